@@ -12,7 +12,12 @@
 
 package main
 
-import "github.com/urfave/cli"
+import (
+	"time"
+
+	"github.com/urfave/cli"
+	"isula.org/isula-transform/pkg/server"
+)
 
 var basicFlags = []cli.Flag{
 	cli.StringFlag{
@@ -25,6 +30,15 @@ var basicFlags = []cli.Flag{
 		Usage: "Customize the level of logging for collection, allowed: debug, info, warn, error",
 		Value: "info",
 	},
+	cli.StringFlag{
+		Name:  "progress",
+		Usage: "how to display the progress of containers on stderr, allowed: auto, tty, plain, none, auto is tty on a terminal and none otherwise",
+		Value: "auto",
+	},
+	cli.StringFlag{
+		Name:  "metrics-textfile",
+		Usage: "write the metrics of the run to the .prom file for the textfile collector of node_exporter",
+	},
 	cli.StringFlag{
 		Name:   "container-type",
 		Usage:  "origin container type",
@@ -44,6 +58,16 @@ var dockerFlags = []cli.Flag{
 		Usage: "state root of docker",
 		Value: "/var/run/docker",
 	},
+	cli.StringFlag{
+		Name:  "docker-dm-diff",
+		Usage: "how to get the changes of devicemapper container, allowed: api, local",
+		Value: "api",
+	},
+	cli.StringFlag{
+		Name:  "docker-finalize",
+		Usage: "what to do with the docker container after a successful transformation, allowed: keep, stop, disable-restart, remove",
+		Value: "keep",
+	},
 }
 
 var containerFlags = []cli.Flag{
@@ -51,6 +75,81 @@ var containerFlags = []cli.Flag{
 		Name:  "all",
 		Usage: "transform all containers",
 	},
+	cli.StringSliceFlag{
+		Name: "filter",
+		Usage: "select containers by key=value, allowed keys: label, name (regex), image (name[:tag] or ID of 12+ chars), " +
+			"status, created-before, created-after, network-mode, all containers are selected if no container is given",
+	},
+	cli.StringSliceFlag{
+		Name:  "exclude",
+		Usage: "leave out the container of the ID, ID prefix or name",
+	},
+	cli.StringFlag{
+		Name:  "from-file",
+		Usage: "read the IDs or names of containers to transform from file, one per line",
+	},
+	cli.StringFlag{
+		Name:  "on-name-conflict",
+		Usage: "how to handle the name in use by isulad containers, allowed: fail, suffix, template",
+		Value: "fail",
+	},
+	cli.StringFlag{
+		Name:  "name-template",
+		Usage: "Go template of the new name for --on-name-conflict=template, fields: .Name, .ID, .ShortID",
+		Value: "{{.Name}}-{{.ShortID}}",
+	},
+	cli.StringSliceFlag{
+		Name:  "remap-path",
+		Usage: "relocate the host paths of binds, mount points, mounts and log path as old=new, the new sources must exist",
+	},
+	cli.StringFlag{
+		Name:  "rules-file",
+		Usage: "JSON or YAML (.yaml, .yml) file of the rules changing the configs of the selected containers after the built-in reconciliation",
+	},
+	cli.BoolFlag{
+		Name:  "verify",
+		Usage: "verify the content of read-write layer after migration, roll back on mismatch",
+	},
+	cli.BoolFlag{
+		Name:  "no-deps",
+		Usage: "refuse the containers depending on the ones neither given nor in isulad instead of transforming them together",
+	},
+	cli.BoolFlag{
+		Name:  "start",
+		Usage: "restart isulad to load the transformed containers and start them with the docker containers stopped, roll back if they are not ready",
+	},
+	cli.DurationFlag{
+		Name:  "start-timeout",
+		Usage: "how long to wait for the started containers to be running and healthy, 0 means no limit",
+		Value: time.Minute,
+	},
+	cli.IntFlag{
+		Name:  "parallel",
+		Usage: "number of containers paused and transformed at the same time, 0 means no limit",
+		Value: 8,
+	},
+	cli.StringFlag{
+		Name:  "copy-bps",
+		Usage: "limit the bytes per second of copying read-write layers, such as 100M, 0 means no limit",
+		Value: "0",
+	},
+	cli.DurationFlag{
+		Name:  "container-timeout",
+		Usage: "how long the transformation of each container may take before it is rolled back, 0 means no limit",
+	},
+	cli.StringSliceFlag{
+		Name: "phase-timeout",
+		Usage: "limit a phase of the transformation of each container as phase=duration, such as rw-layer=30m, " +
+			"phases: pause, config, rw-layer, verify, create, start, finalize",
+	},
 }
 
 var transformFlags = [][]cli.Flag{basicFlags, dockerFlags, containerFlags}
+
+var serveFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "socket",
+		Usage: "unix socket the REST API listens on",
+		Value: server.DefaultSocket,
+	},
+}
@@ -13,18 +13,24 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
 	"gopkg.in/natefinch/lumberjack.v2"
 	"isula.org/isula-transform/pkg/isulad"
+	"isula.org/isula-transform/pkg/metrics"
+	"isula.org/isula-transform/pkg/server"
 	"isula.org/isula-transform/transform"
 	_ "isula.org/isula-transform/transform/register"
 	"isula.org/isula-transform/utils"
@@ -39,6 +45,8 @@ const (
 	maxPerLogFileSize      = 10 // megabytes
 
 	isuladConfFIle = "/etc/isulad/daemon.json"
+
+	serveShutdownTimeout = 10 * time.Second
 )
 
 var (
@@ -58,9 +66,18 @@ func main() {
 	app := &cli.App{
 		Name:      "isula-transform",
 		Usage:     "transform specify docker container type configuration to iSulad type",
-		UsageText: "[global options] --all|container_id[ container_id...]",
+		UsageText: "[global options] --all|container[ container...]",
 		Version:   genVersion(),
 		Action:    start,
+		Commands: []cli.Command{
+			{
+				Name:      "serve",
+				Usage:     "serve the transform, check and rollback jobs through a REST API on a unix socket",
+				UsageText: "[global options] serve [--socket path]",
+				Flags:     serveFlags,
+				Action:    serve,
+			},
+		},
 	}
 	for _, v := range transformFlags {
 		app.Flags = append(app.Flags, v...)
@@ -80,6 +97,52 @@ func start(ctx *cli.Context) error {
 	return doTransform(ctx)
 }
 
+func serve(ctx *cli.Context) error {
+	logInit(ctx)
+	if err := transformInit(); err != nil {
+		return cli.NewExitError(err.Error(), exitInitErr)
+	}
+	manager := server.NewManager(func() (transform.Transformer, error) {
+		e := transform.GetTransformer(ctx)
+		if e == nil {
+			return nil, errors.New("get transform engine failed")
+		}
+		if err := e.Init(); err != nil {
+			return nil, errors.Wrap(err, "transform engine init failed")
+		}
+		return e, nil
+	})
+	runCtx, stopRun := context.WithCancel(context.Background())
+	runDone := make(chan struct{})
+	go func() {
+		manager.Run(runCtx)
+		close(runDone)
+	}()
+	// the running job is canceled by the signal as well, wait for its rollback
+	defer func() {
+		stopRun()
+		<-runDone
+	}()
+
+	srv := server.New(manager)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, unix.SIGINT, unix.SIGTERM)
+	defer signal.Stop(sigCh)
+	go func() {
+		sig := <-sigCh
+		logrus.Infof("receive signal %v, shutting down", sig)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logrus.Warnf("shut down server: %v", err)
+		}
+	}()
+	if err := srv.Serve(ctx.String("socket")); err != nil {
+		return cli.NewExitError(err.Error(), exitInitErr)
+	}
+	return nil
+}
+
 func logInit(ctx *cli.Context) {
 	logPath := ctx.GlobalString("log")
 	logRoot := filepath.Dir(logPath)
@@ -153,21 +216,91 @@ func doTransform(ctx *cli.Context) error {
 	if !all {
 		if ctx.Args().Present() {
 			ids = append(ctx.Args().Tail(), ctx.Args().First())
-		} else {
-			exitMsg := "isula-transform requires at least one container id as an input or setting the --all flag"
+		}
+		if file := ctx.GlobalString("from-file"); file != "" {
+			fileIDs, err := readIDsFile(file)
+			if err != nil {
+				return cli.NewExitError(err.Error(), exitInitErr)
+			}
+			ids = append(ids, fileIDs...)
+		}
+		// filters select from all the containers if no container is given
+		all = len(ids) == 0 && len(ctx.GlobalStringSlice("filter")) > 0
+		if len(ids) == 0 && !all {
+			exitMsg := "isula-transform requires at least one container id as an input, --from-file, --filter or the --all flag"
 			return cli.NewExitError(exitMsg, exitInitErr)
 		}
 	}
 
+	display, err := newProgressDisplay(os.Stderr, ctx.GlobalString("progress"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), exitInitErr)
+	}
+	if file := ctx.GlobalString("metrics-textfile"); file != "" && !strings.HasSuffix(file, metrics.TextfileSuffix) {
+		return cli.NewExitError(fmt.Sprintf("metrics textfile %s must end with %s", file, metrics.TextfileSuffix), exitInitErr)
+	}
+	var collector *metrics.Collector
+	metricsFile := ctx.GlobalString("metrics-textfile")
+	if metricsFile != "" {
+		collector = metrics.NewCollector()
+	}
+	var events <-chan transform.Event
+	if p, ok := e.(transform.Publisher); ok && (display != nil || collector != nil) {
+		ch, unsubscribe := p.Events().Subscribe(progressBuffer)
+		defer unsubscribe()
+		events = ch
+	}
+
+	transCtx, stop := signalContext()
+	defer stop()
 	exitCode := exitNormal
 	retCh := make(chan transform.Result, maxConcurrentTransform)
-	go e.Transform(ids, all, retCh)
-	for ret := range retCh {
-		if !ret.Ok {
-			exitCode = exitTransformErr
-			fmt.Fprintln(os.Stderr, ret.Msg)
-		} else {
-			fmt.Fprintln(os.Stdout, ret.Msg)
+	go e.Transform(transCtx, ids, all, retCh)
+	for results := retCh; results != nil; {
+		select {
+		case ev := <-events:
+			if display != nil {
+				display.handle(ev)
+			}
+			if collector != nil {
+				collector.Handle(ev)
+			}
+		case ret, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			if collector != nil {
+				collector.Result(ret)
+			}
+			out := os.Stdout
+			if !ret.Ok {
+				exitCode = exitTransformErr
+				out = os.Stderr
+			}
+			if display != nil {
+				display.println(out, ret.Msg)
+			} else {
+				fmt.Fprintln(out, ret.Msg)
+			}
+		}
+	}
+	if display != nil {
+		display.clear()
+	}
+	if collector != nil {
+		// the events published before the results are still buffered, they categorize the failures
+		for drained := false; !drained; {
+			select {
+			case ev := <-events:
+				collector.Handle(ev)
+			default:
+				drained = true
+			}
+		}
+		if err := collector.WriteTextfile(metricsFile, time.Now()); err != nil {
+			logrus.Errorf("write metrics textfile failed: %v", err)
+			fmt.Fprintln(os.Stderr, err)
 		}
 	}
 	if exitCode != exitNormal {
@@ -175,3 +308,48 @@ func doTransform(ctx *cli.Context) error {
 	}
 	return nil
 }
+
+// signalContext returns a context canceled once SIGHUP, SIGINT or SIGTERM is caught,
+// so that the containers being transformed are rolled back before exiting
+func signalContext() (context.Context, func()) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, unix.SIGHUP, unix.SIGINT, unix.SIGTERM)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case sig := <-sigCh:
+			logrus.Infof("catch signal %v", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sigCh)
+		cancel()
+	}
+}
+
+// readIDsFile reads the IDs or names of containers from file, one per line,
+// blank lines and the lines starting with # are ignored
+func readIDsFile(file string) ([]string, error) {
+	if err := utils.CheckFileValid(file); err != nil {
+		return nil, errors.Wrap(err, "check containers file")
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		logrus.Errorf("read containers file %s failed: %v", file, err)
+		return nil, errors.Wrap(err, "read containers file")
+	}
+	var ids []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids = append(ids, line)
+	}
+	if len(ids) == 0 {
+		return nil, errors.Errorf("no container found in %s", file)
+	}
+	return ids, nil
+}
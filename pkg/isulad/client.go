@@ -0,0 +1,246 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package isulad
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultIsuladHost = "unix:///var/run/isulad.sock"
+	unixHostPrefix    = "unix://"
+
+	// paths of the REST interface of isulad
+	restContainerStart   = "/ContainerService/Start"
+	restContainerStop    = "/ContainerService/Stop"
+	restContainerDelete  = "/ContainerService/Delete"
+	restContainerInspect = "/ContainerService/Inspect"
+	restContainerVersion = "/ContainerService/Version"
+
+	// health status of the container with healthcheck
+	healthHealthy   = "healthy"
+	healthUnhealthy = "unhealthy"
+
+	defaultPollInterval = 500 * time.Millisecond
+	stopTimeout         = 10
+)
+
+// noSuchContainerMsg is the error message of isulad for the container it does not have
+const noSuchContainerMsg = "No such container"
+
+// restartCommand restarts isulad managed by systemd
+var restartCommand = []string{"systemctl", "restart", "isulad"}
+
+// Client talks to isulad through its REST interface on the local socket
+type Client struct {
+	http *http.Client
+	// interval of polling the state of container
+	interval time.Duration
+	// restart restarts the daemon of isulad
+	restart func(ctx context.Context) error
+}
+
+// restResponse is the common part of the responses of isulad
+type restResponse struct {
+	Cc     uint32 `json:"cc"`
+	Errmsg string `json:"errmsg"`
+}
+
+// ContainerInspect is the part of isulad inspect used by the transformation
+type ContainerInspect struct {
+	ID    string `json:"Id"`
+	Name  string `json:"Name"`
+	State struct {
+		Status   string `json:"Status"`
+		Running  bool   `json:"Running"`
+		ExitCode int    `json:"ExitCode"`
+		Error    string `json:"Error"`
+		Health   *struct {
+			Status string `json:"Status"`
+		} `json:"Health,omitempty"`
+	} `json:"State"`
+}
+
+// NewClient creates a client of isulad listening on host, only unix socket is supported
+func NewClient(host string) (*Client, error) {
+	if host == "" {
+		host = defaultIsuladHost
+	}
+	if !strings.HasPrefix(host, unixHostPrefix) {
+		return nil, fmt.Errorf("unsupported isulad host %s, only unix socket is supported", host)
+	}
+	sock := strings.TrimPrefix(host, unixHostPrefix)
+	return &Client{
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", sock)
+				},
+				DisableCompression: true,
+			},
+		},
+		interval: defaultPollInterval,
+		restart:  restartDaemon,
+	}, nil
+}
+
+func restartDaemon(ctx context.Context) error {
+	out, err := exec.CommandContext(ctx, restartCommand[0], restartCommand[1:]...).CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "%s: %s", strings.Join(restartCommand, " "), strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// call posts req to path of isulad and decodes the response into resp
+func (c *Client) call(ctx context.Context, path string, req interface{}, resp interface{}) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return errors.Wrap(err, "marshal request")
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, "http://localhost"+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := c.http.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "request %s of isulad", path)
+	}
+	defer httpResp.Body.Close()
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return errors.Wrapf(err, "read response of %s", path)
+	}
+
+	var common restResponse
+	if err := json.Unmarshal(body, &common); err != nil {
+		return errors.Wrapf(err, "unmarshal response of %s with status %d", path, httpResp.StatusCode)
+	}
+	if httpResp.StatusCode != http.StatusOK || common.Cc != 0 {
+		return fmt.Errorf("isulad %s failed with status %d: %s", path, httpResp.StatusCode, common.Errmsg)
+	}
+	if resp != nil {
+		return errors.Wrapf(json.Unmarshal(body, resp), "unmarshal response of %s", path)
+	}
+	return nil
+}
+
+// Ping checks that isulad serves its REST interface
+func (c *Client) Ping(ctx context.Context) error {
+	return c.call(ctx, restContainerVersion, struct{}{}, nil)
+}
+
+// Reload restarts isulad, which loads the containers on disk only when it starts, and waits
+// until it serves again. The running containers of isulad are restored by isulad after restart
+func (c *Client) Reload(ctx context.Context) error {
+	if err := c.restart(ctx); err != nil {
+		return errors.Wrap(err, "restart isulad")
+	}
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		err := c.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(err, "wait for isulad to serve: %v", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Start starts the container id
+func (c *Client) Start(ctx context.Context, id string) error {
+	return c.call(ctx, restContainerStart, map[string]interface{}{"id": id}, nil)
+}
+
+// Stop stops the container id by force
+func (c *Client) Stop(ctx context.Context, id string) error {
+	return c.call(ctx, restContainerStop, map[string]interface{}{
+		"id":      id,
+		"force":   true,
+		"timeout": stopTimeout,
+	}, nil)
+}
+
+// Delete removes the container id by force
+func (c *Client) Delete(ctx context.Context, id string) error {
+	return c.call(ctx, restContainerDelete, map[string]interface{}{"id": id, "force": true}, nil)
+}
+
+// IsNoSuchContainer reports whether err is returned by isulad for the container it does not have
+func IsNoSuchContainer(err error) bool {
+	return err != nil && strings.Contains(err.Error(), noSuchContainerMsg)
+}
+
+// Inspect returns the state of the container id
+func (c *Client) Inspect(ctx context.Context, id string) (*ContainerInspect, error) {
+	var resp struct {
+		restResponse
+		ContainerJSON string `json:"container_json"`
+	}
+	if err := c.call(ctx, restContainerInspect, map[string]interface{}{"id": id}, &resp); err != nil {
+		return nil, err
+	}
+	var info ContainerInspect
+	if err := json.Unmarshal([]byte(resp.ContainerJSON), &info); err != nil {
+		return nil, errors.Wrap(err, "unmarshal container inspect")
+	}
+	return &info, nil
+}
+
+// WaitReady waits until the container id is running, and healthy if it has a healthcheck
+func (c *Client) WaitReady(ctx context.Context, id string) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		info, err := c.Inspect(ctx, id)
+		if err != nil {
+			return err
+		}
+		var health string
+		if info.State.Health != nil {
+			health = info.State.Health.Status
+		}
+		switch {
+		case !info.State.Running && (info.State.Status == "exited" || info.State.Status == "stopped"):
+			return fmt.Errorf("container exited with code %d: %s", info.State.ExitCode, info.State.Error)
+		case info.State.Running && (health == "" || health == healthHealthy):
+			return nil
+		case health == healthUnhealthy:
+			return fmt.Errorf("container is unhealthy")
+		default:
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "wait for container in status %s", info.State.Status)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package isulad
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeIsulad serves the REST interface of isulad for the containers in states, which are on disk
+// and loaded only when the fake restarts, the container goes through its states one by one on each
+// inspect after starting
+type fakeIsulad struct {
+	mu      sync.Mutex
+	states  map[string][]string
+	started map[string]bool
+	deleted map[string]bool
+	loaded  bool
+	// down is the number of pings failing after restart
+	down int
+}
+
+func (f *fakeIsulad) restart(context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loaded = true
+	f.down = 2
+	return nil
+}
+
+func (f *fakeIsulad) handler() http.Handler {
+	reply := func(w http.ResponseWriter, resp interface{}) {
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+	handle := func(fn func(id string) interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				ID string `json:"id"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				reply(w, restResponse{Cc: 1, Errmsg: err.Error()})
+				return
+			}
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if _, ok := f.states[req.ID]; !ok || !f.loaded || f.deleted[req.ID] {
+				w.WriteHeader(http.StatusNotFound)
+				reply(w, restResponse{Cc: 1, Errmsg: "No such container:" + req.ID})
+				return
+			}
+			reply(w, fn(req.ID))
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(restContainerVersion, func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.down > 0 {
+			f.down--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			reply(w, restResponse{Cc: 1, Errmsg: "starting"})
+			return
+		}
+		reply(w, restResponse{})
+	})
+	mux.HandleFunc(restContainerStart, handle(func(id string) interface{} {
+		f.started[id] = true
+		return restResponse{}
+	}))
+	mux.HandleFunc(restContainerStop, handle(func(id string) interface{} {
+		f.started[id] = false
+		return restResponse{}
+	}))
+	mux.HandleFunc(restContainerDelete, handle(func(id string) interface{} {
+		f.deleted[id] = true
+		return restResponse{}
+	}))
+	mux.HandleFunc(restContainerInspect, handle(func(id string) interface{} {
+		state := `{"Status":"created"}`
+		if f.started[id] {
+			state = f.states[id][0]
+			if len(f.states[id]) > 1 {
+				f.states[id] = f.states[id][1:]
+			}
+		}
+		return map[string]interface{}{
+			"cc":             0,
+			"container_json": `{"Id":"` + id + `","State":` + state + `}`,
+		}
+	}))
+	return mux
+}
+
+func startFakeIsulad(t *testing.T, sock string, states map[string][]string) *fakeIsulad {
+	f := &fakeIsulad{states: states, started: make(map[string]bool), deleted: make(map[string]bool)}
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("listen %s: %v", sock, err)
+	}
+	srv := &http.Server{Handler: f.handler()}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	return f
+}
+
+func TestClient(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "IsuladClient")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	sock := filepath.Join(tmpdir, "isulad.sock")
+	f := startFakeIsulad(t, sock, map[string][]string{
+		"running":   {`{"Status":"running","Running":true}`},
+		"healthy":   {`{"Running":true,"Health":{"Status":"starting"}}`, `{"Running":true,"Health":{"Status":"healthy"}}`},
+		"unhealthy": {`{"Running":true,"Health":{"Status":"starting"}}`, `{"Running":true,"Health":{"Status":"unhealthy"}}`},
+		"exited":    {`{"Status":"exited","ExitCode":1,"Error":"oops"}`},
+		"hang":      {`{"Status":"running","Running":true,"Health":{"Status":"starting"}}`},
+	})
+
+	Convey("TestClient", t, func() {
+		_, err := NewClient("tcp://127.0.0.1:2375")
+		So(err, ShouldBeError)
+		tool := &Tool{hosts: []string{"tcp://127.0.0.1:2375", "unix://" + sock}}
+		c, err := tool.Client()
+		So(err, ShouldBeNil)
+		c.interval = 10 * time.Millisecond
+		c.restart = f.restart
+		ctx := context.Background()
+
+		Convey("not loaded before reload", func() {
+			So(c.Ping(ctx), ShouldBeNil)
+			So(c.Start(ctx, "running"), ShouldBeError, "isulad /ContainerService/Start failed with status 404: "+
+				"No such container:running")
+		})
+
+		So(c.Reload(ctx), ShouldBeNil)
+
+		Convey("container not found", func() {
+			err := c.Start(ctx, "missing")
+			So(err, ShouldBeError, "isulad /ContainerService/Start failed with status 404: "+
+				"No such container:missing")
+			So(IsNoSuchContainer(err), ShouldBeTrue)
+			So(IsNoSuchContainer(c.Start(ctx, "running")), ShouldBeFalse)
+		})
+
+		Convey("start and wait", func() {
+			for _, id := range []string{"running", "healthy"} {
+				So(c.Start(ctx, id), ShouldBeNil)
+				So(c.WaitReady(ctx, id), ShouldBeNil)
+			}
+			So(c.Start(ctx, "unhealthy"), ShouldBeNil)
+			So(c.WaitReady(ctx, "unhealthy"), ShouldBeError, "container is unhealthy")
+			So(c.Start(ctx, "exited"), ShouldBeNil)
+			So(c.WaitReady(ctx, "exited"), ShouldBeError, "container exited with code 1: oops")
+
+			So(c.Start(ctx, "hang"), ShouldBeNil)
+			timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			So(c.WaitReady(timeoutCtx, "hang"), ShouldBeError)
+		})
+
+		Convey("stop and delete", func() {
+			So(c.Start(ctx, "running"), ShouldBeNil)
+			So(c.Stop(ctx, "running"), ShouldBeNil)
+			info, err := c.Inspect(ctx, "running")
+			So(err, ShouldBeNil)
+			So(info.ID, ShouldEqual, "running")
+			So(info.State.Status, ShouldEqual, "created")
+			So(c.Delete(ctx, "running"), ShouldBeNil)
+			f.mu.Lock()
+			deleted := f.deleted["running"]
+			f.mu.Unlock()
+			So(deleted, ShouldBeTrue)
+			_, err = c.Inspect(ctx, "running")
+			So(err, ShouldBeError)
+		})
+	})
+}
@@ -68,10 +68,11 @@ int init_isulad_image_module(char *graph, char *state, char *driver, char **opts
     return ret;
 }
 
-char *isulad_img_prepare_rootfs(char *type, char *id, char *name)
+char *isulad_img_prepare_rootfs(char *type, char *id, char *name, char **opt_keys, char **opt_values, size_t opt_len)
 {
     char *real_rootfs = NULL;
     im_prepare_request *req = NULL;
+    size_t i = 0;
 
     if (type == NULL || id == NULL || name == NULL)
     {
@@ -82,11 +83,23 @@ char *isulad_img_prepare_rootfs(char *type, char *id, char *name)
     req->container_id = util_strdup_s(id);
     req->image_type = util_strdup_s(type);
     req->image_name = util_strdup_s(name);
+    if (opt_len > 0)
+    {
+        req->storage_opt = safe_malloc(sizeof(json_map_string_string));
+        for (i = 0; i < opt_len; i++)
+        {
+            if (append_json_map_string_string(req->storage_opt, opt_keys[i], opt_values[i]) != 0)
+            {
+                goto out;
+            }
+        }
+    }
     if (im_prepare_container_rootfs(req, &real_rootfs) != 0)
     {
         real_rootfs = NULL;
     }
 
+out:
     free_im_prepare_request(req);
     return real_rootfs;
 }
\ No newline at end of file
@@ -70,16 +70,32 @@ func InitLib(graph, state, driverType string, driverOpts []string, check bool) e
 	return nil
 }
 
-// PrepareRootfs calls isulad_img_prepare_rootfs to prepare container rootfs
-func PrepareRootfs(id, image string) string {
+// PrepareRootfs calls isulad_img_prepare_rootfs to prepare container rootfs,
+// storageOpt such as size is used to limit the rootfs
+func PrepareRootfs(id, image string, storageOpt map[string]string) string {
 	imageType := C.CString(imageTypeOCI)
 	defer C.free(unsafe.Pointer(imageType))
 	containerID := C.CString(id)
 	defer C.free(unsafe.Pointer(containerID))
 	imageName := C.CString(image)
 	defer C.free(unsafe.Pointer(imageName))
+	keys := make([]*C.char, 0, len(storageOpt))
+	values := make([]*C.char, 0, len(storageOpt))
+	for k, v := range storageOpt {
+		cKey, cValue := C.CString(k), C.CString(v)
+		defer C.free(unsafe.Pointer(cKey))
+		defer C.free(unsafe.Pointer(cValue))
+		keys = append(keys, cKey)
+		values = append(values, cValue)
+	}
+	var cKeys, cValues **C.char
+	if len(storageOpt) != 0 {
+		cKeys = (**C.char)(unsafe.Pointer(&keys[0]))
+		cValues = (**C.char)(unsafe.Pointer(&values[0]))
+	}
 
-	realRootfs := C.isulad_img_prepare_rootfs(imageType, containerID, imageName)
+	realRootfs := C.isulad_img_prepare_rootfs(imageType, containerID, imageName,
+		cKeys, cValues, C.size_t(len(storageOpt)))
 	mountPoint := C.GoString(realRootfs)
 	return mountPoint
 }
@@ -13,4 +13,4 @@
 #include <isulad/image_api.h>
 
 extern int init_isulad_image_module(char *graph, char *state, char *driver, char **opts, size_t len, int check);
-extern char *isulad_img_prepare_rootfs(char *type, char *id, char *name);
\ No newline at end of file
+extern char *isulad_img_prepare_rootfs(char *type, char *id, char *name, char **opt_keys, char **opt_values, size_t opt_len);
\ No newline at end of file
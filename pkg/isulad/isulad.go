@@ -19,6 +19,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/pkg/errors"
@@ -27,6 +28,7 @@ import (
 	"isula.org/isula-transform/pkg/isulad/internal/isuladimg"
 	"isula.org/isula-transform/transform"
 	"isula.org/isula-transform/types"
+	"isula.org/isula-transform/utils"
 )
 
 const (
@@ -41,6 +43,11 @@ const (
 	defaultIsuladStatePath = "/var/run/isulad"
 	defaultRuntime         = "lcr"
 	defaultStorageDriver   = "overlay2"
+
+	// CgroupDriverCgroupfs manages the cgroups through the cgroup filesystem
+	CgroupDriverCgroupfs = "cgroupfs"
+	// CgroupDriverSystemd manages the cgroups through the slices and scopes of systemd
+	CgroupDriverSystemd = "systemd"
 )
 
 var (
@@ -57,12 +64,20 @@ type DaemonConfig struct {
 	StorageDriver   string   `json:"storage-driver"`
 	StorageOpts     []string `json:"storage-opts"`
 	ImageLayerCheck bool     `json:"image-layer-check"`
+	Hosts           []string `json:"hosts"`
+	SystemdCgroup   bool     `json:"systemd-cgroup"`
+	UsernsRemap     string   `json:"userns-remap"`
 }
 
 // Tool contains the common functions used by transformer
 type Tool struct {
 	graph   string
 	runtime string
+	hosts   []string
+	// cgroupDriver is the cgroup driver isulad is configured with
+	cgroupDriver string
+	// usernsRemap is the uid:gid:size isulad remaps the root of containers to
+	usernsRemap string
 
 	// storage
 	storageType   transform.StorageType
@@ -90,7 +105,13 @@ func InitIsuladTool(conf *DaemonConfig) error {
 	commonTool = &Tool{
 		graph:       conf.Graph,
 		runtime:     conf.Runtime,
+		hosts:       conf.Hosts,
 		storageType: transform.StorageType(conf.StorageDriver),
+		usernsRemap: conf.UsernsRemap,
+	}
+	commonTool.cgroupDriver = CgroupDriverCgroupfs
+	if conf.SystemdCgroup {
+		commonTool.cgroupDriver = CgroupDriverSystemd
 	}
 
 	if err := checkToolConfigValid(); err != nil {
@@ -146,6 +167,29 @@ func (ict *Tool) Runtime() string {
 	return ict.runtime
 }
 
+// Client returns a client of isulad listening on the first unix socket of hosts
+func (ict *Tool) Client() (*Client, error) {
+	for _, host := range ict.hosts {
+		if strings.HasPrefix(host, unixHostPrefix) {
+			return NewClient(host)
+		}
+	}
+	return NewClient(defaultIsuladHost)
+}
+
+// CgroupDriver returns the cgroup driver of isulad, cgroupfs or systemd
+func (ict *Tool) CgroupDriver() string {
+	if ict.cgroupDriver == "" {
+		return CgroupDriverCgroupfs
+	}
+	return ict.cgroupDriver
+}
+
+// UsernsRemap returns the uid:gid:size isulad remaps the root of containers to, empty if it does not remap
+func (ict *Tool) UsernsRemap() string {
+	return ict.usernsRemap
+}
+
 // GetRuntimePath returns the default runtime path of isulad
 func (ict *Tool) GetRuntimePath() string {
 	return filepath.Join(ict.graph, "engines", ict.runtime)
@@ -228,13 +272,42 @@ func (ict *Tool) Cleanup(id string) error {
 	return os.RemoveAll(path)
 }
 
-// PrepareShm creates sharm shm mount point for container
-func (ict *Tool) PrepareShm(path string, size int64) error {
+// ContainerNames maps the names of the containers existing in isulad to their IDs
+func (ict *Tool) ContainerNames() (map[string]string, error) {
+	names := make(map[string]string)
+	infos, err := ioutil.ReadDir(ict.GetRuntimePath())
+	if os.IsNotExist(err) {
+		return names, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "read isulad containers")
+	}
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		var cfg types.IsuladV2Config
+		data, err := ioutil.ReadFile(ict.GetConfigV2Path(info.Name()))
+		if err == nil {
+			err = json.Unmarshal(data, &cfg)
+		}
+		if err != nil {
+			logrus.Warnf("load name of isulad container %s failed: %v", info.Name(), err)
+			continue
+		}
+		if cfg.CommonConfig != nil && cfg.CommonConfig.Name != "" {
+			names[cfg.CommonConfig.Name] = info.Name()
+		}
+	}
+	return names, nil
+}
+
+// PrepareShm creates sharm shm mount point for container, which is labeled with mountLabel
+func (ict *Tool) PrepareShm(path string, size int64, mountLabel string) error {
 	err := os.MkdirAll(path, mountsDirMode)
 	if err != nil {
 		return err
 	}
-	shmProperty := "mode=1777,size=" + strconv.FormatInt(size, 10)
+	shmProperty := utils.FormatMountLabel("mode=1777,size="+strconv.FormatInt(size, 10), mountLabel)
 	err = unix.Mount("shm", path, "tmpfs", uintptr(unix.MS_NOEXEC|unix.MS_NOSUID|unix.MS_NODEV), shmProperty)
 	if err != nil {
 		return err
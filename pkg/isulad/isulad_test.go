@@ -56,6 +56,17 @@ func TestInitIsuladTool(t *testing.T) {
 			So(GetIsuladTool().runtime, ShouldEqual, testIsuladTool.runtime)
 			So(GetIsuladTool().storageType, ShouldEqual, testIsuladTool.storageType)
 			So(GetIsuladTool().storageDriver, ShouldNotBeNil)
+			So(GetIsuladTool().CgroupDriver(), ShouldEqual, CgroupDriverCgroupfs)
+		})
+
+		Convey("systemd cgroup driver", func() {
+			So(InitIsuladTool(&DaemonConfig{SystemdCgroup: true}), ShouldBeNil)
+			So(GetIsuladTool().CgroupDriver(), ShouldEqual, CgroupDriverSystemd)
+		})
+
+		Convey("userns remap", func() {
+			So(InitIsuladTool(&DaemonConfig{UsernsRemap: "100000:100000:65536"}), ShouldBeNil)
+			So(GetIsuladTool().UsernsRemap(), ShouldEqual, "100000:100000:65536")
 		})
 	})
 }
@@ -225,7 +236,7 @@ func TestIsuladTool_PrepareShm(t *testing.T) {
 	Convey("TestIsuladTool_PrepareShm", t, func() {
 		var shmPath = filepath.Join(tmpdir, "mounts/shm")
 		var shmSize int64 = 67108864
-		So(testIsuladTool.PrepareShm(shmPath, shmSize), ShouldBeEmpty)
+		So(testIsuladTool.PrepareShm(shmPath, shmSize, ""), ShouldBeEmpty)
 		defer func(path string) {
 			if err := unix.Unmount(path, unix.MNT_DETACH); err != nil {
 				t.Logf("umount path err: %v", err)
@@ -236,3 +247,28 @@ func TestIsuladTool_PrepareShm(t *testing.T) {
 		So(info.Mode(), ShouldEqual, os.ModeDir|os.ModeSticky|os.ModePerm)
 	})
 }
+
+func TestIsuladTool_ContainerNames(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "IsuladTool")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	tool := &Tool{graph: tmpdir, runtime: "lcr"}
+	Convey("TestIsuladTool_ContainerNames", t, func() {
+		names, err := tool.ContainerNames()
+		So(err, ShouldBeNil)
+		So(names, ShouldBeEmpty)
+
+		for id, cfg := range map[string]string{
+			"ctr1": `{"CommonConfig":{"Name":"web"}}`,
+			"ctr2": `bad json`,
+		} {
+			So(os.MkdirAll(filepath.Join(tool.GetRuntimePath(), id), rootDirMode), ShouldBeNil)
+			So(ioutil.WriteFile(tool.GetConfigV2Path(id), []byte(cfg), cfgFileMode), ShouldBeNil)
+		}
+		names, err = tool.ContainerNames()
+		So(err, ShouldBeNil)
+		So(names, ShouldResemble, map[string]string{"web": "ctr1"})
+	})
+}
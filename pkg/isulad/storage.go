@@ -20,8 +20,8 @@ import (
 
 type isuladStorageDriver struct{}
 
-func (sd *isuladStorageDriver) GenerateRootFs(id, image string) (string, error) {
-	mountPoint := isuladimg.PrepareRootfs(id, image)
+func (sd *isuladStorageDriver) GenerateRootFs(id, image string, storageOpt map[string]string) (string, error) {
+	mountPoint := isuladimg.PrepareRootfs(id, image, storageOpt)
 	if mountPoint == "" {
 		return "", errors.New("isuladimg returns nil rootfs")
 	}
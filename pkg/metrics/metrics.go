@@ -0,0 +1,274 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+// Package metrics collects the progress events of a transformation run and
+// writes them as a textfile of the node_exporter textfile collector
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"isula.org/isula-transform/transform"
+	"isula.org/isula-transform/utils"
+)
+
+const (
+	namespace = "isula_transform"
+	// TextfileSuffix is the suffix of the files read by the textfile collector
+	TextfileSuffix = ".prom"
+
+	// categories of the failures not caused by a phase of the container itself
+	categoryGroup      = "group"
+	categoryNotStarted = "not-started"
+)
+
+// phaseBuckets are the upper bounds of the phase duration histogram in seconds
+var phaseBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(phaseBuckets))
+	}
+	for i, b := range phaseBuckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// Collector gathers the metrics of a run from the results of the transformer and the progress
+// events. The results are counted as they are received since the events may be dropped, the
+// events only categorize the failures and time the phases
+type Collector struct {
+	mu    sync.Mutex
+	start time.Time
+	// results are categorized when the metrics are written, after the events before them
+	results []transform.Result
+	// failedPhase and started record the phases of the containers
+	failedPhase map[string]string
+	started     map[string]bool
+	pausedAt    map[string]time.Time
+	// finishedAt is when the result of the container came, which ends its pause
+	finishedAt map[string]time.Time
+	copied     map[string]int64
+	rolledBack map[string]bool
+	phases     map[string]*histogram
+}
+
+// NewCollector creates a collector of the run starting now
+func NewCollector() *Collector {
+	return &Collector{
+		start:       time.Now(),
+		failedPhase: make(map[string]string),
+		started:     make(map[string]bool),
+		pausedAt:    make(map[string]time.Time),
+		finishedAt:  make(map[string]time.Time),
+		copied:      make(map[string]int64),
+		rolledBack:  make(map[string]bool),
+		phases:      make(map[string]*histogram),
+	}
+}
+
+// Handle updates the metrics with ev
+func (c *Collector) Handle(ev transform.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := ev.Container
+	switch ev.Type {
+	case transform.EventPhaseStarted:
+		c.started[id] = true
+		if ev.Phase == transform.PhasePause {
+			c.pausedAt[id] = ev.Time
+		}
+	case transform.EventPhaseFinished:
+		h, ok := c.phases[ev.Phase]
+		if !ok {
+			h = &histogram{}
+			c.phases[ev.Phase] = h
+		}
+		h.observe(ev.Duration.Seconds())
+		if !ev.Ok {
+			c.failedPhase[id] = ev.Phase
+		}
+	case transform.EventBytesCopied:
+		c.copied[id] = ev.Bytes
+	case transform.EventRollbackStep:
+		c.rolledBack[id] = true
+	case transform.EventResult:
+		// the event is published right before the result is sent, its time is more accurate
+		c.finishedAt[id] = ev.Time
+	default:
+	}
+}
+
+// Result counts ret received from the transformer
+func (c *Collector) Result(ret transform.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, ret)
+	if _, ok := c.finishedAt[ret.Container]; !ok {
+		c.finishedAt[ret.Container] = time.Now()
+	}
+}
+
+// counts returns the attempted and succeeded containers, the failed ones by category, which is the
+// failed phase, group if the container is rolled back for another member of its group or not-started,
+// and the skipped ones by reason, which are not attempted
+func (c *Collector) counts() (int, int, map[string]int, map[string]int) {
+	attempted, succeeded := 0, 0
+	failed, skipped := make(map[string]int), make(map[string]int)
+	for _, ret := range c.results {
+		if ret.Skipped != "" {
+			skipped[ret.Skipped]++
+			continue
+		}
+		attempted++
+		if ret.Ok {
+			succeeded++
+			continue
+		}
+		category := c.failedPhase[ret.Container]
+		if category == "" {
+			category = categoryNotStarted
+			if c.started[ret.Container] {
+				category = categoryGroup
+			}
+		}
+		failed[category]++
+	}
+	return attempted, succeeded, failed, skipped
+}
+
+// pausedTimes returns how long each container was paused until its result came
+func (c *Collector) pausedTimes() map[string]time.Duration {
+	paused := make(map[string]time.Duration)
+	for id, at := range c.pausedAt {
+		if end, ok := c.finishedAt[id]; ok {
+			paused[id] = end.Sub(at)
+		}
+	}
+	return paused
+}
+
+// Bytes formats the metrics in the text exposition format of prometheus
+func (c *Collector) Bytes(end time.Time) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var b bytes.Buffer
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(&b, "# HELP %s_%s %s\n# TYPE %s_%s %s\n", namespace, name, help, namespace, name, typ)
+	}
+	sample := func(name, labels string, v float64) {
+		fmt.Fprintf(&b, "%s_%s%s %s\n", namespace, name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+
+	metric("run_timestamp_seconds", "gauge", "Unix time the last run finished.")
+	sample("run_timestamp_seconds", "", float64(end.Unix()))
+	metric("run_duration_seconds", "gauge", "Duration of the last run.")
+	sample("run_duration_seconds", "", end.Sub(c.start).Seconds())
+
+	attempted, succeeded, failed, skipped := c.counts()
+	metric("containers_attempted", "gauge", "Containers attempted in the last run.")
+	sample("containers_attempted", "", float64(attempted))
+	metric("containers_succeeded", "gauge", "Containers transformed successfully in the last run.")
+	sample("containers_succeeded", "", float64(succeeded))
+	metric("containers_failed", "gauge", "Containers failed in the last run by error category.")
+	for _, category := range sortedKeys(failed) {
+		sample("containers_failed", labels("category", category), float64(failed[category]))
+	}
+	metric("containers_skipped", "gauge", "Containers skipped without being attempted in the last run by reason.")
+	for _, reason := range sortedKeys(skipped) {
+		sample("containers_skipped", labels("reason", reason), float64(skipped[reason]))
+	}
+
+	paused := c.pausedTimes()
+	metric("container_paused_seconds", "gauge", "How long each container was paused in the last run.")
+	for _, id := range sortedKeys(paused) {
+		sample("container_paused_seconds", labels("container", id), paused[id].Seconds())
+	}
+
+	var copied int64
+	for _, n := range c.copied {
+		copied += n
+	}
+	metric("rw_layer_copied_bytes", "gauge", "Bytes of read-write layers copied in the last run.")
+	sample("rw_layer_copied_bytes", "", float64(copied))
+
+	metric("rollbacks", "gauge", "Containers rolled back in the last run.")
+	sample("rollbacks", "", float64(len(c.rolledBack)))
+
+	metric("phase_duration_seconds", "histogram", "Duration of the transformation phases of containers in the last run.")
+	for _, phase := range sortedKeys(c.phases) {
+		h := c.phases[phase]
+		for i, bound := range phaseBuckets {
+			sample("phase_duration_seconds_bucket",
+				labels("phase", phase, "le", strconv.FormatFloat(bound, 'g', -1, 64)), float64(h.counts[i]))
+		}
+		sample("phase_duration_seconds_bucket", labels("phase", phase, "le", "+Inf"), float64(h.count))
+		sample("phase_duration_seconds_sum", labels("phase", phase), h.sum)
+		sample("phase_duration_seconds_count", labels("phase", phase), float64(h.count))
+	}
+	return b.Bytes()
+}
+
+// WriteTextfile writes the metrics to path atomically, so that the collector never reads a partial file
+func (c *Collector) WriteTextfile(path string, end time.Time) error {
+	if !strings.HasSuffix(path, TextfileSuffix) {
+		return errors.Errorf("metrics textfile %s must end with %s", path, TextfileSuffix)
+	}
+	return utils.AtomicWriteFile(path, c.Bytes(end), 0644)
+}
+
+// labels formats the pairs of label names and values
+func labels(pairs ...string) string {
+	var parts []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=%q", pairs[i], pairs[i+1]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch v := m.(type) {
+	case map[string]int:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]time.Duration:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]*histogram:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	default:
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/transform"
+)
+
+func TestCollector(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	Convey("TestCollector", t, func() {
+		c := NewCollector()
+		now := time.Now()
+		for _, ev := range []transform.Event{
+			{Type: transform.EventPhaseStarted, Container: "a", Phase: transform.PhasePause, Time: now},
+			{Type: transform.EventPhaseFinished, Container: "a", Phase: transform.PhasePause, Duration: time.Second, Ok: true},
+			{Type: transform.EventBytesCopied, Container: "a", Bytes: 100, Total: 300},
+			{Type: transform.EventBytesCopied, Container: "a", Bytes: 300, Total: 300},
+			{Type: transform.EventResult, Container: "a", Ok: true, Time: now.Add(2 * time.Second)},
+
+			{Type: transform.EventPhaseStarted, Container: "b", Phase: transform.PhasePause, Time: now},
+			{Type: transform.EventPhaseFinished, Container: "b", Phase: transform.PhasePause, Duration: time.Second, Ok: true},
+			{Type: transform.EventPhaseStarted, Container: "b", Phase: transform.PhaseRWLayer, Time: now},
+			{Type: transform.EventPhaseFinished, Container: "b", Phase: transform.PhaseRWLayer, Duration: 20 * time.Second},
+			{Type: transform.EventRollbackStep, Container: "b", Msg: "clean up bundle dir"},
+			{Type: transform.EventResult, Container: "b", Time: now.Add(30 * time.Second)},
+
+			{Type: transform.EventPhaseStarted, Container: "c", Phase: transform.PhasePause, Time: now},
+			{Type: transform.EventRollbackStep, Container: "c", Msg: "clean up bundle dir"},
+			{Type: transform.EventResult, Container: "c", Time: now.Add(time.Second)},
+		} {
+			c.Handle(ev)
+		}
+		// the result events of d and e are dropped, e failed to be selected
+		for _, ret := range []transform.Result{
+			{Container: "a", Ok: true},
+			{Container: "b"},
+			{Container: "c"},
+			{Container: "d"},
+			{Container: "e", Msg: "transform e: dependency x is excluded"},
+			{Container: "f", Msg: "transform f: container was not found", Skipped: transform.SkipUnresolved},
+			{Container: "g", Ok: true, Msg: "transform g: skipped, excluded", Skipped: transform.SkipExcluded},
+			{Container: "h", Ok: true, Msg: "transform h: container has been transformed",
+				Skipped: transform.SkipTransformed},
+		} {
+			c.Result(ret)
+		}
+
+		path := filepath.Join(tmpdir, "transform.prom")
+		So(c.WriteTextfile(path, now.Add(time.Minute)), ShouldBeNil)
+		data, err := ioutil.ReadFile(path)
+		So(err, ShouldBeNil)
+		text := string(data)
+		for _, line := range []string{
+			"isula_transform_containers_attempted 5",
+			"isula_transform_containers_succeeded 1",
+			`isula_transform_containers_failed{category="rw-layer"} 1`,
+			`isula_transform_containers_failed{category="group"} 1`,
+			`isula_transform_containers_failed{category="not-started"} 2`,
+			`isula_transform_containers_skipped{reason="excluded"} 1`,
+			`isula_transform_containers_skipped{reason="transformed"} 1`,
+			`isula_transform_containers_skipped{reason="unresolved"} 1`,
+			`isula_transform_container_paused_seconds{container="a"} 2`,
+			`isula_transform_container_paused_seconds{container="b"} 30`,
+			"isula_transform_rw_layer_copied_bytes 300",
+			"isula_transform_rollbacks 2",
+			`isula_transform_phase_duration_seconds_bucket{phase="pause",le="1"} 2`,
+			`isula_transform_phase_duration_seconds_bucket{phase="rw-layer",le="10"} 0`,
+			`isula_transform_phase_duration_seconds_bucket{phase="rw-layer",le="30"} 1`,
+			`isula_transform_phase_duration_seconds_bucket{phase="rw-layer",le="+Inf"} 1`,
+			`isula_transform_phase_duration_seconds_sum{phase="pause"} 2`,
+			`isula_transform_phase_duration_seconds_count{phase="pause"} 2`,
+			"# TYPE isula_transform_phase_duration_seconds histogram",
+		} {
+			So(text, ShouldContainSubstring, line+"\n")
+		}
+		So(strings.HasSuffix(text, "\n"), ShouldBeTrue)
+
+		So(c.WriteTextfile(filepath.Join(tmpdir, "transform.txt"), now), ShouldBeError)
+	})
+}
@@ -0,0 +1,494 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+// Package server runs the transformations as jobs submitted through a local REST API
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"isula.org/isula-transform/transform"
+)
+
+// Kind is the kind of work done by a job
+type Kind string
+
+// kinds of job
+const (
+	KindTransform Kind = "transform"
+	KindCheck     Kind = "check"
+	KindRollback  Kind = "rollback"
+)
+
+// Phase is the phase of a job
+type Phase string
+
+// phases of job
+const (
+	PhasePending   Phase = "pending"
+	PhaseRunning   Phase = "running"
+	PhaseSucceeded Phase = "succeeded"
+	PhaseFailed    Phase = "failed"
+	PhaseCanceled  Phase = "canceled"
+)
+
+const (
+	// maxPendingJobs limits the jobs waiting in the queue
+	maxPendingJobs = 128
+	// maxResults is the buffer size of the results of a job
+	maxResults = 128
+	// maxProgressEvents is the buffer size of the progress events of a job
+	maxProgressEvents = 1024
+	// maxFinishedJobs limits the finished jobs kept for querying, the oldest are dropped first
+	maxFinishedJobs = 128
+	// finishedJobTTL is how long a finished job is kept for querying
+	finishedJobTTL = 24 * time.Hour
+)
+
+var (
+	errJobNotFound = errors.New("job not found")
+	errJobFinished = errors.New("job is finished")
+	errQueueFull   = errors.New("too many pending jobs")
+)
+
+// Factory creates an initialized transformer for each job
+type Factory func() (transform.Transformer, error)
+
+// Request is the job to be submitted
+type Request struct {
+	Kind Kind     `json:"kind"`
+	IDs  []string `json:"ids,omitempty"`
+	All  bool     `json:"all,omitempty"`
+}
+
+// validate checks whether the request can be run
+func (req *Request) validate() error {
+	switch req.Kind {
+	case KindTransform, KindCheck:
+		if len(req.IDs) == 0 && !req.All {
+			return errors.New("requires at least one container or all")
+		}
+	case KindRollback:
+		if req.All {
+			return errors.New("rollback of all containers is not supported")
+		}
+		if len(req.IDs) == 0 {
+			return errors.New("requires at least one container")
+		}
+	default:
+		return errors.Errorf("unknown job kind %q", req.Kind)
+	}
+	return nil
+}
+
+// Event is a progress of job, either a change of phase, a result of container
+// or a progress event published by the transformer
+type Event struct {
+	Time     time.Time        `json:"time"`
+	Phase    Phase            `json:"phase,omitempty"`
+	Msg      string           `json:"msg,omitempty"`
+	Ok       bool             `json:"ok,omitempty"`
+	Progress *transform.Event `json:"progress,omitempty"`
+	// seq is the position of the event in the job, kept when the progress events are dropped
+	seq int
+}
+
+// Status is the snapshot of a job
+type Status struct {
+	ID       string     `json:"id"`
+	Request  Request    `json:"request"`
+	Phase    Phase      `json:"phase"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Results  []Event    `json:"results,omitempty"`
+}
+
+// Job is a transform, check or rollback of containers
+type Job struct {
+	mu       sync.Mutex
+	seq      int
+	id       string
+	req      Request
+	phase    Phase
+	created  time.Time
+	started  time.Time
+	finished time.Time
+	// events are the phases and results of the job, and the progress events until it is finished
+	events []Event
+	// nextSeq is the seq of the next event
+	nextSeq int
+	// notify is closed and replaced when a new event comes
+	notify chan struct{}
+	// canceled is set once the job is requested to be canceled
+	canceled bool
+	// stop cancels the context of the running job
+	stop context.CancelFunc
+}
+
+func newJob(seq int, req Request) *Job {
+	j := &Job{
+		seq:     seq,
+		id:      strconv.Itoa(seq),
+		req:     req,
+		created: time.Now(),
+		notify:  make(chan struct{}),
+	}
+	j.emitLocked(Event{Time: j.created, Phase: PhasePending})
+	return j
+}
+
+// ID returns the ID of job
+func (j *Job) ID() string {
+	return j.id
+}
+
+// Status returns the snapshot of job
+func (j *Job) Status() Status {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st := Status{
+		ID:      j.id,
+		Request: j.req,
+		Phase:   j.phase,
+		Created: j.created,
+	}
+	if !j.started.IsZero() {
+		started := j.started
+		st.Started = &started
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		st.Finished = &finished
+	}
+	for _, ev := range j.events {
+		if ev.Phase == "" && ev.Progress == nil {
+			st.Results = append(st.Results, ev)
+		}
+	}
+	return st
+}
+
+// Events returns the events since the seq from, the seq to continue from, a channel closed
+// on the next event and whether the job is finished so that no more event will come
+func (j *Job) Events(from int) ([]Event, int, <-chan struct{}, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	idx := sort.Search(len(j.events), func(i int) bool {
+		return j.events[i].seq >= from
+	})
+	var evs []Event
+	if idx < len(j.events) {
+		evs = append(evs, j.events[idx:]...)
+	}
+	return evs, j.nextSeq, j.notify, j.finishedLocked()
+}
+
+func (j *Job) finishedLocked() bool {
+	return j.phase == PhaseSucceeded || j.phase == PhaseFailed || j.phase == PhaseCanceled
+}
+
+func (j *Job) emitLocked(ev Event) {
+	if ev.Phase != "" {
+		j.phase = ev.Phase
+	}
+	ev.seq = j.nextSeq
+	j.nextSeq++
+	j.events = append(j.events, ev)
+	if j.finishedLocked() {
+		j.compactLocked()
+	}
+	close(j.notify)
+	j.notify = make(chan struct{})
+}
+
+// compactLocked drops the progress events of the finished job, the phases and results are kept
+func (j *Job) compactLocked() {
+	kept := j.events[:0]
+	for _, ev := range j.events {
+		if ev.Progress == nil {
+			kept = append(kept, ev)
+		}
+	}
+	for i := len(kept); i < len(j.events); i++ {
+		j.events[i] = Event{}
+	}
+	j.events = kept
+}
+
+// finishedAt returns when the job finished and whether it is finished
+func (j *Job) finishedAt() (time.Time, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.finished, j.finishedLocked()
+}
+
+func (j *Job) emit(ev Event) {
+	j.mu.Lock()
+	j.emitLocked(ev)
+	j.mu.Unlock()
+}
+
+// setPhase moves the job to phase, the job is not changed any more once it is finished
+func (j *Job) setPhase(phase Phase) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.finishedLocked() {
+		return false
+	}
+	now := time.Now()
+	switch phase {
+	case PhaseRunning:
+		j.started = now
+	case PhaseSucceeded, PhaseFailed, PhaseCanceled:
+		j.finished = now
+	default:
+	}
+	j.emitLocked(Event{Time: now, Phase: phase})
+	return true
+}
+
+// cancel cancels the pending job at once, the running one is canceled through its context
+func (j *Job) cancel() error {
+	j.mu.Lock()
+	if j.finishedLocked() {
+		j.mu.Unlock()
+		return errJobFinished
+	}
+	j.canceled = true
+	phase, stop := j.phase, j.stop
+	j.mu.Unlock()
+
+	if phase == PhasePending {
+		j.setPhase(PhaseCanceled)
+		return nil
+	}
+	if stop != nil {
+		stop()
+	}
+	return nil
+}
+
+// Manager queues the jobs and runs them one by one
+type Manager struct {
+	mu      sync.Mutex
+	factory Factory
+	jobs    map[string]*Job
+	seq     int
+	queue   chan *Job
+	// maxFinished and finishedTTL limit the finished jobs kept in jobs
+	maxFinished int
+	finishedTTL time.Duration
+}
+
+// NewManager creates a job manager using factory to create the transformers
+func NewManager(factory Factory) *Manager {
+	return &Manager{
+		factory:     factory,
+		jobs:        make(map[string]*Job),
+		queue:       make(chan *Job, maxPendingJobs),
+		maxFinished: maxFinishedJobs,
+		finishedTTL: finishedJobTTL,
+	}
+}
+
+// Submit queues a job of req
+func (m *Manager) Submit(req Request) (*Job, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(time.Now())
+	m.seq++
+	j := newJob(m.seq, req)
+	select {
+	case m.queue <- j:
+	default:
+		m.seq--
+		return nil, errQueueFull
+	}
+	m.jobs[j.id] = j
+	logrus.Infof("job %s: %s %v submitted", j.id, req.Kind, req.IDs)
+	return j, nil
+}
+
+// prune drops the finished jobs beyond the retention
+func (m *Manager) prune() {
+	m.mu.Lock()
+	m.pruneLocked(time.Now())
+	m.mu.Unlock()
+}
+
+// pruneLocked drops the finished jobs expired at now, and the oldest ones beyond maxFinished
+func (m *Manager) pruneLocked(now time.Time) {
+	var finished []*Job
+	for id, j := range m.jobs {
+		end, ok := j.finishedAt()
+		if !ok {
+			continue
+		}
+		if now.Sub(end) > m.finishedTTL {
+			delete(m.jobs, id)
+			continue
+		}
+		finished = append(finished, j)
+	}
+	if len(finished) <= m.maxFinished {
+		return
+	}
+	sort.Slice(finished, func(i, k int) bool {
+		return finished[i].seq < finished[k].seq
+	})
+	for _, j := range finished[:len(finished)-m.maxFinished] {
+		delete(m.jobs, j.id)
+	}
+}
+
+// Get returns the job id
+func (m *Manager) Get(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, errJobNotFound
+	}
+	return j, nil
+}
+
+// List returns the status of all the jobs in the order of submission
+func (m *Manager) List() []Status {
+	m.mu.Lock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+	m.mu.Unlock()
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].seq < jobs[k].seq
+	})
+	sts := make([]Status, 0, len(jobs))
+	for _, j := range jobs {
+		sts = append(sts, j.Status())
+	}
+	return sts
+}
+
+// Cancel cancels the job id
+func (m *Manager) Cancel(id string) error {
+	j, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	return j.cancel()
+}
+
+// Run runs the queued jobs until ctx is done, the running job is canceled
+// along with ctx and Run returns after it is finished
+func (m *Manager) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-m.queue:
+			m.run(ctx, j)
+		}
+	}
+}
+
+func (m *Manager) run(ctx context.Context, j *Job) {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	j.mu.Lock()
+	j.stop = stop
+	j.mu.Unlock()
+	if !j.setPhase(PhaseRunning) {
+		// canceled before running
+		return
+	}
+	logrus.Infof("job %s: running", j.id)
+	ok := m.execute(ctx, j)
+
+	j.mu.Lock()
+	canceled := j.canceled
+	j.mu.Unlock()
+	phase := PhaseSucceeded
+	switch {
+	case canceled:
+		phase = PhaseCanceled
+	case !ok:
+		phase = PhaseFailed
+	default:
+	}
+	j.setPhase(phase)
+	logrus.Infof("job %s: %s", j.id, phase)
+	m.prune()
+}
+
+// execute runs the job by a new transformer and reports whether all the containers succeed
+func (m *Manager) execute(ctx context.Context, j *Job) bool {
+	engine, err := m.factory()
+	if err != nil {
+		j.emit(Event{Time: time.Now(), Msg: fmt.Sprintf("init transformer: %v", err)})
+		return false
+	}
+
+	if p, ok := engine.(transform.Publisher); ok {
+		events, unsubscribe := p.Events().Subscribe(maxProgressEvents)
+		forwarded := make(chan struct{})
+		go func() {
+			for ev := range events {
+				ev := ev
+				j.emit(Event{Time: ev.Time, Progress: &ev})
+			}
+			close(forwarded)
+		}()
+		defer func() {
+			unsubscribe()
+			<-forwarded
+		}()
+	}
+
+	retCh := make(chan transform.Result, maxResults)
+	switch j.req.Kind {
+	case KindCheck:
+		c, ok := engine.(transform.Checker)
+		if !ok {
+			j.emit(Event{Time: time.Now(), Msg: "check is not supported by the transformer"})
+			return false
+		}
+		go c.Check(ctx, j.req.IDs, j.req.All, retCh)
+	case KindRollback:
+		r, ok := engine.(transform.Reverter)
+		if !ok {
+			j.emit(Event{Time: time.Now(), Msg: "rollback is not supported by the transformer"})
+			return false
+		}
+		go r.Rollback(ctx, j.req.IDs, retCh)
+	default:
+		go engine.Transform(ctx, j.req.IDs, j.req.All, retCh)
+	}
+
+	allOk := true
+	for ret := range retCh {
+		allOk = allOk && ret.Ok
+		j.emit(Event{Time: time.Now(), Msg: ret.Msg, Ok: ret.Ok})
+	}
+	return allOk
+}
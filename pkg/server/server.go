@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultSocket is the default unix socket the server listens on
+	DefaultSocket = "/var/run/isula-transform.sock"
+
+	jobsPath     = "/jobs"
+	cancelAction = "cancel"
+	eventsAction = "events"
+)
+
+// Server serves the REST API of the job manager
+type Server struct {
+	manager *Manager
+	http    *http.Server
+}
+
+// errorResponse is the body of the failed requests
+type errorResponse struct {
+	Message string `json:"message"`
+}
+
+// New creates a server of manager
+func New(manager *Manager) *Server {
+	s := &Server{manager: manager}
+	s.http = &http.Server{Handler: s.Handler()}
+	return s
+}
+
+// Handler returns the http handler of the REST API, jobs are submitted and listed at /jobs,
+// /jobs/{id} is the status of a job, /jobs/{id}/cancel cancels it and /jobs/{id}/events
+// streams its events as JSON lines
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(jobsPath, s.handleJobs)
+	mux.HandleFunc(jobsPath+"/", s.handleJob)
+	return mux
+}
+
+// Serve listens on the unix socket and serves until Shutdown is called
+func (s *Server) Serve(socket string) error {
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return errors.Wrap(err, "create socket directory")
+	}
+	// the socket is left by the previous server
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "remove stale socket")
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		logrus.Errorf("listen on %s failed: %v", socket, err)
+		return errors.Wrap(err, "listen")
+	}
+	defer os.Remove(socket)
+	if err := os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		return errors.Wrap(err, "chmod socket")
+	}
+	logrus.Infof("serving on %s", socket)
+	if err := s.http.Serve(l); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops the server, the event streams are closed
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.http.Shutdown(ctx)
+}
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.manager.List())
+	case http.MethodPost:
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, errors.Wrap(err, "decode request"))
+			return
+		}
+		j, err := s.manager.Submit(req)
+		if err != nil {
+			code := http.StatusBadRequest
+			if err == errQueueFull {
+				code = http.StatusServiceUnavailable
+			}
+			writeError(w, code, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, j.Status())
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, jobsPath+"/"), "/")
+	if len(parts) > 2 || parts[0] == "" {
+		writeError(w, http.StatusNotFound, errors.Errorf("unknown path %s", r.URL.Path))
+		return
+	}
+	j, err := s.manager.Get(parts[0])
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	var action string
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+	method := http.MethodGet
+	if action == cancelAction {
+		method = http.MethodPost
+	}
+	if r.Method != method {
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	switch action {
+	case "":
+		writeJSON(w, http.StatusOK, j.Status())
+	case cancelAction:
+		if err := j.cancel(); err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, j.Status())
+	case eventsAction:
+		streamEvents(w, r, j)
+	default:
+		writeError(w, http.StatusNotFound, errors.Errorf("unknown path %s", r.URL.Path))
+	}
+}
+
+// streamEvents writes all the events of job, one JSON per line, until the job is finished
+func streamEvents(w http.ResponseWriter, r *http.Request, j *Job) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	next := 0
+	for {
+		evs, seq, notify, finished := j.Events(next)
+		for _, ev := range evs {
+			if err := enc.Encode(ev); err != nil {
+				return
+			}
+		}
+		next = seq
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if finished {
+			return
+		}
+		select {
+		case <-notify:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Warnf("write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Message: err.Error()})
+}
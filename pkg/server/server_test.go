@@ -0,0 +1,325 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/transform"
+)
+
+// fakeTransformer succeeds on the containers starting with "ok", the transformation
+// of the container "block" waits until its context is canceled
+type fakeTransformer struct {
+	transform.BaseTransformer
+}
+
+func (f *fakeTransformer) Init() error { return nil }
+
+func (f *fakeTransformer) results(ctx context.Context, prefix string, ids []string, retCh chan transform.Result) {
+	defer close(retCh)
+	for _, id := range ids {
+		f.Events().Publish(transform.Event{Type: transform.EventPhaseStarted, Container: id, Phase: prefix})
+		if id == "block" {
+			<-ctx.Done()
+			retCh <- transform.Result{Msg: prefix + " " + id + ": canceled"}
+			continue
+		}
+		ok := strings.HasPrefix(id, "ok")
+		retCh <- transform.Result{Ok: ok, Msg: prefix + " " + id}
+	}
+}
+
+func (f *fakeTransformer) Transform(ctx context.Context, ids []string, _ bool, retCh chan transform.Result) {
+	f.results(ctx, "transform", ids, retCh)
+}
+
+func (f *fakeTransformer) Check(ctx context.Context, ids []string, _ bool, retCh chan transform.Result) {
+	f.results(ctx, "check", ids, retCh)
+}
+
+// startTestServer serves a manager of fakeTransformer on a temporary socket
+func startTestServer(t *testing.T) (*http.Client, func()) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	socket := filepath.Join(tmpdir, "transform.sock")
+	manager := NewManager(func() (transform.Transformer, error) {
+		f := &fakeTransformer{}
+		f.EventBus = transform.NewEventBus()
+		return f, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	go manager.Run(ctx)
+	srv := New(manager)
+	go func() {
+		_ = srv.Serve(socket)
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(socket); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	return client, func() {
+		_ = srv.Shutdown(context.Background())
+		cancel()
+		os.RemoveAll(tmpdir)
+	}
+}
+
+func doRequest(client *http.Client, method, path string, body interface{}, resp interface{}) (int, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(method, "http://localhost"+path, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	httpResp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer httpResp.Body.Close()
+	if resp != nil {
+		err = json.NewDecoder(httpResp.Body).Decode(resp)
+	}
+	return httpResp.StatusCode, err
+}
+
+func readEvents(client *http.Client, id string) ([]Event, error) {
+	resp, err := client.Get("http://localhost/jobs/" + id + "/events")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var evs []Event
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			return nil, err
+		}
+		evs = append(evs, ev)
+	}
+	return evs, scanner.Err()
+}
+
+func eventsSummary(evs []Event) []string {
+	var sum []string
+	for _, ev := range evs {
+		if ev.Progress != nil {
+			continue
+		}
+		if ev.Phase != "" {
+			sum = append(sum, string(ev.Phase))
+		} else {
+			sum = append(sum, ev.Msg)
+		}
+	}
+	return sum
+}
+
+func TestServer_jobs(t *testing.T) {
+	client, cleanup := startTestServer(t)
+	defer cleanup()
+
+	Convey("TestServer_jobs", t, func() {
+		Convey("submit and stream events", func() {
+			var st Status
+			code, err := doRequest(client, http.MethodPost, "/jobs",
+				Request{Kind: KindTransform, IDs: []string{"ok1", "bad"}}, &st)
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, http.StatusCreated)
+
+			evs, err := readEvents(client, st.ID)
+			So(err, ShouldBeNil)
+			So(eventsSummary(evs), ShouldResemble, []string{
+				"pending", "running", "transform ok1", "transform bad", "failed",
+			})
+
+			code, err = doRequest(client, http.MethodGet, "/jobs/"+st.ID, nil, &st)
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, http.StatusOK)
+			So(st.Phase, ShouldEqual, PhaseFailed)
+			So(len(st.Results), ShouldEqual, 2)
+			So(st.Finished, ShouldNotBeNil)
+		})
+
+		Convey("check", func() {
+			var st Status
+			_, err := doRequest(client, http.MethodPost, "/jobs", Request{Kind: KindCheck, IDs: []string{"ok1"}}, &st)
+			So(err, ShouldBeNil)
+			evs, err := readEvents(client, st.ID)
+			So(err, ShouldBeNil)
+			So(eventsSummary(evs), ShouldResemble, []string{"pending", "running", "check ok1", "succeeded"})
+		})
+
+		Convey("rollback not supported", func() {
+			var st Status
+			_, err := doRequest(client, http.MethodPost, "/jobs", Request{Kind: KindRollback, IDs: []string{"ok1"}}, &st)
+			So(err, ShouldBeNil)
+			evs, err := readEvents(client, st.ID)
+			So(err, ShouldBeNil)
+			So(evs[len(evs)-1].Phase, ShouldEqual, PhaseFailed)
+		})
+
+		Convey("cancel", func() {
+			var running, pending Status
+			_, err := doRequest(client, http.MethodPost, "/jobs", Request{Kind: KindTransform, IDs: []string{"block"}}, &running)
+			So(err, ShouldBeNil)
+			_, err = doRequest(client, http.MethodPost, "/jobs", Request{Kind: KindTransform, All: true}, &pending)
+			So(err, ShouldBeNil)
+
+			var st Status
+			code, err := doRequest(client, http.MethodPost, "/jobs/"+pending.ID+"/cancel", nil, &st)
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, http.StatusAccepted)
+			So(st.Phase, ShouldEqual, PhaseCanceled)
+
+			code, err = doRequest(client, http.MethodPost, "/jobs/"+running.ID+"/cancel", nil, nil)
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, http.StatusAccepted)
+			evs, err := readEvents(client, running.ID)
+			So(err, ShouldBeNil)
+			So(evs[len(evs)-1].Phase, ShouldEqual, PhaseCanceled)
+
+			code, err = doRequest(client, http.MethodPost, "/jobs/"+running.ID+"/cancel", nil, nil)
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, http.StatusConflict)
+		})
+
+		Convey("list", func() {
+			var sts []Status
+			code, err := doRequest(client, http.MethodGet, "/jobs", nil, &sts)
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, http.StatusOK)
+			So(len(sts), ShouldBeGreaterThan, 0)
+			for i := 1; i < len(sts); i++ {
+				So(sts[i-1].Created.After(sts[i].Created), ShouldBeFalse)
+			}
+		})
+
+		Convey("bad requests", func() {
+			code, err := doRequest(client, http.MethodPost, "/jobs", Request{Kind: "unknown", All: true}, nil)
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, http.StatusBadRequest)
+			code, err = doRequest(client, http.MethodPost, "/jobs", Request{Kind: KindRollback, All: true}, nil)
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, http.StatusBadRequest)
+			code, err = doRequest(client, http.MethodGet, "/jobs/none", nil, nil)
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, http.StatusNotFound)
+			code, err = doRequest(client, http.MethodDelete, "/jobs", nil, nil)
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, http.StatusMethodNotAllowed)
+		})
+	})
+}
+
+func TestManager_retention(t *testing.T) {
+	Convey("TestManager_retention", t, func() {
+		manager := NewManager(func() (transform.Transformer, error) {
+			f := &fakeTransformer{}
+			f.EventBus = transform.NewEventBus()
+			return f, nil
+		})
+
+		Convey("progress events until finished", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go manager.Run(ctx)
+			j, err := manager.Submit(Request{Kind: KindTransform, IDs: []string{"block"}})
+			So(err, ShouldBeNil)
+			var progress int
+			for i := 0; i < 100 && progress == 0; i++ {
+				time.Sleep(10 * time.Millisecond)
+				evs, _, _, _ := j.Events(0)
+				for _, ev := range evs {
+					if ev.Progress != nil {
+						progress = ev.seq
+					}
+				}
+			}
+			So(progress, ShouldBeGreaterThan, 0)
+
+			So(manager.Cancel(j.ID()), ShouldBeNil)
+			var (
+				evs      []Event
+				finished bool
+			)
+			for i := 0; i < 100 && !finished; i++ {
+				time.Sleep(10 * time.Millisecond)
+				evs, _, _, finished = j.Events(0)
+			}
+			So(finished, ShouldBeTrue)
+			So(eventsSummary(evs), ShouldResemble, []string{"pending", "running", "transform block: canceled", "canceled"})
+			for _, ev := range evs {
+				So(ev.Progress, ShouldBeNil)
+			}
+			// the streams following the job continue after the dropped progress events
+			after, next, _, _ := j.Events(progress)
+			So(eventsSummary(after), ShouldResemble, []string{"transform block: canceled", "canceled"})
+			So(next, ShouldEqual, evs[len(evs)-1].seq+1)
+		})
+
+		Convey("finished jobs", func() {
+			// the jobs are canceled before running
+			manager.maxFinished = 2
+			var jobs []*Job
+			for i := 0; i < 4; i++ {
+				j, err := manager.Submit(Request{Kind: KindCheck, IDs: []string{"ok1"}})
+				So(err, ShouldBeNil)
+				So(manager.Cancel(j.ID()), ShouldBeNil)
+				jobs = append(jobs, j)
+			}
+			manager.prune()
+			for idx, j := range jobs {
+				_, err := manager.Get(j.ID())
+				if idx < 2 {
+					So(err, ShouldEqual, errJobNotFound)
+				} else {
+					So(err, ShouldBeNil)
+				}
+			}
+
+			manager.finishedTTL = time.Nanosecond
+			time.Sleep(time.Millisecond)
+			manager.prune()
+			So(manager.List(), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+	"isula.org/isula-transform/transform"
+)
+
+// modes of the progress display
+const (
+	progressAuto  = "auto"
+	progressTTY   = "tty"
+	progressPlain = "plain"
+	progressNone  = "none"
+
+	// progressBuffer is the number of events buffered for the display
+	progressBuffer = 1024
+	shortIDLen     = 12
+)
+
+// ctrProgress is the progress of a container shown in the live display
+type ctrProgress struct {
+	phase    string
+	start    time.Time
+	bytes    int64
+	total    int64
+	warnings int
+}
+
+// progressDisplay renders the progress events of the containers being transformed. On a terminal
+// each container has a line redrawn in place until its result comes, otherwise the events are
+// printed line by line
+type progressDisplay struct {
+	out   io.Writer
+	live  bool
+	order []string
+	ctrs  map[string]*ctrProgress
+	drawn int
+}
+
+// newProgressDisplay creates the display of mode on out, nil is returned if nothing is displayed
+func newProgressDisplay(out *os.File, mode string) (*progressDisplay, error) {
+	d := &progressDisplay{out: out, ctrs: make(map[string]*ctrProgress)}
+	switch mode {
+	case progressAuto:
+		if !isTerminal(out) {
+			return nil, nil
+		}
+		d.live = true
+	case progressTTY:
+		d.live = true
+	case progressPlain:
+	case progressNone:
+		return nil, nil
+	default:
+		return nil, errors.Errorf("unknown progress mode %s", mode)
+	}
+	return d, nil
+}
+
+func isTerminal(f *os.File) bool {
+	_, err := unix.IoctlGetTermios(int(f.Fd()), unix.TCGETS)
+	return err == nil
+}
+
+func shortID(id string) string {
+	if len(id) > shortIDLen {
+		return id[:shortIDLen]
+	}
+	return id
+}
+
+// handle updates the display with ev
+func (d *progressDisplay) handle(ev transform.Event) {
+	if !d.live {
+		if line := plainLine(ev); line != "" {
+			fmt.Fprintln(d.out, line)
+		}
+		return
+	}
+
+	p, ok := d.ctrs[ev.Container]
+	if !ok {
+		if ev.Type == transform.EventResult {
+			return
+		}
+		p = &ctrProgress{}
+		d.ctrs[ev.Container] = p
+		d.order = append(d.order, ev.Container)
+	}
+	switch ev.Type {
+	case transform.EventPhaseStarted:
+		p.phase, p.start = ev.Phase, ev.Time
+	case transform.EventBytesCopied:
+		p.bytes, p.total = ev.Bytes, ev.Total
+	case transform.EventWarning:
+		p.warnings++
+	case transform.EventRollbackStep:
+		p.phase, p.start = "rollback", ev.Time
+	case transform.EventResult:
+		d.remove(ev.Container)
+	default:
+	}
+	d.redraw()
+}
+
+// plainLine formats ev as a line, the intermediate bytes copied are not printed
+func plainLine(ev transform.Event) string {
+	id := shortID(ev.Container)
+	switch ev.Type {
+	case transform.EventPhaseStarted:
+		return fmt.Sprintf("%s: %s started", id, ev.Phase)
+	case transform.EventPhaseFinished:
+		if !ev.Ok {
+			return fmt.Sprintf("%s: %s failed after %s: %s", id, ev.Phase, ev.Duration.Round(time.Millisecond), ev.Msg)
+		}
+		return fmt.Sprintf("%s: %s finished in %s", id, ev.Phase, ev.Duration.Round(time.Millisecond))
+	case transform.EventBytesCopied:
+		if ev.Total > 0 && ev.Bytes >= ev.Total {
+			return fmt.Sprintf("%s: copied %s", id, units.HumanSize(float64(ev.Bytes)))
+		}
+	case transform.EventWarning:
+		return fmt.Sprintf("%s: warning: %s", id, ev.Msg)
+	case transform.EventRollbackStep:
+		return fmt.Sprintf("%s: rollback: %s", id, ev.Msg)
+	default:
+	}
+	return ""
+}
+
+func (d *progressDisplay) remove(id string) {
+	delete(d.ctrs, id)
+	for i := range d.order {
+		if d.order[i] == id {
+			d.order = append(d.order[:i], d.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// println prints line to w above the live lines
+func (d *progressDisplay) println(w io.Writer, line string) {
+	d.clear()
+	fmt.Fprintln(w, line)
+	d.redraw()
+}
+
+// clear erases the live lines
+func (d *progressDisplay) clear() {
+	if d.drawn > 0 {
+		fmt.Fprintf(d.out, "\033[%dA\033[J", d.drawn)
+		d.drawn = 0
+	}
+}
+
+func (d *progressDisplay) redraw() {
+	if !d.live {
+		return
+	}
+	d.clear()
+	var b strings.Builder
+	for _, id := range d.order {
+		p := d.ctrs[id]
+		fmt.Fprintf(&b, "%-12s %-9s", shortID(id), p.phase)
+		if p.total > 0 {
+			fmt.Fprintf(&b, " %s/%s (%d%%)", units.HumanSize(float64(p.bytes)),
+				units.HumanSize(float64(p.total)), p.bytes*100/p.total)
+		} else if p.bytes > 0 {
+			fmt.Fprintf(&b, " %s", units.HumanSize(float64(p.bytes)))
+		}
+		if !p.start.IsZero() {
+			fmt.Fprintf(&b, " %s", time.Since(p.start).Round(time.Second))
+		}
+		if p.warnings > 0 {
+			fmt.Fprintf(&b, " %d warning(s)", p.warnings)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprint(d.out, b.String())
+	d.drawn = len(d.order)
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+package docker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"isula.org/isula-transform/types"
+)
+
+const (
+	// aufsWhiteoutPrefix prefixes the name of the file hiding the one of lower branches
+	aufsWhiteoutPrefix = ".wh."
+	// aufsWhiteoutMetaPrefix prefixes the internal files of aufs, such as .wh..wh.plnk
+	aufsWhiteoutMetaPrefix = ".wh..wh."
+	// aufsOpaqueMarker marks its parent directory hiding the content of lower branches
+	aufsOpaqueMarker = ".wh..wh..opq"
+)
+
+// aufsSource gets the changes of the container created by docker aufs driver
+// from its read-write branch diff/<id>, whose union mount point is mnt/<id>
+type aufsSource struct{}
+
+func (as *aufsSource) open(_ context.Context, ctr *types.IsuladV2Config, oldRootFs string) (string, changeWalker, func(), error) {
+	branch := filepath.Join(filepath.Dir(filepath.Dir(oldRootFs)), "diff", filepath.Base(oldRootFs))
+	walk := func(fn changeFunc) error {
+		return layerWalk(branch, ctr.CommonConfig.MountPoints, aufsFormat{}, fn)
+	}
+	return branch, walk, func() {}, nil
+}
+
+// aufsFormat marks the deleted files by .wh. prefixed files and .wh..wh..opq markers
+type aufsFormat struct{}
+
+func (aufsFormat) classify(path string, fi os.FileInfo) (layerEntry, error) {
+	name := fi.Name()
+	switch {
+	case strings.HasPrefix(name, aufsWhiteoutMetaPrefix):
+		return layerInternal, nil
+	case strings.HasPrefix(name, aufsWhiteoutPrefix):
+		return layerWhiteout, nil
+	case fi.IsDir():
+		_, err := os.Lstat(filepath.Join(path, aufsOpaqueMarker))
+		if err == nil {
+			return layerOpaque, nil
+		}
+		if !os.IsNotExist(err) {
+			return layerFile, err
+		}
+	default:
+	}
+	return layerFile, nil
+}
+
+func (aufsFormat) hidden(path string) string {
+	return filepath.Join(filepath.Dir(path), strings.TrimPrefix(filepath.Base(path), aufsWhiteoutPrefix))
+}
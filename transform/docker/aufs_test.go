@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+package docker
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	. "github.com/google/go-cmp/cmp"
+	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/types"
+)
+
+func Test_aufsSource(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	mountID := "0123456789abcdef"
+	branch := filepath.Join(tmpdir, "aufs/diff", mountID)
+	for _, dir := range []string{".wh..wh.plnk", "etc", "opt/app"} {
+		if err := os.MkdirAll(filepath.Join(branch, dir), 0755); err != nil {
+			t.Skipf("prepare branch: %v", err)
+		}
+	}
+	for _, file := range []string{".wh..wh.aufs", "etc/conf", "etc/.wh.old", "opt/.wh..wh..opq", "opt/app/bin"} {
+		if err := ioutil.WriteFile(filepath.Join(branch, file), nil, 0644); err != nil {
+			t.Skipf("prepare branch: %v", err)
+		}
+	}
+
+	Convey("Test_aufsSource", t, func() {
+		as := &aufsSource{}
+		ctr := &types.IsuladV2Config{CommonConfig: &types.CommonConfig{ID: "aufstest"}}
+		srcRoot, walk, release, err := as.open(context.Background(), ctr, filepath.Join(tmpdir, "aufs/mnt", mountID))
+		So(err, ShouldBeNil)
+		defer release()
+		So(srcRoot, ShouldEqual, branch)
+
+		var got []container.ContainerChangeResponseItem
+		So(walk(func(change container.ContainerChangeResponseItem) error {
+			got = append(got, change)
+			return nil
+		}), ShouldBeNil)
+		So(Diff(got, []container.ContainerChangeResponseItem{
+			{Kind: metaItem, Path: "/etc"},
+			{Kind: delItem, Path: "/etc/old"},
+			{Kind: addItem, Path: "/etc/conf"},
+			{Kind: delItem, Path: "/opt"},
+			{Kind: metaItem, Path: "/opt"},
+			{Kind: metaItem, Path: "/opt/app"},
+			{Kind: addItem, Path: "/opt/app/bin"},
+		}), ShouldBeBlank)
+	})
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"isula.org/isula-transform/pkg/isulad"
+)
+
+const (
+	// defaultSystemdSlice is the default cgroup parent of docker and isulad with systemd cgroup driver
+	defaultSystemdSlice = "system.slice"
+	sliceSuffix         = ".slice"
+	// the prefixes of the systemd scopes of containers
+	dockerScopePrefix = "docker"
+	isuladScopePrefix = "isulad"
+)
+
+// cgroupMapper converts the cgroup parents of docker with cgroup driver docker
+// into the style of the cgroup driver of isulad
+type cgroupMapper struct {
+	docker string
+	isulad string
+	// unified is set when the host runs with cgroup v2 only
+	unified bool
+}
+
+func newCgroupMapper(docker, isulad string, unified bool) cgroupMapper {
+	return cgroupMapper{docker: docker, isulad: isulad, unified: unified}
+}
+
+// initCgroupMapper detects the cgroup driver of docker from its info and the cgroup version of
+// the host, the cgroup driver of docker is mapped to the one of isulad
+func (t *dockerTransformer) initCgroupMapper(info dockertypes.Info) error {
+	docker, iSulad := info.CgroupDriver, isulad.GetIsuladTool().CgroupDriver()
+	switch docker {
+	case isulad.CgroupDriverCgroupfs, isulad.CgroupDriverSystemd:
+	default:
+		return fmt.Errorf("unsupported cgroup driver of docker: %s", docker)
+	}
+	if docker != iSulad {
+		logrus.Infof("cgroup parents are converted from %s of docker to %s of isulad", docker, iSulad)
+	}
+	unified, err := isUnifiedCgroup(cgroupRoot)
+	if err != nil {
+		return errors.Wrap(err, "detect cgroup version")
+	}
+	if unified {
+		logrus.Info("host runs with cgroup v2, the resources of cgroup v1 only are removed")
+	}
+	t.cgroups = newCgroupMapper(docker, iSulad, unified)
+	return nil
+}
+
+func isSystemd(driver string) bool {
+	return driver == isulad.CgroupDriverSystemd
+}
+
+// defaultParent returns the default cgroup parent of isulad
+func (m cgroupMapper) defaultParent() string {
+	if isSystemd(m.isulad) {
+		return defaultSystemdSlice
+	}
+	return defaultCgroupDir
+}
+
+// parent returns the cgroup parent in isulad of container id from its cgroup path in docker, which is
+// <parent>/<id> with cgroupfs and <slice>:docker:<id> with systemd. The default parent of docker and
+// the paths not belonging to the container become the default parent of isulad
+func (m cgroupMapper) parent(dockerPath, id string) string {
+	var parent string
+	if isSystemd(m.docker) {
+		parts := strings.Split(dockerPath, ":")
+		if len(parts) != 3 || parts[1] != dockerScopePrefix || parts[2] != id || parts[0] == defaultSystemdSlice {
+			return m.defaultParent()
+		}
+		parent = parts[0]
+	} else {
+		if strings.HasPrefix(dockerPath, "/docker/") || !strings.HasSuffix(dockerPath, "/"+id) {
+			return m.defaultParent()
+		}
+		parent = strings.TrimSuffix(dockerPath, "/"+id)
+	}
+	return m.convertParent(parent)
+}
+
+// convertParent converts parent in the style of docker into the style of isulad
+func (m cgroupMapper) convertParent(parent string) string {
+	if parent == "" || isSystemd(m.docker) == isSystemd(m.isulad) {
+		return parent
+	}
+	if isSystemd(m.isulad) {
+		return pathToSlice(parent)
+	}
+	return expandSlice(parent)
+}
+
+// checkParent checks that parent is in the style of the cgroup driver of isulad, a slice
+// with systemd and an absolute path with cgroupfs
+func (m cgroupMapper) checkParent(parent string) error {
+	if isSystemd(m.isulad) {
+		if !strings.HasSuffix(parent, sliceSuffix) || strings.Contains(parent, "/") {
+			return fmt.Errorf("cgroup parent %q is not a systemd slice as required by cgroup driver %s of isulad", parent, m.isulad)
+		}
+		return nil
+	}
+	if !path.IsAbs(parent) {
+		return fmt.Errorf("cgroup parent %q is not an absolute path as required by cgroup driver %s of isulad", parent, m.isulad)
+	}
+	return nil
+}
+
+// path returns the cgroups path of container id under parent in the style of isulad
+func (m cgroupMapper) path(parent, id string) string {
+	if isSystemd(m.isulad) {
+		return parent + ":" + isuladScopePrefix + ":" + id
+	}
+	return path.Join(parent, id)
+}
+
+// expandSlice converts the systemd slice into its cgroupfs path, such as
+// a-b.slice into /a.slice/a-b.slice
+func expandSlice(slice string) string {
+	name := strings.TrimSuffix(slice, sliceSuffix)
+	if name == slice {
+		return path.Join("/", slice)
+	}
+	if name == "-" || name == "" {
+		return "/"
+	}
+	var (
+		expanded string
+		prefix   string
+	)
+	for _, component := range strings.Split(name, "-") {
+		expanded += "/" + prefix + component + sliceSuffix
+		prefix += component + "-"
+	}
+	return expanded
+}
+
+// pathToSlice converts the cgroupfs path into the slice at the same position of the hierarchy,
+// the path expanded from a slice such as /a.slice/a-b.slice becomes a-b.slice again
+func pathToSlice(cgroupPath string) string {
+	cleaned := strings.Trim(path.Clean(cgroupPath), "/")
+	if cleaned == "" || cleaned == "." {
+		return defaultSystemdSlice
+	}
+	components := strings.Split(cleaned, "/")
+	if last := components[len(components)-1]; strings.HasSuffix(last, sliceSuffix) {
+		return last
+	}
+	for idx, component := range components {
+		if strings.Contains(component, "-") {
+			logrus.Warnf("dash in cgroup %s is taken as the separator of slices", cgroupPath)
+		}
+		components[idx] = strings.TrimSuffix(component, sliceSuffix)
+	}
+	return strings.Join(components, "-") + sliceSuffix
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"fmt"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+	"isula.org/isula-transform/types"
+)
+
+const (
+	// cgroupRoot is where the cgroup hierarchies of the host are mounted
+	cgroupRoot = "/sys/fs/cgroup"
+
+	// the ranges of the cgroup v2 interface files the resources are written to
+	minBlkioWeight = 10
+	maxBlkioWeight = 1000
+	minCPUPeriod   = 1000
+	maxCPUPeriod   = 1000000
+	minCPUQuota    = 1000
+)
+
+// isUnifiedCgroup reports whether the host runs with cgroup v2 only
+func isUnifiedCgroup(root string) (bool, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(root, &st); err != nil {
+		return false, err
+	}
+	return st.Type == unix.CGROUP2_SUPER_MAGIC, nil
+}
+
+// adaptUnifiedResources removes the resources of s which can not be expressed by cgroup v2 and returns
+// the reasons. The runtime translates the rest into io.weight, memory.max, memory.low, memory.swap.max
+// and cpu.max, so the ones out of the ranges of these files are removed as well
+func adaptUnifiedResources(s *specs.Spec) []string {
+	if s.Linux == nil || s.Linux.Resources == nil {
+		return nil
+	}
+	var dropped []string
+	drop := func(format string, a ...interface{}) {
+		dropped = append(dropped, fmt.Sprintf(format, a...))
+	}
+	r := s.Linux.Resources
+	if mem := r.Memory; mem != nil {
+		if mem.Kernel != nil {
+			drop("kernel memory limit %d is not supported by cgroup v2", *mem.Kernel)
+			mem.Kernel = nil
+		}
+		if mem.KernelTCP != nil {
+			drop("kernel tcp memory limit %d is not supported by cgroup v2", *mem.KernelTCP)
+			mem.KernelTCP = nil
+		}
+		if mem.Swappiness != nil {
+			drop("memory swappiness %d is not supported by cgroup v2", *mem.Swappiness)
+			mem.Swappiness = nil
+		}
+		var limit int64
+		if mem.Limit != nil {
+			limit = *mem.Limit
+		}
+		if mem.Swap != nil && !validMemorySwap(*mem.Swap, limit) {
+			drop("memory+swap limit %d can not be converted to memory.swap.max without a memory limit below it",
+				*mem.Swap)
+			mem.Swap = nil
+		}
+	}
+	if cpu := r.CPU; cpu != nil {
+		if cpu.RealtimeRuntime != nil || cpu.RealtimePeriod != nil {
+			drop("cpu realtime runtime and period are not supported by cgroup v2")
+			cpu.RealtimeRuntime, cpu.RealtimePeriod = nil, nil
+		}
+		if cpu.Period != nil && !validCPUPeriod(*cpu.Period) {
+			drop("cpu period %d is out of the range of cpu.max [%d, %d]", *cpu.Period, minCPUPeriod, maxCPUPeriod)
+			cpu.Period = nil
+		}
+		if cpu.Quota != nil && !validCPUQuota(*cpu.Quota) {
+			drop("cpu quota %d is below the minimum %d of cpu.max", *cpu.Quota, minCPUQuota)
+			cpu.Quota = nil
+		}
+	}
+	if blkio := r.BlockIO; blkio != nil {
+		if blkio.Weight != nil && *blkio.Weight != 0 && !validBlkioWeight(*blkio.Weight) {
+			drop("blkio weight %d is out of the range [%d, %d] converted to io.weight", *blkio.Weight,
+				minBlkioWeight, maxBlkioWeight)
+			blkio.Weight = nil
+		}
+		if blkio.LeafWeight != nil {
+			drop("blkio leaf weight %d is not supported by cgroup v2", *blkio.LeafWeight)
+			blkio.LeafWeight = nil
+		}
+		// io.weight takes the weights of devices as "<major>:<minor> <weight>" as well
+		var devices []specs.LinuxWeightDevice
+		for _, dev := range blkio.WeightDevice {
+			if dev.LeafWeight != nil {
+				drop("blkio leaf weight %d of device %d:%d is not supported by cgroup v2", *dev.LeafWeight,
+					dev.Major, dev.Minor)
+				dev.LeafWeight = nil
+			}
+			if dev.Weight != nil && !validBlkioWeight(*dev.Weight) {
+				drop("blkio weight %d of device %d:%d is out of the range [%d, %d] converted to io.weight",
+					*dev.Weight, dev.Major, dev.Minor, minBlkioWeight, maxBlkioWeight)
+				dev.Weight = nil
+			}
+			if dev.Weight != nil {
+				devices = append(devices, dev)
+			}
+		}
+		blkio.WeightDevice = devices
+	}
+	return dropped
+}
+
+// validBlkioWeight reports whether the blkio weight can be converted to io.weight
+func validBlkioWeight(weight uint16) bool {
+	return weight >= minBlkioWeight && weight <= maxBlkioWeight
+}
+
+// validMemorySwap reports whether the memory+swap limit can be converted to memory.swap.max,
+// which is the swap limit without the memory limit
+func validMemorySwap(swap, limit int64) bool {
+	return swap == -1 || (limit > 0 && swap >= limit)
+}
+
+// validCPUPeriod reports whether the cpu period can be written to cpu.max, 0 means the default
+func validCPUPeriod(period uint64) bool {
+	return period == 0 || (period >= minCPUPeriod && period <= maxCPUPeriod)
+}
+
+// validCPUQuota reports whether the cpu quota can be written to cpu.max, not positive means no limit
+func validCPUQuota(quota int64) bool {
+	return quota <= 0 || quota >= minCPUQuota
+}
+
+// adaptUnifiedHostConfig clears the settings of h which can not be expressed by cgroup v2 in the same
+// way as adaptUnifiedResources, which reports them when the OCI config is transformed
+func adaptUnifiedHostConfig(h *types.IsuladHostConfig) {
+	h.KernelMemory = 0
+	h.CPURealtimePeriod, h.CPURealtimeRuntime = 0, 0
+	if h.MemorySwap != 0 && !validMemorySwap(h.MemorySwap, h.Memory) {
+		h.MemorySwap = 0
+	}
+	if h.CPUPeriod > 0 && !validCPUPeriod(uint64(h.CPUPeriod)) {
+		h.CPUPeriod = 0
+	}
+	if !validCPUQuota(h.CPUQuota) {
+		h.CPUQuota = 0
+	}
+	if h.BlkioWeight != 0 && !validBlkioWeight(h.BlkioWeight) {
+		h.BlkioWeight = 0
+	}
+	var devices []*types.BlockIOWeightDevice
+	for _, dev := range h.BlkioWeightDevice {
+		if dev != nil && validBlkioWeight(dev.Weight) {
+			devices = append(devices, dev)
+		}
+	}
+	h.BlkioWeightDevice = devices
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"testing"
+
+	. "github.com/google/go-cmp/cmp"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/types"
+)
+
+func Test_adaptUnifiedResources(t *testing.T) {
+	i64 := func(v int64) *int64 { return &v }
+	u64 := func(v uint64) *uint64 { return &v }
+	u16 := func(v uint16) *uint16 { return &v }
+	weightDevice := func(major, minor int64, weight, leafWeight *uint16) specs.LinuxWeightDevice {
+		var dev specs.LinuxWeightDevice
+		dev.Major, dev.Minor = major, minor
+		dev.Weight, dev.LeafWeight = weight, leafWeight
+		return dev
+	}
+
+	Convey("Test_adaptUnifiedResources", t, func() {
+		Convey("no resources", func() {
+			So(adaptUnifiedResources(&specs.Spec{}), ShouldBeEmpty)
+			So(adaptUnifiedResources(&specs.Spec{Linux: &specs.Linux{}}), ShouldBeEmpty)
+		})
+
+		Convey("supported resources are kept", func() {
+			r := &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Limit: i64(1 << 30), Reservation: i64(1 << 29), Swap: i64(2 << 30)},
+				CPU:    &specs.LinuxCPU{Shares: u64(512), Quota: i64(50000), Period: u64(100000)},
+				BlockIO: &specs.LinuxBlockIO{
+					Weight:       u16(500),
+					WeightDevice: []specs.LinuxWeightDevice{weightDevice(8, 0, u16(200), nil)},
+				},
+			}
+			So(adaptUnifiedResources(&specs.Spec{Linux: &specs.Linux{Resources: r}}), ShouldBeEmpty)
+			So(*r.BlockIO.WeightDevice[0].Weight, ShouldEqual, 200)
+			So(*r.Memory.Swap, ShouldEqual, 2<<30)
+			So(*r.CPU.Quota, ShouldEqual, 50000)
+			So(*r.BlockIO.Weight, ShouldEqual, 500)
+		})
+
+		Convey("v1 only resources are removed", func() {
+			r := &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{
+					Limit: i64(1 << 30), Swap: i64(1 << 29), Kernel: i64(1 << 20), KernelTCP: i64(1 << 20),
+					Swappiness: u64(60),
+				},
+				CPU: &specs.LinuxCPU{
+					Quota: i64(500), Period: u64(100), RealtimeRuntime: i64(950000), RealtimePeriod: u64(1000000),
+				},
+				BlockIO: &specs.LinuxBlockIO{
+					Weight: u16(5), LeafWeight: u16(500),
+					WeightDevice: []specs.LinuxWeightDevice{
+						weightDevice(8, 0, u16(5000), nil),
+						weightDevice(8, 16, u16(300), u16(300)),
+						weightDevice(8, 32, nil, u16(300)),
+					},
+				},
+			}
+			dropped := adaptUnifiedResources(&specs.Spec{Linux: &specs.Linux{Resources: r}})
+			So(dropped, ShouldHaveLength, 12)
+			So(r.BlockIO.WeightDevice, ShouldResemble, []specs.LinuxWeightDevice{weightDevice(8, 16, u16(300), nil)})
+			r.BlockIO.WeightDevice = nil
+			So(Diff(r, &specs.LinuxResources{
+				Memory:  &specs.LinuxMemory{Limit: i64(1 << 30)},
+				CPU:     &specs.LinuxCPU{},
+				BlockIO: &specs.LinuxBlockIO{},
+			}), ShouldBeBlank)
+		})
+
+		Convey("swap without memory limit", func() {
+			r := &specs.LinuxResources{Memory: &specs.LinuxMemory{Swap: i64(1 << 30)}}
+			So(adaptUnifiedResources(&specs.Spec{Linux: &specs.Linux{Resources: r}}), ShouldHaveLength, 1)
+			So(r.Memory.Swap, ShouldBeNil)
+			r.Memory.Swap = i64(-1)
+			So(adaptUnifiedResources(&specs.Spec{Linux: &specs.Linux{Resources: r}}), ShouldBeEmpty)
+		})
+
+		Convey("host config follows the OCI config", func() {
+			for _, h := range []*types.IsuladHostConfig{
+				{Memory: 1 << 30, MemorySwap: 1 << 29, KernelMemory: 1 << 20, CPUQuota: 500, CPUPeriod: 100,
+					CPURealtimePeriod: 1000000, CPURealtimeRuntime: 950000, BlkioWeight: 5},
+				{MemorySwap: 1 << 30, CPUPeriod: 2000000, BlkioWeight: 2000},
+				{Memory: 1 << 30, MemorySwap: 2 << 30, CPUQuota: 50000, CPUPeriod: 100000, BlkioWeight: 500},
+				{MemorySwap: -1, CPUQuota: -1},
+			} {
+				r := &specs.LinuxResources{
+					Memory:  &specs.LinuxMemory{Kernel: i64(h.KernelMemory)},
+					CPU:     &specs.LinuxCPU{Quota: i64(h.CPUQuota), Period: u64(uint64(h.CPUPeriod))},
+					BlockIO: &specs.LinuxBlockIO{Weight: u16(h.BlkioWeight)},
+				}
+				if h.Memory != 0 {
+					r.Memory.Limit = i64(h.Memory)
+				}
+				if h.MemorySwap != 0 {
+					r.Memory.Swap = i64(h.MemorySwap)
+				}
+				if h.CPURealtimePeriod != 0 {
+					r.CPU.RealtimePeriod, r.CPU.RealtimeRuntime = u64(uint64(h.CPURealtimePeriod)), i64(h.CPURealtimeRuntime)
+				}
+				adaptUnifiedResources(&specs.Spec{Linux: &specs.Linux{Resources: r}})
+				adaptUnifiedHostConfig(h)
+				value := func(p *int64) int64 {
+					if p == nil {
+						return 0
+					}
+					return *p
+				}
+				So(h.KernelMemory, ShouldEqual, value(r.Memory.Kernel))
+				So(h.MemorySwap, ShouldEqual, value(r.Memory.Swap))
+				So(h.CPUQuota, ShouldEqual, value(r.CPU.Quota))
+				So(h.CPURealtimeRuntime, ShouldEqual, value(r.CPU.RealtimeRuntime))
+				So(r.CPU.RealtimePeriod, ShouldBeNil)
+				if r.CPU.Period == nil {
+					So(h.CPUPeriod, ShouldEqual, 0)
+				} else {
+					So(h.CPUPeriod, ShouldEqual, *r.CPU.Period)
+				}
+				if r.BlockIO.Weight == nil {
+					So(h.BlkioWeight, ShouldEqual, 0)
+				} else {
+					So(h.BlkioWeight, ShouldEqual, *r.BlockIO.Weight)
+				}
+			}
+		})
+
+		Convey("host config", func() {
+			h := &types.IsuladHostConfig{
+				KernelMemory: 1 << 20, CPURealtimePeriod: 1000000, CPURealtimeRuntime: 950000,
+				BlkioWeightDevice: []*types.BlockIOWeightDevice{{Path: "/dev/sda", Weight: 5}, {Path: "/dev/sdb", Weight: 300}},
+				Memory:            1 << 30,
+			}
+			adaptUnifiedHostConfig(h)
+			So(Diff(h, &types.IsuladHostConfig{
+				BlkioWeightDevice: []*types.BlockIOWeightDevice{{Path: "/dev/sdb", Weight: 300}},
+				Memory:            1 << 30,
+			}), ShouldBeBlank)
+		})
+	})
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/pkg/isulad"
+)
+
+func Test_cgroupMapper(t *testing.T) {
+	id := ctrID('a')
+	cgroupfs, systemd := isulad.CgroupDriverCgroupfs, isulad.CgroupDriverSystemd
+
+	Convey("Test_cgroupMapper", t, func() {
+		Convey("cgroupfs to cgroupfs", func() {
+			m := newCgroupMapper(cgroupfs, cgroupfs, false)
+			So(m.parent("/docker/"+id, id), ShouldEqual, "/isulad")
+			So(m.parent("/test/"+id, id), ShouldEqual, "/test")
+			So(m.parent("/test", id), ShouldEqual, "/isulad")
+			So(m.path("/test", id), ShouldEqual, "/test/"+id)
+			So(m.checkParent("/test"), ShouldBeNil)
+			So(m.checkParent("web.slice"), ShouldBeError)
+		})
+
+		Convey("systemd to systemd", func() {
+			m := newCgroupMapper(systemd, systemd, false)
+			So(m.parent("system.slice:docker:"+id, id), ShouldEqual, "system.slice")
+			So(m.parent("web.slice:docker:"+id, id), ShouldEqual, "web.slice")
+			So(m.parent("web.slice:other:"+id, id), ShouldEqual, "system.slice")
+			So(m.path("web.slice", id), ShouldEqual, "web.slice:isulad:"+id)
+			So(m.checkParent("web.slice"), ShouldBeNil)
+			So(m.checkParent("/test"), ShouldBeError)
+			So(m.checkParent("/apps.slice/web.slice"), ShouldBeError)
+		})
+
+		Convey("systemd to cgroupfs", func() {
+			m := newCgroupMapper(systemd, cgroupfs, false)
+			So(m.parent("system.slice:docker:"+id, id), ShouldEqual, "/isulad")
+			So(m.parent("apps-web.slice:docker:"+id, id), ShouldEqual, "/apps.slice/apps-web.slice")
+			So(m.convertParent("-.slice"), ShouldEqual, "/")
+			So(m.convertParent(""), ShouldEqual, "")
+		})
+
+		Convey("cgroupfs to systemd", func() {
+			m := newCgroupMapper(cgroupfs, systemd, false)
+			So(m.parent("/docker/"+id, id), ShouldEqual, "system.slice")
+			So(m.parent("/apps/web/"+id, id), ShouldEqual, "apps-web.slice")
+			So(m.parent("/apps.slice/apps-web.slice/"+id, id), ShouldEqual, "apps-web.slice")
+			So(m.convertParent("/"), ShouldEqual, "system.slice")
+			So(m.path(m.defaultParent(), id), ShouldEqual, "system.slice:isulad:"+id)
+		})
+
+		Convey("init from docker info", func() {
+			_ = isulad.InitIsuladTool(&isulad.DaemonConfig{})
+			dt := &dockerTransformer{client: &fakeDockerClient{cgroupDriver: systemd}}
+			info, err := dt.dockerInfo()
+			So(err, ShouldBeNil)
+			So(dt.initCgroupMapper(info), ShouldBeNil)
+			So(dt.cgroups.docker, ShouldEqual, systemd)
+			So(dt.cgroups.isulad, ShouldEqual, cgroupfs)
+			So(dt.initCgroupMapper(dockertypes.Info{CgroupDriver: "none"}), ShouldBeError)
+			dt.client = &fakeDockerClient{offline: true}
+			_, err = dt.dockerInfo()
+			So(err, ShouldBeError)
+		})
+	})
+}
@@ -0,0 +1,483 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"isula.org/isula-transform/transform"
+	"isula.org/isula-transform/types"
+	"isula.org/isula-transform/utils"
+)
+
+// diskBlockSize is the unit of the number of blocks reported by stat
+const diskBlockSize = 512
+
+type changeFunc func(change container.ContainerChangeResponseItem) error
+
+// changeWalker calls fn with each change need to be migrated, parents come before their children
+type changeWalker func(fn changeFunc) error
+
+// changeSource provides the changes of the read-write layer of a docker container
+type changeSource interface {
+	// open returns the root holding the content of the container, a walker of the
+	// changes need to be migrated and a func to release the resources used by the walker
+	open(ctx context.Context, ctr *types.IsuladV2Config, oldRootFs string) (string, changeWalker, func(), error)
+}
+
+// changeSources maps the docker storage driver to the source of the changes
+type changeSources map[transform.StorageType]changeSource
+
+func newChangeSources(graphRoot string, client dockerClient, local *dmLocalDiff) changeSources {
+	return changeSources{
+		transform.Overlay2:     &overlaySource{},
+		transform.DeviceMapper: &dmSource{client: client, local: local},
+		transform.Aufs:         &aufsSource{},
+		transform.Vfs:          &vfsSource{graphRoot: graphRoot},
+	}
+}
+
+// open opens the source of driver, the walker returned stops once ctx is done
+func (cs changeSources) open(ctx context.Context, driver transform.StorageType, ctr *types.IsuladV2Config,
+	oldRootFs string) (string, changeWalker, func(), error) {
+	src, ok := cs[driver]
+	if !ok {
+		return "", nil, nil, fmt.Errorf("unsupported docker storage driver: %s", driver)
+	}
+	root, walk, release, err := src.open(ctx, ctr, oldRootFs)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return root, func(fn changeFunc) error {
+		return walk(func(change container.ContainerChangeResponseItem) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return fn(change)
+		})
+	}, release, nil
+}
+
+// originDriver returns the storage driver used by docker for ctr,
+// the same driver as iSulad is assumed when it is unknown
+func originDriver(ctr *types.IsuladV2Config, def transform.StorageType) transform.StorageType {
+	if ctr.CommonConfig.OriginDriver == "" {
+		return def
+	}
+	return transform.StorageType(ctr.CommonConfig.OriginDriver)
+}
+
+// layerEntry is the kind of a file in the layer of a union filesystem
+type layerEntry int
+
+const (
+	// layerFile is a file added or changed in the layer
+	layerFile layerEntry = iota
+	// layerWhiteout hides a file of the lower layers
+	layerWhiteout
+	// layerOpaque is a directory hiding the content of the lower layers
+	layerOpaque
+	// layerInternal is the metadata of graph driver which should not be migrated
+	layerInternal
+)
+
+// layerFormat tells how a graph driver records the deletions in a layer
+type layerFormat interface {
+	// classify returns the kind of the file at path
+	classify(path string, fi os.FileInfo) (layerEntry, error)
+	// hidden returns the path hidden by the whiteout at path
+	hidden(path string) string
+}
+
+// layerWalk converts the layer at root into changes, whiteouts become deletions
+// and opaque directories are deleted before being created again
+func layerWalk(root string, mounts map[string]types.Mount, format layerFormat, fn changeFunc) error {
+	return filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel := strings.TrimPrefix(path, root)
+		if _, ok := mounts[rel]; ok {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		entry, err := format.classify(path, fi)
+		if err != nil {
+			return err
+		}
+		switch entry {
+		case layerInternal:
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		case layerWhiteout:
+			return fn(container.ContainerChangeResponseItem{Kind: delItem, Path: format.hidden(rel)})
+		case layerOpaque:
+			if err := fn(container.ContainerChangeResponseItem{Kind: delItem, Path: rel}); err != nil {
+				return err
+			}
+		default:
+		}
+		if fi.IsDir() {
+			return fn(container.ContainerChangeResponseItem{Kind: metaItem, Path: rel})
+		}
+		return fn(container.ContainerChangeResponseItem{Kind: addItem, Path: rel})
+	})
+}
+
+// readLayerID reads the mount-id or init-id of container from the layer database of docker
+func readLayerID(graphRoot string, driver transform.StorageType, id, name string) (string, error) {
+	path := filepath.Join(graphRoot, "image", string(driver), "layerdb", "mounts", id, name)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "read %s of container", name)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// privateXattrs returns the prefixes of the xattrs private to the storage driver, which mean
+// nothing once the files leave it for another driver
+func privateXattrs(driver transform.StorageType) []string {
+	if driver == transform.Overlay2 {
+		return []string{overlayXattrPrefix}
+	}
+	return nil
+}
+
+// applyChanges migrates the changes from srcRoot to the complete rootfs destRoot, the owners of
+// the migrated files are shifted by owners if it is not nil and they are labeled with the SELinux
+// label if it is not empty, the files of the image under destRoot are left as they are. The xattrs
+// private to the source driver, whose names start with any of privXattrs, are not migrated
+func applyChanges(ctx context.Context, srcRoot, destRoot string, walk changeWalker,
+	limiter *utils.RateLimiter, owners *idShift, label string, privXattrs ...string) error {
+	var dirs, copied []string
+	links := make(linkTracker)
+	err := walk(func(change container.ContainerChangeResponseItem) error {
+		dest := destRoot + change.Path
+		switch change.Kind {
+		case metaItem:
+			// parents come before their children, create the directory here
+			// and apply the metadata after all the children are migrated
+			if err := makeDir(dest); err != nil {
+				return err
+			}
+			dirs = append(dirs, change.Path)
+		case addItem, changeItem:
+			// the link shares the inode of a copied file, which is shifted and labeled already
+			if linked, err := links.link(srcRoot+change.Path, dest); err != nil || linked {
+				return err
+			}
+			copied = append(copied, change.Path)
+			if err := copyChange(ctx, srcRoot+change.Path, dest, limiter); err != nil {
+				return err
+			}
+			// the copies keep the private xattrs of the source driver
+			for _, prefix := range privXattrs {
+				if err := utils.RemoveXattrs(dest, prefix); err != nil {
+					logrus.Errorf("remove xattrs %s* of %s failed: %v", prefix, dest, err)
+					return err
+				}
+			}
+		case delItem:
+			if err := os.RemoveAll(dest); err != nil {
+				logrus.Errorf("remove %s failed: %v", dest, err)
+				return err
+			}
+		default:
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// the label of the directories of the image is kept, it is set by relabelChanges if any
+	skipped := append([]string{utils.SELinuxXattr}, privXattrs...)
+	if err := applyDirsMetadata(srcRoot, destRoot, dirs, skipped...); err != nil {
+		return err
+	}
+	if err := shiftOwners(owners, destRoot, copied, dirs); err != nil {
+		return err
+	}
+	return relabelChanges(destRoot, label, copied, dirs)
+}
+
+// relabelChanges labels the copied paths and their children and the dirs under root with label
+func relabelChanges(root, label string, copied, dirs []string) error {
+	for _, p := range copied {
+		if err := utils.Relabel(root+p, label); err != nil {
+			logrus.Errorf("relabel copied files failed: %v", err)
+			return err
+		}
+	}
+	for _, dir := range dirs {
+		if err := utils.SetLabel(root+dir, label); err != nil {
+			logrus.Errorf("relabel directories failed: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// shiftOwners shifts the owners of the copied paths and their children and of dirs under root
+func shiftOwners(owners *idShift, root string, copied, dirs []string) error {
+	if err := owners.shiftTrees(root, copied...); err != nil {
+		logrus.Errorf("shift owners of copied files failed: %v", err)
+		return err
+	}
+	if err := owners.shiftDirs(root, dirs); err != nil {
+		logrus.Errorf("shift owners of directories failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// makeDir creates the directory dest, replacing the non-directory file at dest
+func makeDir(dest string) error {
+	if fi, err := os.Lstat(dest); err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		if err := os.Remove(dest); err != nil {
+			logrus.Errorf("remove %s failed: %v", dest, err)
+			return err
+		}
+	}
+	if err := os.Mkdir(dest, 0700); err != nil && !os.IsExist(err) {
+		logrus.Errorf("create directory %s failed: %v", dest, err)
+		return err
+	}
+	return nil
+}
+
+// copyPath copies src into the directory destDir as cp -ra does, the data of src is transferred
+// through a tar stream throttled by limiter if it is not nil. The copy is killed once ctx is done
+func copyPath(ctx context.Context, src, destDir string, limiter *utils.RateLimiter) error {
+	if limiter == nil {
+		return exec.CommandContext(ctx, "cp", "-ra", src, destDir).Run()
+	}
+
+	tarOpts := []string{"--numeric-owner", "--xattrs", "--xattrs-include=*"}
+	pack := exec.CommandContext(ctx, "tar", append(tarOpts, "--sparse", "-C", filepath.Dir(src),
+		"-cf", "-", filepath.Base(src))...)
+	unpack := exec.CommandContext(ctx, "tar", append(tarOpts, "--same-owner", "-p", "-C", destDir, "-xf", "-")...)
+	stdout, err := pack.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	unpack.Stdin = limiter.Reader(stdout)
+	if err := pack.Start(); err != nil {
+		return errors.Wrapf(err, "pack %s", src)
+	}
+	if err := unpack.Run(); err != nil {
+		// nobody reads the stream any more, stop the packing
+		_ = pack.Process.Kill()
+		_ = pack.Wait()
+		return errors.Wrapf(err, "unpack %s to %s", src, destDir)
+	}
+	return errors.Wrapf(pack.Wait(), "pack %s", src)
+}
+
+// copyChange copies src to dest as a whole, the existing dest is
+// replaced unless both of src and dest are directories
+func copyChange(ctx context.Context, src, dest string, limiter *utils.RateLimiter) error {
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		logrus.Errorf("stat %s failed: %v", src, err)
+		return err
+	}
+	if destInfo, err := os.Lstat(dest); err == nil && !(srcInfo.IsDir() && destInfo.IsDir()) {
+		if err := os.RemoveAll(dest); err != nil {
+			logrus.Errorf("remove %s failed: %v", dest, err)
+			return err
+		}
+	}
+	if err := copyPath(ctx, src, filepath.Dir(dest), limiter); err != nil {
+		logrus.Errorf("copy %s to %s failed: %v", src, dest, err)
+		return err
+	}
+	return nil
+}
+
+// applyDirsMetadata applies the metadata of dirs from srcRoot to destRoot, children first, so that
+// the modification time of the parents is not changed by their children. The xattrs starting with
+// one of skippedXattrs are left as they are in destRoot
+func applyDirsMetadata(srcRoot, destRoot string, dirs []string, skippedXattrs ...string) error {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := utils.CopyMetadata(srcRoot+dirs[i], destRoot+dirs[i], skippedXattrs...); err != nil {
+			logrus.Errorf("apply metadata of %s failed: %v", dirs[i], err)
+			return err
+		}
+	}
+	return nil
+}
+
+// changesSize returns the disk usage of the files added or changed by the changes walked from srcRoot
+func changesSize(srcRoot string, walk changeWalker) (int64, error) {
+	var size int64
+	usage := newDiskUsage()
+	err := walk(func(change container.ContainerChangeResponseItem) error {
+		if change.Kind != addItem && change.Kind != changeItem {
+			return nil
+		}
+		n, err := usage.add(srcRoot + change.Path)
+		size += n
+		return err
+	})
+	return size, err
+}
+
+// fileID identifies a file by its device and inode
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// linkedFileID returns the id of fi if it is a regular file with more than one hard link
+func linkedFileID(fi os.FileInfo) (fileID, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || !fi.Mode().IsRegular() || st.Nlink < 2 {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: st.Ino}, true
+}
+
+// diskUsage sums the disk usage of paths, a file with several hard links counts only once
+type diskUsage struct {
+	seen map[fileID]bool
+}
+
+func newDiskUsage() *diskUsage {
+	return &diskUsage{seen: make(map[fileID]bool)}
+}
+
+// add returns the disk usage of path and its children which are not counted before
+func (u *diskUsage) add(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if id, ok := linkedFileID(fi); ok {
+			if u.seen[id] {
+				return nil
+			}
+			u.seen[id] = true
+		}
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			size += st.Blocks * diskBlockSize
+		} else {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// linkTracker keeps the hard links between the changed files, which are copied one by one,
+// it maps the files with several hard links to their first copy
+type linkTracker map[fileID]string
+
+// link hard links dest to the copy of the file src if another link of src is copied before,
+// otherwise dest is recorded as the copy of src and false is returned
+func (l linkTracker) link(src, dest string) (bool, error) {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		logrus.Errorf("stat %s failed: %v", src, err)
+		return false, err
+	}
+	id, ok := linkedFileID(fi)
+	if !ok {
+		return false, nil
+	}
+	first, ok := l[id]
+	if !ok {
+		l[id] = dest
+		return false, nil
+	}
+	if err := os.RemoveAll(dest); err != nil {
+		logrus.Errorf("remove %s failed: %v", dest, err)
+		return false, err
+	}
+	if err := os.Link(first, dest); err != nil {
+		logrus.Errorf("link %s to %s failed: %v", dest, first, err)
+		return false, err
+	}
+	return true, nil
+}
+
+// verifyChanges checks that the changes walked from srcRoot are migrated to destRoot with their
+// owners shifted by owners, checkDeleted makes sure that the deleted paths are invisible under destRoot.
+// The xattrs private to the source driver, whose names start with any of privXattrs, are not compared
+func verifyChanges(srcRoot, destRoot string, walk changeWalker, owners *idShift,
+	checkDeleted func(root string, paths []string) error, privXattrs ...string) error {
+	var copied []string
+	dirs := make(manifest)
+	deleted := make(map[string]bool)
+	err := walk(func(change container.ContainerChangeResponseItem) error {
+		switch change.Kind {
+		case metaItem:
+			entry, err := newManifestEntry(srcRoot, change.Path)
+			if err != nil {
+				return err
+			}
+			dirs[entry.Path] = entry
+		case addItem, changeItem:
+			copied = append(copied, change.Path)
+		case delItem:
+			deleted[change.Path] = true
+			return nil
+		default:
+		}
+		// the path is deleted and then recreated
+		delete(deleted, change.Path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	m, err := buildManifest(srcRoot, copied...)
+	if err != nil {
+		return err
+	}
+	for path, entry := range dirs {
+		m[path] = entry
+	}
+	removed := make([]string, 0, len(deleted))
+	for path := range deleted {
+		removed = append(removed, path)
+	}
+	sort.Strings(removed)
+	owners.shiftManifest(m)
+	logrus.Debugf("verify %d items, %d removed", len(m), len(removed))
+	if err := m.compare(destRoot, privXattrs...); err != nil {
+		return err
+	}
+	return checkDeleted(destRoot, removed)
+}
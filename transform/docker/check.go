@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"isula.org/isula-transform/pkg/isulad"
+	"isula.org/isula-transform/transform"
+	"isula.org/isula-transform/types"
+	"isula.org/isula-transform/utils"
+)
+
+// Check checks whether the containers can be transformed in the same way as Transform
+// selects and groups them, nothing is changed on either docker or isulad side
+func (t *dockerTransformer) Check(ctx context.Context, ids []string, all bool, retCh chan transform.Result) {
+	defer close(retCh)
+	if all {
+		t.ctrs.Range(func(k, _ interface{}) bool {
+			id, _ := k.(string)
+			ids = append(ids, id)
+			return true
+		})
+	}
+
+	ids, results := t.selectContainers(ids, all)
+	groups, planResults := t.planGroups(ids, !t.noDeps)
+	for _, ret := range append(results, planResults...) {
+		retCh <- ret
+	}
+	for _, g := range groups {
+		for _, id := range g.ids {
+			err := g.err
+			if err == nil {
+				err = ctx.Err()
+			}
+			if err == nil {
+				err = t.checkContainer(id)
+			}
+			if err != nil {
+				retCh <- transform.Result{Msg: fmt.Sprintf("check %s: %v", id, err)}
+				continue
+			}
+			retCh <- transform.Result{Ok: true, Msg: fmt.Sprintf("check %s: ok", id)}
+		}
+	}
+}
+
+// checkContainer checks the configs, state and storage driver of container id and
+// the name it would get in isulad
+func (t *dockerTransformer) checkContainer(id string) error {
+	cfg, err := t.loadV2Config(id)
+	if err != nil {
+		return errors.Wrap(err, "load container config")
+	}
+	if _, err := t.loadRefs(id); err != nil {
+		return errors.Wrap(err, "load host config")
+	}
+	ociPath := filepath.Join(t.StateRoot, "containerd/daemon", containerdRuntime, containerdNameSpace, id, types.Ociconfig)
+	if err := utils.CheckFileValid(ociPath); err != nil {
+		return errors.Wrap(err, "container is not running")
+	}
+	switch transform.StorageType(cfg.Driver) {
+	case transform.Overlay2, transform.DeviceMapper, transform.Aufs, transform.Vfs:
+	default:
+		return fmt.Errorf("unsupported docker storage driver: %s", cfg.Driver)
+	}
+
+	iSulad := isulad.GetIsuladTool()
+	if _, err := os.Stat(filepath.Join(iSulad.GetRuntimePath(), id)); err == nil {
+		return fmt.Errorf("container has been or is being transformed")
+	}
+	if _, err := t.nameAlloc.peek(id, strings.TrimPrefix(cfg.Name, "/")); err != nil {
+		return err
+	}
+	return nil
+}
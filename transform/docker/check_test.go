@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/types"
+)
+
+func Test_dockerTransformer_checkContainer(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	dt := getTestDockerTransformer(tmpdir)
+	for _, c := range []byte{'a', 'b'} {
+		if err := prepareDepsTestContainer(dt.GraphRoot, ctrID(c), string(c), `{}`); err != nil {
+			t.Skipf("prepare container: %v", err)
+		}
+	}
+	v2Cfg := fmt.Sprintf(`{"ID":"%s","Name":"/b","Driver":"btrfs"}`, ctrID('b'))
+	if err := ioutil.WriteFile(filepath.Join(dt.GraphRoot, "containers", ctrID('b'), types.V2config),
+		[]byte(v2Cfg), 0600); err != nil {
+		t.Skipf("prepare container: %v", err)
+	}
+	bundle := filepath.Join(dt.StateRoot, "containerd/daemon", containerdRuntime, containerdNameSpace, ctrID('b'))
+	if err := os.MkdirAll(bundle, 0700); err != nil {
+		t.Skipf("prepare bundle: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(bundle, types.Ociconfig), []byte(`{}`), 0600); err != nil {
+		t.Skipf("prepare bundle: %v", err)
+	}
+
+	Convey("Test_dockerTransformer_checkContainer", t, func() {
+		Convey("not running", func() {
+			err := dt.checkContainer(ctrID('a'))
+			So(err, ShouldBeError)
+			So(err.Error(), ShouldContainSubstring, "container is not running")
+		})
+
+		Convey("unsupported driver", func() {
+			err := dt.checkContainer(ctrID('b'))
+			So(err, ShouldBeError)
+			So(err.Error(), ShouldContainSubstring, "unsupported docker storage driver: btrfs")
+		})
+
+		Convey("missing container", func() {
+			So(dt.checkContainer(ctrID('c')), ShouldBeError)
+		})
+	})
+}
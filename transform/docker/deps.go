@@ -0,0 +1,368 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"isula.org/isula-transform/pkg/isulad"
+	"isula.org/isula-transform/transform"
+	"isula.org/isula-transform/utils"
+)
+
+// containerModePrefix prefixes the namespace mode sharing the namespace of another container
+const containerModePrefix = "container:"
+
+// dockerRefs maps the fields of docker hostconfig.json referring to other containers
+type dockerRefs struct {
+	NetworkMode string
+	IpcMode     string
+	PidMode     string
+	UTSMode     string
+	UsernsMode  string
+	VolumesFrom []string
+	Links       []string
+}
+
+// refs returns the names or IDs of the containers referred to
+func (r *dockerRefs) refs() []string {
+	var refs []string
+	for _, mode := range []string{r.NetworkMode, r.IpcMode, r.PidMode, r.UTSMode, r.UsernsMode} {
+		if strings.HasPrefix(mode, containerModePrefix) {
+			refs = append(refs, strings.TrimPrefix(mode, containerModePrefix))
+		}
+	}
+	// container[:ro|rw]
+	for _, v := range r.VolumesFrom {
+		refs = append(refs, strings.SplitN(v, ":", 2)[0])
+	}
+	// /name:/container/alias
+	for _, l := range r.Links {
+		refs = append(refs, strings.TrimPrefix(strings.SplitN(l, ":", 2)[0], "/"))
+	}
+	return refs
+}
+
+// transformGroup is a set of containers depending on each other,
+// sorted so that the providers come before their consumers
+type transformGroup struct {
+	ids []string
+	// err is set when the group can not be transformed
+	err error
+}
+
+// loadRefs reads the fields referring to other containers from docker hostconfig.json of container id
+func (t *dockerTransformer) loadRefs(id string) (*dockerRefs, error) {
+	var r dockerRefs
+	data, err := t.readHostConfig(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, errors.Wrap(err, "unmarshal host config data")
+	}
+	return &r, nil
+}
+
+// containerRefs returns the names or IDs of the containers referred to by container id
+func (t *dockerTransformer) containerRefs(id string) ([]string, error) {
+	r, err := t.loadRefs(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.refs(), nil
+}
+
+// containerNames maps the names of the containers known by the transformer to their IDs,
+// the names are loaded once and shared by all the transformations
+func (t *dockerTransformer) containerNames() map[string]string {
+	t.namesOnce.Do(func() {
+		t.names = t.loadNames()
+	})
+	return t.names
+}
+
+func (t *dockerTransformer) loadNames() map[string]string {
+	names := make(map[string]string)
+	t.ctrs.Range(func(k, _ interface{}) bool {
+		id, _ := k.(string)
+		cfg, err := t.loadV2Config(id)
+		if err != nil {
+			logrus.Warnf("load name of container %s failed: %v", id, err)
+			return true
+		}
+		names[strings.TrimPrefix(cfg.Name, "/")] = id
+		return true
+	})
+	return names
+}
+
+// errCtrNotFound is returned when no container is referred to
+var errCtrNotFound = errors.New("container was not found")
+
+// resolveRef finds the ID of the container referred to in the same way as docker CLI, by its full ID,
+// name with or without the leading '/' or unique ID prefix, an error is returned if the prefix is ambiguous
+func (t *dockerTransformer) resolveRef(ref string, names map[string]string) (string, error) {
+	if _, ok := t.ctrs.Load(ref); ok {
+		return ref, nil
+	}
+	if id, ok := names[strings.TrimPrefix(ref, "/")]; ok {
+		return id, nil
+	}
+	var matched []string
+	t.ctrs.Range(func(k, _ interface{}) bool {
+		if id, _ := k.(string); ref != "" && strings.HasPrefix(id, ref) {
+			matched = append(matched, id)
+		}
+		return true
+	})
+	switch len(matched) {
+	case 0:
+		return "", errCtrNotFound
+	case 1:
+		return matched[0], nil
+	default:
+	}
+	sort.Strings(matched)
+	return "", errors.Errorf("multiple containers found with prefix %s: %s", ref, strings.Join(matched, ", "))
+}
+
+// planGroups claims the containers to be transformed and splits them into groups by dependencies,
+// the dependencies are included automatically when withDeps is true, otherwise the containers
+// depending on the ones not given are refused. Results are returned for the given ids
+// which need no transformation.
+func (t *dockerTransformer) planGroups(ids []string, withDeps bool) ([]transformGroup, []transform.Result) {
+	var (
+		results  []transform.Result
+		queue    []string
+		selected = make(map[string]bool)
+		deps     = make(map[string][]string)
+		errs     = make(map[string]error)
+		names    = t.containerNames()
+	)
+	for _, id := range ids {
+		switch ctrID, st := t.matchID(id); st {
+		case notExist:
+			results = append(results, transform.Result{
+				Container: id,
+				Msg:       fmt.Sprintf("transform %s: container was not found", id),
+				Skipped:   transform.SkipUnresolved,
+			})
+		case hasBeenTransformed:
+			if !selected[ctrID] {
+				results = append(results, transform.Result{
+					Container: ctrID,
+					Ok:        true,
+					Msg:       fmt.Sprintf("transform %s: container has been transformed", id),
+					Skipped:   transform.SkipTransformed,
+				})
+			}
+		case needTransform:
+			selected[ctrID] = true
+			queue = append(queue, ctrID)
+		default:
+		}
+	}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		refs, err := t.containerRefs(id)
+		if err != nil {
+			errs[id] = errors.Wrap(err, "get dependencies")
+			continue
+		}
+		for _, ref := range refs {
+			depID, inIsulad, err := t.resolveDep(ref, names)
+			if err != nil {
+				errs[id] = errors.Wrapf(err, "dependency %s", ref)
+				break
+			}
+			if depID == id {
+				continue
+			}
+			deps[id] = append(deps[id], depID)
+			if selected[depID] || inIsulad {
+				continue
+			}
+			if !withDeps {
+				errs[id] = errors.Errorf("dependency %s is not being transformed", ref)
+				break
+			}
+			if t.excluded[depID] {
+				errs[id] = errors.Errorf("dependency %s is excluded", ref)
+				break
+			}
+			// the dependency claimed before is neither in isulad, as it failed or was rolled back,
+			// nor in this plan, as it is being transformed by another job
+			if _, st := t.matchID(depID); st != needTransform {
+				errs[id] = errors.Errorf("dependency %s is claimed but not in isulad", ref)
+				break
+			}
+			logrus.Infof("transform %s as the dependency of %s", depID, id)
+			selected[depID] = true
+			queue = append(queue, depID)
+		}
+	}
+	return groupByDeps(selected, deps, errs), results
+}
+
+// resolveDep finds the ID of the container referred to by ref and tells whether it is in isulad,
+// the container left out of docker after its transformation is looked up in isulad
+func (t *dockerTransformer) resolveDep(ref string, names map[string]string) (string, bool, error) {
+	id, err := t.resolveRef(ref, names)
+	if err == nil {
+		return id, inIsulad(id), nil
+	}
+	if err != errCtrNotFound {
+		return "", false, err
+	}
+	isuladNames, nameErr := isulad.GetIsuladTool().ContainerNames()
+	if nameErr != nil {
+		return "", false, nameErr
+	}
+	if id, err = resolveIsuladRef(ref, isuladNames); err != nil {
+		return "", false, err
+	}
+	return id, true, nil
+}
+
+// inIsulad reports whether container id exists in isulad
+func inIsulad(id string) bool {
+	return utils.CheckFileValid(isulad.GetIsuladTool().GetConfigV2Path(id)) == nil
+}
+
+// resolveIsuladRef finds the ID of the isulad container referred to in the same way as resolveRef,
+// names maps the names of the isulad containers to their IDs
+func resolveIsuladRef(ref string, names map[string]string) (string, error) {
+	if id, ok := names[strings.TrimPrefix(ref, "/")]; ok {
+		return id, nil
+	}
+	var matched []string
+	for _, id := range names {
+		if id == ref {
+			return id, nil
+		}
+		if ref != "" && strings.HasPrefix(id, ref) {
+			matched = append(matched, id)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return "", errCtrNotFound
+	case 1:
+		return matched[0], nil
+	default:
+	}
+	sort.Strings(matched)
+	return "", errors.Errorf("multiple containers found with prefix %s: %s", ref, strings.Join(matched, ", "))
+}
+
+// groupByDeps splits the selected containers into the groups connected by dependencies,
+// the group is refused as a whole if any member has an error
+func groupByDeps(selected map[string]bool, deps map[string][]string, errs map[string]error) []transformGroup {
+	// undirected edges for finding the connected groups
+	adj := make(map[string][]string)
+	for id, ds := range deps {
+		for _, d := range ds {
+			if !selected[d] {
+				continue
+			}
+			adj[id] = append(adj[id], d)
+			adj[d] = append(adj[d], id)
+		}
+	}
+
+	ids := make([]string, 0, len(selected))
+	for id := range selected {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var groups []transformGroup
+	visited := make(map[string]bool)
+	for _, id := range ids {
+		if visited[id] {
+			continue
+		}
+		var members []string
+		stack := []string{id}
+		visited[id] = true
+		for len(stack) > 0 {
+			cur := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			members = append(members, cur)
+			for _, next := range adj[cur] {
+				if !visited[next] {
+					visited[next] = true
+					stack = append(stack, next)
+				}
+			}
+		}
+
+		g := transformGroup{}
+		g.ids, g.err = sortByDeps(members, deps)
+		for _, m := range g.ids {
+			if err, ok := errs[m]; ok && g.err == nil {
+				g.err = errors.Wrapf(err, "container %s", m)
+			}
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// sortByDeps sorts the members of a group so that the providers come before their consumers
+func sortByDeps(members []string, deps map[string][]string) ([]string, error) {
+	inGroup := make(map[string]bool, len(members))
+	for _, m := range members {
+		inGroup[m] = true
+	}
+	pending := make(map[string]int, len(members))
+	consumers := make(map[string][]string)
+	for _, m := range members {
+		for _, d := range deps[m] {
+			if inGroup[d] {
+				pending[m]++
+				consumers[d] = append(consumers[d], m)
+			}
+		}
+	}
+
+	var ready, sorted []string
+	for _, m := range members {
+		if pending[m] == 0 {
+			ready = append(ready, m)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		cur := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, cur)
+		for _, c := range consumers[cur] {
+			if pending[c]--; pending[c] == 0 {
+				ready = append(ready, c)
+			}
+		}
+	}
+	if len(sorted) != len(members) {
+		sort.Strings(members)
+		return members, errors.New("circular dependencies found")
+	}
+	return sorted, nil
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/pkg/isulad"
+	"isula.org/isula-transform/transform"
+	"isula.org/isula-transform/types"
+)
+
+func prepareDepsTestContainer(graph, id, name, hostCfg string) error {
+	root := filepath.Join(graph, "containers", id)
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return err
+	}
+	v2Cfg := fmt.Sprintf(`{"ID":"%s","Name":"/%s"}`, id, name)
+	if err := ioutil.WriteFile(filepath.Join(root, types.V2config), []byte(v2Cfg), 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(root, types.Hostconfig), []byte(hostCfg), 0600)
+}
+
+// prepareIsuladTestContainer makes container id named name exist in isulad
+func prepareIsuladTestContainer(id, name string) error {
+	cfgPath := isulad.GetIsuladTool().GetConfigV2Path(id)
+	if err := os.MkdirAll(filepath.Dir(cfgPath), 0700); err != nil {
+		return err
+	}
+	v2Cfg := fmt.Sprintf(`{"CommonConfig":{"ID":"%s","Name":"%s"}}`, id, name)
+	return ioutil.WriteFile(cfgPath, []byte(v2Cfg), 0600)
+}
+
+// ctrID returns a container ID made of c
+func ctrID(c byte) string {
+	return strings.Repeat(string(c), containerIDLen)
+}
+
+func Test_dockerTransformer_planGroups(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	testCtrs := []struct {
+		id, name, hostCfg string
+	}{
+		{ctrID('a'), "provider", `{"NetworkMode":"host"}`},
+		{ctrID('b'), "net", `{"NetworkMode":"container:provider","IpcMode":"container:` + ctrID('a') + `"}`},
+		{ctrID('c'), "volume", `{"VolumesFrom":["bbb:ro"],"Links":["/provider:/volume/db"]}`},
+		{ctrID('d'), "standalone", `{}`},
+		{ctrID('e'), "broken", `{"Links":["/missing:/broken/missing"]}`},
+	}
+	for _, c := range testCtrs {
+		if err := prepareDepsTestContainer(tmpdir, c.id, c.name, c.hostCfg); err != nil {
+			t.Skipf("prepare container %s: %v", c.name, err)
+		}
+	}
+	_ = isulad.InitIsuladTool(&isulad.DaemonConfig{Graph: filepath.Join(tmpdir, "isulad")})
+	newTransformer := func() *dockerTransformer {
+		dt := &dockerTransformer{ctrs: &sync.Map{}}
+		dt.GraphRoot = tmpdir
+		for _, c := range testCtrs {
+			dt.ctrs.Store(c.id, false)
+		}
+		return dt
+	}
+	groupOf := func(groups []transformGroup, id string) *transformGroup {
+		for idx := range groups {
+			for _, m := range groups[idx].ids {
+				if m == id {
+					return &groups[idx]
+				}
+			}
+		}
+		return nil
+	}
+
+	Convey("Test_dockerTransformer_planGroups", t, func() {
+		Convey("include dependencies", func() {
+			dt := newTransformer()
+			groups, results := dt.planGroups([]string{"ccc", "ddd", "eee", "fff"}, true)
+			So(len(results), ShouldEqual, 1)
+			So(results[0].Ok, ShouldBeFalse)
+			So(results[0].Skipped, ShouldEqual, transform.SkipUnresolved)
+			So(len(groups), ShouldEqual, 3)
+
+			g := groupOf(groups, ctrID('c'))
+			So(g, ShouldNotBeNil)
+			So(g.err, ShouldBeNil)
+			So(g.ids, ShouldResemble, []string{ctrID('a'), ctrID('b'), ctrID('c')})
+			g = groupOf(groups, ctrID('d'))
+			So(g.err, ShouldBeNil)
+			So(g.ids, ShouldResemble, []string{ctrID('d')})
+			g = groupOf(groups, ctrID('e'))
+			So(g.err, ShouldBeError)
+		})
+
+		Convey("refuse partial set", func() {
+			dt := newTransformer()
+			groups, results := dt.planGroups([]string{"bbb", "ccc"}, false)
+			So(len(results), ShouldEqual, 0)
+			So(len(groups), ShouldEqual, 1)
+			So(groups[0].err, ShouldBeError)
+			So(groups[0].ids, ShouldResemble, []string{ctrID('b'), ctrID('c')})
+		})
+
+		Convey("dependencies transformed before", func() {
+			defer os.RemoveAll(isulad.GetIsuladTool().GetRuntimePath())
+			So(prepareIsuladTestContainer(ctrID('a'), "provider"), ShouldBeNil)
+			So(prepareIsuladTestContainer(ctrID('b'), "net"), ShouldBeNil)
+			// a fresh transformer sees the containers transformed by an earlier run
+			dt := newTransformer()
+			groups, results := dt.planGroups([]string{"ccc"}, false)
+			So(len(results), ShouldEqual, 0)
+			So(len(groups), ShouldEqual, 1)
+			So(groups[0].err, ShouldBeNil)
+			So(groups[0].ids, ShouldResemble, []string{ctrID('c')})
+
+			// the excluded provider is accepted once it is in isulad
+			dt = newTransformer()
+			dt.excluded = map[string]bool{ctrID('b'): true}
+			groups, _ = dt.planGroups([]string{"ccc"}, true)
+			So(groups[0].err, ShouldBeNil)
+			So(groups[0].ids, ShouldResemble, []string{ctrID('c')})
+		})
+
+		Convey("dependencies only in isulad", func() {
+			defer os.RemoveAll(isulad.GetIsuladTool().GetRuntimePath())
+			So(prepareIsuladTestContainer(ctrID('a'), "provider"), ShouldBeNil)
+			// the provider has been removed from docker after its transformation
+			dt := newTransformer()
+			dt.ctrs.Delete(ctrID('a'))
+			groups, _ := dt.planGroups([]string{"bbb"}, false)
+			So(len(groups), ShouldEqual, 1)
+			So(groups[0].err, ShouldBeNil)
+			So(groups[0].ids, ShouldResemble, []string{ctrID('b')})
+		})
+
+		Convey("dependencies claimed but not in isulad", func() {
+			// the provider failed or was rolled back earlier in the same run
+			dt := newTransformer()
+			dt.ctrs.Store(ctrID('a'), true)
+			groups, _ := dt.planGroups([]string{"bbb"}, true)
+			So(len(groups), ShouldEqual, 1)
+			So(groups[0].err, ShouldBeError)
+			groups, _ = dt.planGroups([]string{"ccc"}, false)
+			So(groups[0].err, ShouldBeError)
+		})
+
+		Convey("circular dependencies", func() {
+			_, err := sortByDeps([]string{"x", "y"}, map[string][]string{"x": {"y"}, "y": {"x"}})
+			So(err, ShouldBeError)
+		})
+	})
+}
+
+func Test_dockerTransformer_prepareGroup(t *testing.T) {
+	Convey("Test_dockerTransformer_prepareGroup", t, func() {
+		dt := &dockerTransformer{}
+		run, results := dt.prepareGroup(context.Background(), transformGroup{
+			ids: []string{"x", "y"},
+			err: fmt.Errorf("refused"),
+		})
+		So(run, ShouldBeNil)
+		So(results, ShouldResemble, []transform.Result{
+			{Msg: "transform x: refused"},
+			{Msg: "transform y: refused"},
+		})
+	})
+}
+
+func Test_dockerTransformer_resolveRef(t *testing.T) {
+	Convey("Test_dockerTransformer_resolveRef", t, func() {
+		dt := &dockerTransformer{ctrs: &sync.Map{}}
+		abc, abd := "abc"+ctrID('1')[3:], "abd"+ctrID('2')[3:]
+		dt.ctrs.Store(abc, false)
+		dt.ctrs.Store(abd, false)
+		names := map[string]string{"web": abc, "abd": abc}
+
+		testCases := []struct {
+			ref, expect string
+		}{
+			{abd, abd},
+			{"web", abc},
+			{"/web", abc},
+			// name comes before the ID prefix as docker does
+			{"abd", abc},
+			{"abc", abc},
+		}
+		for _, tc := range testCases {
+			id, err := dt.resolveRef(tc.ref, names)
+			So(err, ShouldBeNil)
+			So(id, ShouldEqual, tc.expect)
+		}
+
+		_, err := dt.resolveRef("ab", names)
+		So(err, ShouldBeError, "multiple containers found with prefix ab: "+abc+", "+abd)
+		_, err = dt.resolveRef("missing", names)
+		So(err, ShouldEqual, errCtrNotFound)
+		_, err = dt.resolveRef("", names)
+		So(err, ShouldEqual, errCtrNotFound)
+	})
+}
+
+func Test_dockerTransformer_selectContainers_resolve(t *testing.T) {
+	Convey("Test_dockerTransformer_selectContainers_resolve", t, func() {
+		dt := &dockerTransformer{ctrs: &sync.Map{}}
+		dt.ctrs.Store(ctrID('a'), false)
+		dt.ctrs.Store("ab"+ctrID('b')[2:], false)
+		dt.names = map[string]string{"web": ctrID('a')}
+		dt.namesOnce.Do(func() {})
+
+		ids, results := dt.selectContainers([]string{"web", "/web", "aaaa", ctrID('a'), "a", "missing"}, false)
+		So(ids, ShouldResemble, []string{ctrID('a')})
+		So(len(results), ShouldEqual, 2)
+		for _, ret := range results {
+			So(ret.Ok, ShouldBeFalse)
+		}
+		So(results[0].Msg, ShouldStartWith, "transform a: multiple containers found with prefix a")
+		So(results[1].Msg, ShouldEqual, "transform missing: container was not found")
+	})
+}
@@ -14,15 +14,16 @@ package docker
 
 import (
 	"context"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 	"isula.org/isula-transform/transform"
 	"isula.org/isula-transform/types"
+	"isula.org/isula-transform/utils"
 )
 
 const (
@@ -30,6 +31,8 @@ const (
 	addItem
 	delItem
 	ignoreItem
+	// metaItem means only the metadata of the directory need to be migrated
+	metaItem
 )
 
 type diffNode struct {
@@ -38,6 +41,21 @@ type diffNode struct {
 	path      string
 }
 
+// kind returns the operation should be taken on the node, leaf nodes are
+// copied or deleted as a whole, while only the metadata of the added or
+// changed parent directories is applied
+func (n *diffNode) kind() uint8 {
+	if len(n.children) == 0 {
+		return n.operation
+	}
+	switch n.operation {
+	case addItem, changeItem:
+		return metaItem
+	default:
+	}
+	return ignoreItem
+}
+
 type diffTrie struct {
 	root *diffNode
 }
@@ -61,17 +79,16 @@ func (t *diffTrie) insert(path string, operation uint8) {
 		node, ok := curNode.children[curPath]
 		if !ok {
 			node = &diffNode{
-				operation: operation,
+				operation: ignoreItem,
 				path:      filepath.Join(curNode.path, curPath),
 				children:  make(map[string]*diffNode),
 			}
 			curNode.children[curPath] = node
-		} else {
-			node.operation = ignoreItem
 		}
 		curNode = node
 		pathItems = pathItems[1:]
 	}
+	curNode.operation = operation
 }
 
 func (t *diffTrie) filter() []container.ContainerChangeResponseItem {
@@ -84,9 +101,9 @@ func (t *diffTrie) filter() []container.ContainerChangeResponseItem {
 	stack = append(stack, node)
 	for len(stack) > 0 {
 		node := stack[0]
-		if node.operation != ignoreItem {
+		if kind := node.kind(); kind != ignoreItem {
 			diffs = append(diffs, container.ContainerChangeResponseItem{
-				Kind: node.operation,
+				Kind: kind,
 				Path: node.path,
 			})
 		}
@@ -100,18 +117,54 @@ func (t *diffTrie) filter() []container.ContainerChangeResponseItem {
 
 type deviceMapperDriver struct {
 	transform.BaseStorageDriver
-	client dockerClient
+	sources changeSources
+	limiter *utils.RateLimiter
+	events  *transform.EventBus
+	// owners shifts the owners of the copied files when not nil
+	owners *idShift
 }
 
-func newDeviceMapperDriver(base transform.BaseStorageDriver, client dockerClient) transform.StorageDriver {
-	return &deviceMapperDriver{BaseStorageDriver: base, client: client}
+func newDeviceMapperDriver(base transform.BaseStorageDriver, sources changeSources,
+	limiter *utils.RateLimiter, events *transform.EventBus, owners *idShift) transform.StorageDriver {
+	return &deviceMapperDriver{BaseStorageDriver: base, sources: sources, limiter: limiter, events: events, owners: owners}
 }
 
-func (dm *deviceMapperDriver) GenerateRootFs(id, image string) (string, error) {
-	return dm.BaseStorageDriver.GenerateRootFs(id, image)
+func (dm *deviceMapperDriver) GenerateRootFs(id, image string, storageOpt map[string]string) (string, error) {
+	return dm.BaseStorageDriver.GenerateRootFs(id, image, storageOpt)
+}
+
+func (dm *deviceMapperDriver) RWLayerSize(ctx context.Context, ctr *types.IsuladV2Config,
+	oldRootFs string) (int64, error) {
+	srcRoot, walk, release, err := dm.sources.open(ctx, originDriver(ctr, transform.DeviceMapper), ctr, oldRootFs)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	return changesSize(srcRoot, walk)
 }
 
-func (dm *deviceMapperDriver) TransformRWLayer(ctr *types.IsuladV2Config, oldRootFs string) error {
+// RWLayerSpace returns the free space of the new rootfs, the size limit of devicemapper
+// covers the whole device and the image takes part of it
+func (dm *deviceMapperDriver) RWLayerSpace(ctr *types.IsuladV2Config, limit int64) (int64, error) {
+	if err := dm.BaseStorageDriver.MountRootFs(ctr.CommonConfig.ID, ctr.Image); err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := dm.BaseStorageDriver.UmountRootFs(ctr.CommonConfig.ID, ctr.Image); err != nil {
+			logrus.Infof("device mapper umount rootfs failed: %v", err)
+		}
+	}()
+
+	var st unix.Statfs_t
+	if err := unix.Statfs(ctr.CommonConfig.BaseFs, &st); err != nil {
+		logrus.Errorf("statfs %s failed: %v", ctr.CommonConfig.BaseFs, err)
+		return 0, errors.Wrapf(err, "get free space of %s", ctr.CommonConfig.BaseFs)
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
+
+func (dm *deviceMapperDriver) TransformRWLayer(ctx context.Context, ctr *types.IsuladV2Config, oldRootFs string,
+	size int64) error {
 	if err := dm.BaseStorageDriver.MountRootFs(ctr.CommonConfig.ID, ctr.Image); err != nil {
 		return err
 	}
@@ -121,45 +174,103 @@ func (dm *deviceMapperDriver) TransformRWLayer(ctr *types.IsuladV2Config, oldRoo
 		}
 	}()
 
-	diff, err := dm.client.ContainerDiff(context.Background(), ctr.CommonConfig.ID)
+	driver := originDriver(ctr, transform.DeviceMapper)
+	srcRoot, walk, release, err := dm.sources.open(ctx, driver, ctr, oldRootFs)
 	if err != nil {
 		return err
 	}
-	changes := dm.changesFilter(diff, ctr.CommonConfig.MountPoints)
-	logrus.Infof("device mapper driver get diff form docker: %+v, filter: %+v", diff, changes)
-	for idx := range changes {
-		src := oldRootFs + changes[idx].Path
-		dest := ctr.CommonConfig.BaseFs + changes[idx].Path
-		switch changes[idx].Kind {
-		case addItem, changeItem:
-			destRoot := filepath.Dir(dest)
-			if err := exec.Command("cp", "-ra", src, destRoot).Run(); err != nil {
-				logrus.Errorf("device mapper copy %s to %s filed: %v", src, dest, err)
-				return err
-			}
-		case delItem:
-			if err := os.RemoveAll(dest); err != nil {
-				logrus.Errorf("device mapper remover %s filed: %v", dest, err)
+	defer release()
+	progress := newCopyProgress(dm.events, ctr.CommonConfig.ID, size)
+	if err := applyChanges(ctx, srcRoot, ctr.CommonConfig.BaseFs, progress.wrap(srcRoot, walk),
+		dm.limiter, dm.owners, ctr.CommonConfig.MountLabel, privateXattrs(driver)...); err != nil {
+		return err
+	}
+	progress.done()
+	return nil
+}
+
+func (dm *deviceMapperDriver) VerifyRWLayer(ctx context.Context, ctr *types.IsuladV2Config, oldRootFs string) error {
+	if err := dm.BaseStorageDriver.MountRootFs(ctr.CommonConfig.ID, ctr.Image); err != nil {
+		return err
+	}
+	defer func() {
+		if err := dm.BaseStorageDriver.UmountRootFs(ctr.CommonConfig.ID, ctr.Image); err != nil {
+			logrus.Infof("device mapper umount rootfs failed: %v", err)
+		}
+	}()
+
+	driver := originDriver(ctr, transform.DeviceMapper)
+	srcRoot, walk, release, err := dm.sources.open(ctx, driver, ctr, oldRootFs)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return verifyChanges(srcRoot, ctr.CommonConfig.BaseFs, walk, dm.owners, checkRemoved, privateXattrs(driver)...)
+}
+
+func (dm *deviceMapperDriver) Cleanup(id string) {
+	dm.BaseStorageDriver.CleanupRootFs(id)
+}
+
+// dmSource gets the changes of the container created by docker devicemapper driver
+type dmSource struct {
+	client dockerClient
+	// local computes the changes without ContainerDiff API when not nil
+	local *dmLocalDiff
+}
+
+func (ds *dmSource) open(ctx context.Context, ctr *types.IsuladV2Config, oldRootFs string) (string, changeWalker, func(), error) {
+	if ds.local != nil {
+		ctrRoot, parentRoot, release, err := ds.local.prepare(ctr.CommonConfig.ID, oldRootFs)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		walk := func(fn changeFunc) error {
+			return diffWalk(ctrRoot, parentRoot, ctr.CommonConfig.MountPoints, fn)
+		}
+		return ctrRoot, walk, release, nil
+	}
+
+	changes, err := ds.changes(ctx, ctr)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	walk := func(fn changeFunc) error {
+		for idx := range changes {
+			if err := fn(changes[idx]); err != nil {
 				return err
 			}
-		default:
 		}
+		return nil
 	}
-	return nil
+	return oldRootFs, walk, func() {}, nil
+}
+
+// changes gets the diff of container from docker and filters out the items need to be migrated
+func (ds *dmSource) changes(ctx context.Context,
+	ctr *types.IsuladV2Config) ([]container.ContainerChangeResponseItem, error) {
+	diff, err := ds.client.ContainerDiff(ctx, ctr.CommonConfig.ID)
+	if err != nil {
+		return nil, err
+	}
+	changes := ds.changesFilter(diff, ctr.CommonConfig.MountPoints)
+	logrus.Infof("device mapper driver get diff form docker: %+v, filter: %+v", diff, changes)
+	return changes, nil
 }
 
 /*
-A /xxx/.../something ==> root dir /xxx  C
-D /xxx/.../something ==> root dir /xxx C
-C /xxx/.../something ==> root dir /xxx C
-1. We ignore the parent folder node /xxx/..., so if the properties of the folder
-   are modified at the same time, the change is lost.
-2. only if the node didn't have any children nodes, we copy or delete it. In
-   another words, We only deal with leaf nodes.
+A /xxx/.../something ==> parent dir /xxx A or C
+D /xxx/.../something ==> parent dir /xxx C
+C /xxx/.../something ==> parent dir /xxx C
+ 1. only if the node didn't have any children nodes, we copy or delete it as
+    a whole. In another words, the content is only migrated by leaf nodes.
+ 2. the added or changed parent folder node /xxx/... is kept as a metaItem, so
+    that its permission, owner and xattrs are applied to the destination
+    without recopying the whole folder.
 
 note: filter path which match bind mount in container
 */
-func (dm *deviceMapperDriver) changesFilter(changes []container.ContainerChangeResponseItem,
+func (ds *dmSource) changesFilter(changes []container.ContainerChangeResponseItem,
 	mounts map[string]types.Mount) []container.ContainerChangeResponseItem {
 	t := newTrie()
 	for _, change := range changes {
@@ -170,7 +281,3 @@ func (dm *deviceMapperDriver) changesFilter(changes []container.ContainerChangeR
 	}
 	return t.filter()
 }
-
-func (dm *deviceMapperDriver) Cleanup(id string) {
-	dm.BaseStorageDriver.CleanupRootFs(id)
-}
@@ -0,0 +1,349 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+	"isula.org/isula-transform/transform"
+	"isula.org/isula-transform/types"
+	"isula.org/isula-transform/utils"
+)
+
+const (
+	dmDiffAPI   = "api"
+	dmDiffLocal = "local"
+
+	dmSectorSize      = 512
+	dmActivateSuffix  = "transform"
+	dmDeviceSetMeta   = "deviceset-metadata"
+	dmDefaultFsType   = "xfs"
+	dmContainerRootfs = "rootfs"
+)
+
+// dmDeviceInfo maps the metadata of a thin device saved by docker devicemapper driver
+type dmDeviceInfo struct {
+	DeviceID int    `json:"device_id"`
+	Size     uint64 `json:"size"`
+}
+
+// dmDeviceSetInfo maps the deviceset-metadata saved by docker devicemapper driver
+type dmDeviceSetInfo struct {
+	BaseDeviceFilesystem string `json:"BaseDeviceFilesystem"`
+}
+
+// dmLocalDiff computes the changes of a devicemapper container by comparing
+// the thin device of the container with its init device, which is the parent
+// device docker uses for ContainerDiff, without the help of dockerd
+type dmLocalDiff struct {
+	graphRoot string
+}
+
+func newDmLocalDiff(graphRoot string) *dmLocalDiff {
+	return &dmLocalDiff{graphRoot: graphRoot}
+}
+
+func (l *dmLocalDiff) dmRoot() string {
+	return filepath.Join(l.graphRoot, "devicemapper")
+}
+
+// devicePrefix returns the name prefix of devices which docker generated by the devicemapper root
+func (l *dmLocalDiff) devicePrefix() (string, error) {
+	fi, err := os.Stat(l.dmRoot())
+	if err != nil {
+		return "", errors.Wrap(err, "stat devicemapper root")
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", errors.New("unsupported stat type of devicemapper root")
+	}
+	dev := uint64(st.Dev)
+	major := (dev >> 8) & 0xfff
+	minor := (dev & 0xff) | ((dev >> 12) & 0xfff00)
+	return fmt.Sprintf("docker-%d:%d-%d", major, minor, st.Ino), nil
+}
+
+// layerID reads the mount-id or init-id of container from the layer database
+func (l *dmLocalDiff) layerID(id, name string) (string, error) {
+	return readLayerID(l.graphRoot, transform.DeviceMapper, id, name)
+}
+
+func (l *dmLocalDiff) deviceInfo(hash string) (*dmDeviceInfo, error) {
+	var info dmDeviceInfo
+	data, err := ioutil.ReadFile(filepath.Join(l.dmRoot(), "metadata", hash))
+	if err != nil {
+		return nil, errors.Wrapf(err, "read metadata of device %s", hash)
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal metadata of device %s", hash)
+	}
+	return &info, nil
+}
+
+func (l *dmLocalDiff) fsType() string {
+	var info dmDeviceSetInfo
+	data, err := ioutil.ReadFile(filepath.Join(l.dmRoot(), "metadata", dmDeviceSetMeta))
+	if err == nil && json.Unmarshal(data, &info) == nil && info.BaseDeviceFilesystem != "" {
+		return info.BaseDeviceFilesystem
+	}
+	return dmDefaultFsType
+}
+
+// thinPool finds the pool of the thin devices from the table of the active container
+// device, which works with both loopback and dm.thinpooldev configurations
+func (l *dmLocalDiff) thinPool(prefix, ctrHash string) string {
+	out, err := exec.Command("dmsetup", "table", prefix+"-"+ctrHash).Output()
+	if err == nil {
+		// table like: 0 20971520 thin 253:0 7
+		fields := strings.Fields(string(out))
+		if len(fields) >= 5 && fields[2] == "thin" {
+			return fields[3]
+		}
+	}
+	return filepath.Join("/dev/mapper", prefix+"-pool")
+}
+
+// activate maps the thin device read-only and mounts it, the returned func releases them
+func (l *dmLocalDiff) activate(prefix, pool, hash string) (string, func(), error) {
+	info, err := l.deviceInfo(hash)
+	if err != nil {
+		return "", nil, err
+	}
+	name := fmt.Sprintf("%s-%s-%s", prefix, hash, dmActivateSuffix)
+	table := fmt.Sprintf("0 %d thin %s %d", info.Size/dmSectorSize, pool, info.DeviceID)
+	if out, err := exec.Command("dmsetup", "create", name, "--readonly", "--table", table).CombinedOutput(); err != nil {
+		return "", nil, errors.Wrapf(err, "activate device %s: %s", hash, strings.TrimSpace(string(out)))
+	}
+	removeDevice := func() {
+		if out, err := exec.Command("dmsetup", "remove", name).CombinedOutput(); err != nil {
+			logrus.Warnf("remove device %s failed: %v: %s", name, err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	mnt, err := ioutil.TempDir("", "isula-transform-dm")
+	if err != nil {
+		removeDevice()
+		return "", nil, err
+	}
+	fsType := l.fsType()
+	var data string
+	switch fsType {
+	case "xfs":
+		// the snapshots share the same uuid with their origin
+		data = "nouuid"
+	case "ext4":
+		// do not replay the journal on a read-only device
+		data = "noload"
+	default:
+	}
+	if err := unix.Mount(filepath.Join("/dev/mapper", name), mnt, fsType, unix.MS_RDONLY, data); err != nil {
+		os.Remove(mnt)
+		removeDevice()
+		return "", nil, errors.Wrapf(err, "mount device %s", hash)
+	}
+	release := func() {
+		if err := unix.Unmount(mnt, unix.MNT_DETACH); err != nil {
+			logrus.Warnf("umount %s failed: %v", mnt, err)
+		}
+		os.Remove(mnt)
+		removeDevice()
+	}
+	return filepath.Join(mnt, dmContainerRootfs), release, nil
+}
+
+// prepare returns the read-only roots of the container and its init layer
+func (l *dmLocalDiff) prepare(id, oldRootFs string) (string, string, func(), error) {
+	var releases []func()
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+
+	prefix, err := l.devicePrefix()
+	if err != nil {
+		return "", "", nil, err
+	}
+	mountID, err := l.layerID(id, "mount-id")
+	if err != nil {
+		return "", "", nil, err
+	}
+	initID, err := l.layerID(id, "init-id")
+	if err != nil {
+		return "", "", nil, err
+	}
+	pool := l.thinPool(prefix, mountID)
+
+	// reuse the mount of docker if the container device is still active
+	ctrRoot := oldRootFs
+	if mounted, _ := isMountPoint(filepath.Dir(oldRootFs)); !mounted {
+		root, rel, err := l.activate(prefix, pool, mountID)
+		if err != nil {
+			return "", "", nil, err
+		}
+		releases = append(releases, rel)
+		ctrRoot = root
+	}
+	parentRoot, rel, err := l.activate(prefix, pool, initID)
+	if err != nil {
+		release()
+		return "", "", nil, err
+	}
+	releases = append(releases, rel)
+	logrus.Infof("device mapper local diff between %s and %s", ctrRoot, parentRoot)
+	return ctrRoot, parentRoot, release, nil
+}
+
+func isMountPoint(path string) (bool, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	pfi, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		return false, err
+	}
+	st, _ := fi.Sys().(*syscall.Stat_t)
+	pst, _ := pfi.Sys().(*syscall.Stat_t)
+	if st == nil || pst == nil {
+		return false, errors.Errorf("unsupported stat type of %s", path)
+	}
+	return st.Dev != pst.Dev || st.Ino == pst.Ino, nil
+}
+
+// diffWalk compares the container root with its parent and calls fn with each change
+// as soon as it is found, the children of added or deleted directories are not walked
+func diffWalk(ctrRoot, parentRoot string, mounts map[string]types.Mount, fn changeFunc) error {
+	relPath := func(root, path string) string {
+		return "/" + strings.TrimPrefix(strings.TrimPrefix(path, root), "/")
+	}
+	skipDir := func(fi os.FileInfo) error {
+		if fi.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}
+
+	err := filepath.Walk(ctrRoot, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := relPath(ctrRoot, path)
+		if rel == "/" {
+			return nil
+		}
+		if _, ok := mounts[rel]; ok {
+			return skipDir(fi)
+		}
+		pfi, err := os.Lstat(filepath.Join(parentRoot, rel))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			if err := fn(container.ContainerChangeResponseItem{Kind: addItem, Path: rel}); err != nil {
+				return err
+			}
+			return skipDir(fi)
+		}
+		if fi.Mode()&os.ModeType != pfi.Mode()&os.ModeType {
+			// type changed, replace it as a whole
+			if err := fn(container.ContainerChangeResponseItem{Kind: delItem, Path: rel}); err != nil {
+				return err
+			}
+			if err := fn(container.ContainerChangeResponseItem{Kind: addItem, Path: rel}); err != nil {
+				return err
+			}
+			return skipDir(fi)
+		}
+		changed, err := statChanged(path, filepath.Join(parentRoot, rel), fi, pfi)
+		if err != nil || !changed {
+			return err
+		}
+		kind := uint8(changeItem)
+		if fi.IsDir() {
+			kind = metaItem
+		}
+		return fn(container.ContainerChangeResponseItem{Kind: kind, Path: rel})
+	})
+	if err != nil {
+		return errors.Wrap(err, "walk container rootfs")
+	}
+
+	err = filepath.Walk(parentRoot, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := relPath(parentRoot, path)
+		if rel == "/" {
+			return nil
+		}
+		if _, ok := mounts[rel]; ok {
+			return skipDir(fi)
+		}
+		if _, err := os.Lstat(filepath.Join(ctrRoot, rel)); err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			if err := fn(container.ContainerChangeResponseItem{Kind: delItem, Path: rel}); err != nil {
+				return err
+			}
+			return skipDir(fi)
+		}
+		return nil
+	})
+	return errors.Wrap(err, "walk parent rootfs")
+}
+
+// statChanged follows the rules of docker, size and modification time are not
+// considered for directories
+func statChanged(path, parentPath string, fi, pfi os.FileInfo) (bool, error) {
+	st, _ := fi.Sys().(*syscall.Stat_t)
+	pst, _ := pfi.Sys().(*syscall.Stat_t)
+	if st == nil || pst == nil {
+		return false, errors.Errorf("unsupported stat type of %s", path)
+	}
+	if st.Mode != pst.Mode || st.Uid != pst.Uid || st.Gid != pst.Gid || st.Rdev != pst.Rdev {
+		return true, nil
+	}
+	if !fi.IsDir() && (st.Size != pst.Size || st.Mtim != pst.Mtim) {
+		return true, nil
+	}
+	xattrs, err := utils.Lgetxattrs(path)
+	if err != nil {
+		return false, err
+	}
+	pxattrs, err := utils.Lgetxattrs(parentPath)
+	if err != nil {
+		return false, err
+	}
+	if len(xattrs) != len(pxattrs) {
+		return true, nil
+	}
+	for k, v := range xattrs {
+		if pv, ok := pxattrs[k]; !ok || pv != v {
+			return true, nil
+		}
+	}
+	return false, nil
+}
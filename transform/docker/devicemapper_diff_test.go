@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	. "github.com/google/go-cmp/cmp"
+	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/types"
+)
+
+func Test_dmLocalDiff_metadata(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	l := newDmLocalDiff(tmpdir)
+	mountsRoot := filepath.Join(tmpdir, "image/devicemapper/layerdb/mounts", transformTestCtrID)
+	metaRoot := filepath.Join(tmpdir, "devicemapper/metadata")
+	for _, dir := range []string{mountsRoot, metaRoot} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Skipf("prepare metadata dir: %v", err)
+		}
+	}
+
+	Convey("Test_dmLocalDiff_metadata", t, func() {
+		Convey("device prefix", func() {
+			prefix, err := l.devicePrefix()
+			So(err, ShouldBeNil)
+			So(prefix, ShouldStartWith, "docker-")
+			So(strings.Count(prefix, "-"), ShouldEqual, 2)
+		})
+
+		Convey("layer id", func() {
+			_, err := l.layerID(transformTestCtrID, "mount-id")
+			So(err, ShouldBeError)
+			So(ioutil.WriteFile(filepath.Join(mountsRoot, "init-id"), []byte("abc-init\n"), 0600), ShouldBeNil)
+			id, err := l.layerID(transformTestCtrID, "init-id")
+			So(err, ShouldBeNil)
+			So(id, ShouldEqual, "abc-init")
+		})
+
+		Convey("device info", func() {
+			data := `{"device_id":7,"size":10737418240,"transaction_id":8,"initialized":false,"deleted":false}`
+			So(ioutil.WriteFile(filepath.Join(metaRoot, "abc-init"), []byte(data), 0600), ShouldBeNil)
+			info, err := l.deviceInfo("abc-init")
+			So(err, ShouldBeNil)
+			So(info.DeviceID, ShouldEqual, 7)
+			So(info.Size, ShouldEqual, 10737418240)
+		})
+
+		Convey("filesystem type", func() {
+			So(l.fsType(), ShouldEqual, dmDefaultFsType)
+			data := `{"next_device_id":9,"BaseDeviceUUID":"uuid","BaseDeviceFilesystem":"ext4"}`
+			So(ioutil.WriteFile(filepath.Join(metaRoot, dmDeviceSetMeta), []byte(data), 0600), ShouldBeNil)
+			So(l.fsType(), ShouldEqual, "ext4")
+		})
+	})
+}
+
+func Test_diffWalk(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	ctrRoot, parentRoot := filepath.Join(tmpdir, "ctr"), filepath.Join(tmpdir, "parent")
+
+	Convey("Test_diffWalk", t, func() {
+		sameTime := time.Unix(startUnixTimeStamp, 0)
+		for _, root := range []string{ctrRoot, parentRoot} {
+			So(os.MkdirAll(filepath.Join(root, "etc"), 0755), ShouldBeNil)
+			So(os.MkdirAll(filepath.Join(root, "var/log"), 0755), ShouldBeNil)
+			So(os.MkdirAll(filepath.Join(root, "mnt/data"), 0755), ShouldBeNil)
+			So(ioutil.WriteFile(filepath.Join(root, "etc/same"), []byte("same"), 0644), ShouldBeNil)
+			So(os.Chtimes(filepath.Join(root, "etc/same"), sameTime, sameTime), ShouldBeNil)
+		}
+		defer os.RemoveAll(ctrRoot)
+		defer os.RemoveAll(parentRoot)
+		// changed
+		So(ioutil.WriteFile(filepath.Join(parentRoot, "etc/conf"), []byte("old"), 0644), ShouldBeNil)
+		So(ioutil.WriteFile(filepath.Join(ctrRoot, "etc/conf"), []byte("new content"), 0644), ShouldBeNil)
+		So(os.Chmod(filepath.Join(ctrRoot, "var/log"), 0700), ShouldBeNil)
+		// deleted
+		So(os.MkdirAll(filepath.Join(parentRoot, "opt/app/bin"), 0755), ShouldBeNil)
+		// added
+		So(os.MkdirAll(filepath.Join(ctrRoot, "root/new/sub"), 0755), ShouldBeNil)
+		So(ioutil.WriteFile(filepath.Join(ctrRoot, "var/log/app.log"), []byte("log"), 0644), ShouldBeNil)
+		// type changed
+		So(ioutil.WriteFile(filepath.Join(parentRoot, "etc/link"), []byte("file"), 0644), ShouldBeNil)
+		So(os.Symlink("same", filepath.Join(ctrRoot, "etc/link")), ShouldBeNil)
+		// bind mount
+		So(ioutil.WriteFile(filepath.Join(ctrRoot, "mnt/data/file"), []byte("data"), 0644), ShouldBeNil)
+
+		var got []container.ContainerChangeResponseItem
+		err := diffWalk(ctrRoot, parentRoot, map[string]types.Mount{
+			"/mnt/data": {Destination: "/mnt/data"},
+		}, func(change container.ContainerChangeResponseItem) error {
+			got = append(got, change)
+			return nil
+		})
+		So(err, ShouldBeNil)
+		expect := []container.ContainerChangeResponseItem{
+			{Kind: changeItem, Path: "/etc/conf"},
+			{Kind: delItem, Path: "/etc/link"},
+			{Kind: addItem, Path: "/etc/link"},
+			{Kind: delItem, Path: "/opt"},
+			{Kind: addItem, Path: "/root"},
+			{Kind: metaItem, Path: "/var/log"},
+			{Kind: addItem, Path: "/var/log/app.log"},
+		}
+		sort.SliceStable(got, func(i, j int) bool {
+			return got[i].Path < got[j].Path
+		})
+		So(Diff(got, expect), ShouldBeBlank)
+	})
+}
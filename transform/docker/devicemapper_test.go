@@ -13,18 +13,26 @@
 package docker
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
 	"testing"
+	"time"
 
+	dockertypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	docker "github.com/docker/docker/client"
 	. "github.com/google/go-cmp/cmp"
 	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/transform"
 	"isula.org/isula-transform/types"
 )
 
-func Test_deviceMapperDriver_changesFilter(t *testing.T) {
-	Convey("Test_deviceMapperDriver_changesFilter", t, func() {
-		dm := &deviceMapperDriver{}
+func Test_dmSource_changesFilter(t *testing.T) {
+	Convey("Test_dmSource_changesFilter", t, func() {
+		dm := &dmSource{}
 
 		testDiff := []container.ContainerChangeResponseItem{
 			{Kind: changeItem, Path: "/etc"},            // root filter
@@ -41,9 +49,12 @@ func Test_deviceMapperDriver_changesFilter(t *testing.T) {
 		}
 
 		expect := []container.ContainerChangeResponseItem{
+			{Kind: metaItem, Path: "/etc"},              // parent metadata save
 			{Kind: delItem, Path: "/etc/delfile"},       // delete save
 			{Kind: changeItem, Path: "/etc/os-release"}, // change save
+			{Kind: metaItem, Path: "/root"},             // parent metadata save
 			{Kind: addItem, Path: "/root/add"},          // add save
+			{Kind: metaItem, Path: "/root/padd"},        // parent metadata save
 			{Kind: addItem, Path: "/root/padd/subadd"},  // add save
 		}
 
@@ -59,4 +70,139 @@ func Test_deviceMapperDriver_changesFilter(t *testing.T) {
 
 		So(Diff(got, expect), ShouldBeBlank)
 	})
+
+	Convey("Test_dmSource_changesFilter unlisted parent", t, func() {
+		dm := &dmSource{}
+		got := dm.changesFilter([]container.ContainerChangeResponseItem{
+			{Kind: delItem, Path: "/etc/delfile"},
+		}, nil)
+		So(Diff(got, []container.ContainerChangeResponseItem{
+			{Kind: delItem, Path: "/etc/delfile"},
+		}), ShouldBeBlank)
+	})
+}
+
+type fakeBaseStorageDriver struct{}
+
+func (f *fakeBaseStorageDriver) GenerateRootFs(id, image string, storageOpt map[string]string) (string, error) {
+	return "", nil
+}
+func (f *fakeBaseStorageDriver) CleanupRootFs(id string)             {}
+func (f *fakeBaseStorageDriver) MountRootFs(id, image string) error  { return nil }
+func (f *fakeBaseStorageDriver) UmountRootFs(id, image string) error { return nil }
+
+type fakeDockerClient struct {
+	diff []container.ContainerChangeResponseItem
+	// calls records the calls changing the containers
+	calls []string
+	// offline fails the updates as dockerd is not running
+	offline bool
+	// cgroupDriver is reported by Info
+	cgroupDriver string
+}
+
+func (f *fakeDockerClient) ContainerDiff(context.Context, string) ([]container.ContainerChangeResponseItem, error) {
+	return f.diff, nil
+}
+
+func (f *fakeDockerClient) ContainerPause(context.Context, string) error { return nil }
+
+func (f *fakeDockerClient) ContainerUnpause(_ context.Context, id string) error {
+	f.calls = append(f.calls, "unpause "+id)
+	return nil
+}
+
+func (f *fakeDockerClient) ContainerStart(_ context.Context, id string, _ dockertypes.ContainerStartOptions) error {
+	f.calls = append(f.calls, "start "+id)
+	return nil
+}
+
+func (f *fakeDockerClient) ContainerStop(_ context.Context, id string, _ *time.Duration) error {
+	f.calls = append(f.calls, "stop "+id)
+	return nil
+}
+
+func (f *fakeDockerClient) ContainerUpdate(_ context.Context, id string,
+	cfg container.UpdateConfig) (container.ContainerUpdateOKBody, error) {
+	if f.offline {
+		return container.ContainerUpdateOKBody{}, docker.ErrorConnectionFailed("unix:///var/run/docker.sock")
+	}
+	f.calls = append(f.calls, "update "+id+" "+cfg.RestartPolicy.Name)
+	return container.ContainerUpdateOKBody{}, nil
+}
+
+func (f *fakeDockerClient) ContainerRemove(_ context.Context, id string, _ dockertypes.ContainerRemoveOptions) error {
+	f.calls = append(f.calls, "remove "+id)
+	return nil
+}
+
+func (f *fakeDockerClient) Info(context.Context) (dockertypes.Info, error) {
+	if f.offline {
+		return dockertypes.Info{}, docker.ErrorConnectionFailed("unix:///var/run/docker.sock")
+	}
+	return dockertypes.Info{CgroupDriver: f.cgroupDriver}, nil
+}
+
+func Test_deviceMapperDriver_TransformRWLayer(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	oldRootFs, newRootFs := filepath.Join(tmpdir, "old"), filepath.Join(tmpdir, "new")
+	for _, dir := range []string{oldRootFs + "/etc/app", oldRootFs + "/data/sub", newRootFs + "/etc"} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Skipf("prepare rootfs: %v", err)
+		}
+	}
+	if err := ioutil.WriteFile(oldRootFs+"/data/sub/file", []byte("data"), 0600); err != nil {
+		t.Skipf("prepare rootfs: %v", err)
+	}
+	if err := ioutil.WriteFile(newRootFs+"/etc/delfile", []byte("del"), 0600); err != nil {
+		t.Skipf("prepare rootfs: %v", err)
+	}
+
+	Convey("Test_deviceMapperDriver_TransformRWLayer", t, func() {
+		So(os.Chmod(oldRootFs+"/etc", 0700), ShouldBeNil)
+		So(os.Chmod(oldRootFs+"/data", 0750), ShouldBeNil)
+		events := transform.NewEventBus()
+		evCh, unsubscribe := events.Subscribe(16)
+		dm := newDeviceMapperDriver(&fakeBaseStorageDriver{}, newChangeSources("",
+			&fakeDockerClient{diff: []container.ContainerChangeResponseItem{
+				{Kind: changeItem, Path: "/etc"},
+				{Kind: delItem, Path: "/etc/delfile"},
+				{Kind: addItem, Path: "/etc/app"},
+				{Kind: addItem, Path: "/data"},
+				{Kind: addItem, Path: "/data/sub"},
+				{Kind: addItem, Path: "/data/sub/file"},
+			}}, nil), nil, events, nil)
+		ctr := &types.IsuladV2Config{
+			CommonConfig: &types.CommonConfig{ID: "dmtest", BaseFs: newRootFs},
+		}
+		// the size is unknown without a size limit, the last progress has the total
+		So(dm.TransformRWLayer(context.Background(), ctr, oldRootFs, -1), ShouldBeNil)
+		unsubscribe()
+		var last transform.Event
+		for ev := range evCh {
+			So(ev.Type, ShouldEqual, transform.EventBytesCopied)
+			So(ev.Container, ShouldEqual, "dmtest")
+			last = ev
+		}
+		So(last.Total, ShouldBeGreaterThan, 0)
+		So(last.Bytes, ShouldEqual, last.Total)
+
+		fi, err := os.Stat(newRootFs + "/etc")
+		So(err, ShouldBeNil)
+		So(fi.Mode().Perm(), ShouldEqual, os.FileMode(0700))
+		fi, err = os.Stat(newRootFs + "/data")
+		So(err, ShouldBeNil)
+		So(fi.Mode().Perm(), ShouldEqual, os.FileMode(0750))
+		data, err := ioutil.ReadFile(newRootFs + "/data/sub/file")
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "data")
+		_, err = os.Stat(newRootFs + "/etc/delfile")
+		So(os.IsNotExist(err), ShouldBeTrue)
+
+		So(dm.VerifyRWLayer(context.Background(), ctr, oldRootFs), ShouldBeNil)
+	})
 }
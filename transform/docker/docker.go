@@ -20,17 +20,16 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
-	"os"
 	"os/exec"
-	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
+	dockertypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	docker "github.com/docker/docker/client"
+	"github.com/docker/go-units"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -59,18 +58,78 @@ var (
 	containerdNameSpace    = "moby"
 
 	defaultTimeout = 10 * time.Second
-	containerIDLen = 64
+	// isuladReloadTimeout limits the restart of isulad loading the transformed containers
+	isuladReloadTimeout = time.Minute
+	// isuladStopTimeout limits the stop of isulad container, which is killed after 10s
+	isuladStopTimeout = 2 * defaultTimeout
+	containerIDLen    = 64
+	// storageOptSize limits the size of container rootfs
+	storageOptSize = "size"
 )
 
 type dockerClient interface {
 	ContainerDiff(context.Context, string) ([]container.ContainerChangeResponseItem, error)
 	ContainerPause(context.Context, string) error
+	ContainerUnpause(context.Context, string) error
+	ContainerStart(context.Context, string, dockertypes.ContainerStartOptions) error
+	ContainerStop(context.Context, string, *time.Duration) error
+	ContainerUpdate(context.Context, string, container.UpdateConfig) (container.ContainerUpdateOKBody, error)
+	ContainerRemove(context.Context, string, dockertypes.ContainerRemoveOptions) error
+	Info(context.Context) (dockertypes.Info, error)
+}
+
+// isuladClient is the part of isulad client used to start the transformed containers
+type isuladClient interface {
+	Start(ctx context.Context, id string) error
+	WaitReady(ctx context.Context, id string) error
+	Stop(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string) error
+	Reload(ctx context.Context) error
 }
 
 type dockerTransformer struct {
 	ctrs   *sync.Map
 	client dockerClient
+	isulad isuladClient
 	sd     transform.StorageDriver
+	// dmDiff specifies how to get the changes of devicemapper container
+	dmDiff string
+	// copyBps limits the bytes per second of copying read-write layers
+	copyBps string
+	// rulesFile holds the user-defined rules loaded into rules by Init
+	rulesFile string
+	rules     []*reconcileRule
+	// remapPaths relocates the host paths given as old=new, along with the ones of rulesFile
+	remapPaths []string
+	remaps     pathRemaps
+	// cgroups maps the cgroup parents between the cgroup drivers of docker and isulad
+	cgroups cgroupMapper
+	// userns is the ID shift of docker with userns-remap, nil if docker does not remap
+	userns *idShift
+	// isuladRemap is set when isulad remaps as well and the remapping of docker is kept
+	isuladRemap bool
+	// phaseTimeouts are the timeouts of phases given as phase=duration, parsed by Init
+	phaseTimeouts []string
+	// finalize is the action on the docker container after a successful transformation,
+	// the changes are recorded under recordRoot
+	finalize   string
+	recordRoot string
+	// noDeps refuses the containers whose dependencies are not given instead of including them
+	noDeps bool
+	// filters and excludes narrow the containers to be transformed
+	filters  []string
+	excludes []string
+	// filtered and excluded are the containers left out by filters and excludes
+	filtered map[string]bool
+	excluded map[string]bool
+	// nameConflict is the policy of conflicts with the names of existing isulad containers,
+	// nameTemplate renders the new name for the template policy
+	nameConflict string
+	nameTemplate string
+	nameAlloc    *nameAllocator
+	// names maps the container names to IDs, loaded once by containerNames
+	names     map[string]string
+	namesOnce sync.Once
 	transform.BaseTransformer
 }
 
@@ -83,12 +142,27 @@ func New(ctx *cli.Context) transform.Transformer {
 	var opts []transform.EngineOpt
 	graphRoot := ctx.GlobalString("docker-graph")
 	stateRoot := ctx.GlobalString("docker-state")
-	opts = append(opts, transform.EngineWithGraph(graphRoot), transform.EngineWithState(stateRoot))
-	return newWithConfig(opts...)
+	opts = append(opts, transform.EngineWithGraph(graphRoot), transform.EngineWithState(stateRoot),
+		transform.EngineWithVerify(ctx.GlobalBool("verify")), transform.EngineWithParallel(ctx.GlobalInt("parallel")),
+		transform.EngineWithStart(ctx.GlobalBool("start")), transform.EngineWithStartTimeout(ctx.GlobalDuration("start-timeout")),
+		transform.EngineWithContainerTimeout(ctx.GlobalDuration("container-timeout")))
+	e := newWithConfig(opts...)
+	e.dmDiff = ctx.GlobalString("docker-dm-diff")
+	e.copyBps = ctx.GlobalString("copy-bps")
+	e.phaseTimeouts = ctx.GlobalStringSlice("phase-timeout")
+	e.rulesFile = ctx.GlobalString("rules-file")
+	e.remapPaths = ctx.GlobalStringSlice("remap-path")
+	e.noDeps = ctx.GlobalBool("no-deps")
+	e.finalize = ctx.GlobalString("docker-finalize")
+	e.filters = ctx.GlobalStringSlice("filter")
+	e.excludes = ctx.GlobalStringSlice("exclude")
+	e.nameConflict = ctx.GlobalString("on-name-conflict")
+	e.nameTemplate = ctx.GlobalString("name-template")
+	return e
 }
 
 // newWithConfig create a transform engine for docker container with specific config
-func newWithConfig(opts ...transform.EngineOpt) transform.Transformer {
+func newWithConfig(opts ...transform.EngineOpt) *dockerTransformer {
 	var e dockerTransformer
 	for _, o := range opts {
 		o(&e.BaseTransformer)
@@ -99,12 +173,41 @@ func newWithConfig(opts ...transform.EngineOpt) transform.Transformer {
 	if e.GraphRoot == "" {
 		e.GraphRoot = defaultDataRoot
 	}
+	if e.EventBus == nil {
+		e.EventBus = transform.NewEventBus()
+	}
 	e.Name = "docker"
+	e.recordRoot = defaultFinalizeRecordRoot
 	return &e
 }
 
 func (t *dockerTransformer) Init() error {
 	var retErr error
+	if retErr = checkFinalizeAction(t.finalize); retErr != nil {
+		return retErr
+	}
+	timeoutOpts, retErr := transform.ParsePhaseTimeouts(t.phaseTimeouts)
+	if retErr != nil {
+		logrus.Errorf("parse phase timeouts failed: %v", retErr)
+		return retErr
+	}
+	for _, o := range timeoutOpts {
+		o(&t.BaseTransformer)
+	}
+	remapPaths := t.remapPaths
+	if t.rulesFile != "" {
+		f, err := loadRules(t.rulesFile)
+		if err != nil {
+			logrus.Errorf("load rules failed: %v", err)
+			return err
+		}
+		t.rules = f.Rules
+		remapPaths = append(remapPaths, f.RemapPaths...)
+	}
+	if t.remaps, retErr = parsePathRemaps(remapPaths); retErr != nil {
+		logrus.Errorf("parse path remaps failed: %v", retErr)
+		return retErr
+	}
 	c := &http.Client{
 		Timeout: 2 * defaultTimeout,
 		Transport: &http.Transport{
@@ -120,15 +223,50 @@ func (t *dockerTransformer) Init() error {
 		logrus.Errorf("create docker client failed: %v", retErr)
 		return errors.Wrap(retErr, "create docker client failed")
 	}
+	info, retErr := t.dockerInfo()
+	if retErr != nil {
+		logrus.Errorf("get docker info failed: %v", retErr)
+		return retErr
+	}
+	if retErr = t.initUserns(info); retErr != nil {
+		logrus.Errorf("init userns remap failed: %v", retErr)
+		return errors.Wrap(retErr, "init userns remap failed")
+	}
 	t.sd, retErr = t.initStorageDriver()
 	if retErr != nil {
 		logrus.Errorf("init storage driver failed: %v", retErr)
 		return errors.Wrap(retErr, "init storage driver failed")
 	}
+	if retErr = t.initCgroupMapper(info); retErr != nil {
+		logrus.Errorf("init cgroup mapper failed: %v", retErr)
+		return errors.Wrap(retErr, "init cgroup mapper failed")
+	}
+	if retErr = reconcileRules(t.rules).checkCgroupParents(t.cgroups); retErr != nil {
+		logrus.Errorf("check rules failed: %v", retErr)
+		return retErr
+	}
+	if t.Start {
+		if t.isulad, retErr = isulad.GetIsuladTool().Client(); retErr != nil {
+			logrus.Errorf("create isulad client failed: %v", retErr)
+			return errors.Wrap(retErr, "create isulad client failed")
+		}
+	}
+	existing, retErr := isulad.GetIsuladTool().ContainerNames()
+	if retErr != nil {
+		logrus.Errorf("load names of isulad containers failed: %v", retErr)
+		return errors.Wrap(retErr, "load names of isulad containers failed")
+	}
+	t.nameAlloc, retErr = newNameAllocator(t.nameConflict, t.nameTemplate, existing)
+	if retErr != nil {
+		logrus.Errorf("init name allocator failed: %v", retErr)
+		return errors.Wrap(retErr, "init name allocator failed")
+	}
 	return t.initContainers()
 }
 
-func (t *dockerTransformer) Transform(ids []string, all bool, retCh chan transform.Result) {
+// Transform transforms the containers group by group until ctx is done, the groups
+// being transformed are rolled back and the ones not started yet fail
+func (t *dockerTransformer) Transform(ctx context.Context, ids []string, all bool, retCh chan transform.Result) {
 	if all {
 		t.ctrs.Range(func(k, _ interface{}) bool {
 			id, _ := k.(string)
@@ -137,43 +275,237 @@ func (t *dockerTransformer) Transform(ids []string, all bool, retCh chan transfo
 		})
 	}
 
-	signalWg := new(sync.WaitGroup)
-	signalCtx := t.handleSignal()
+	ids, results := t.selectContainers(ids, all)
+	groups, planResults := t.planGroups(ids, !t.noDeps)
+	t.sendResults(append(results, planResults...), retCh)
+
+	// only the containers of the groups taken by workers are paused and transformed at the same time
+	parallel := t.Parallel
+	if parallel <= 0 || parallel > len(groups) {
+		parallel = len(groups)
+	}
+	var (
+		mu      sync.Mutex
+		pending []*groupRun
+	)
+	forEachGroup(len(groups), parallel, func(i int) {
+		run, results := t.prepareGroup(ctx, groups[i])
+		switch {
+		case run == nil:
+		case t.Start:
+			mu.Lock()
+			pending = append(pending, run)
+			mu.Unlock()
+			return
+		default:
+			results = t.completeGroup(ctx, run)
+		}
+		t.report(groups[i].ids, results, retCh)
+	})
+	if len(pending) > 0 {
+		// isulad loads the containers on disk only when it starts, it is restarted once for all the groups
+		err := t.loadIntoIsulad(ctx)
+		forEachGroup(len(pending), parallel, func(i int) {
+			run := pending[i]
+			if err != nil {
+				run.rb.run()
+				t.report(run.g.ids, failGroup(run.g, "rolled back since %v", err), retCh)
+				return
+			}
+			t.report(run.g.ids, t.completeGroup(ctx, run), retCh)
+		})
+	}
+	close(retCh)
+}
 
+// forEachGroup calls fn with the indexes of n groups by parallel workers and waits for them
+func forEachGroup(n, parallel int, fn func(i int)) {
+	idxCh := make(chan int)
 	var wg sync.WaitGroup
-	for _, ctr := range ids {
+	for i := 0; i < parallel; i++ {
 		wg.Add(1)
-		go func(id string) {
-			var ret transform.Result
-			switch ctrID, ok := t.matchID(id); ok {
-			case notExist:
-				ret.Msg = fmt.Sprintf("transform %s: container was not found", id)
-			case hasBeenTransformed:
-				ret.Ok = true
-				ret.Msg = fmt.Sprintf("transform %s: container has been transformed", id)
-			case needTransform:
-				err := t.transform(ctrID, newRollback(signalCtx, signalWg))
-				if err != nil {
-					ret.Msg = fmt.Sprintf("transform %s: %s", id, err.Error())
-				} else {
-					ret.Ok = true
-					ret.Msg = fmt.Sprintf("transform %s: success", id)
-				}
-			default:
+		go func() {
+			defer wg.Done()
+			for idx := range idxCh {
+				fn(idx)
 			}
-			retCh <- ret
-			wg.Done()
-		}(ctr)
+		}()
+	}
+	for i := 0; i < n; i++ {
+		idxCh <- i
 	}
+	close(idxCh)
 	wg.Wait()
-	signalWg.Wait()
-	close(retCh)
 }
 
-func (t *dockerTransformer) transform(id string, rb *rollback) error {
-	var (
-		retErr error
+// report publishes and sends the results of the members of a group
+func (t *dockerTransformer) report(ids []string, results []transform.Result, retCh chan transform.Result) {
+	for idx := range results {
+		results[idx].Container = ids[idx]
+	}
+	t.sendResults(results, retCh)
+}
+
+// sendResults publishes the results of their containers and sends them to retCh
+func (t *dockerTransformer) sendResults(results []transform.Result, retCh chan transform.Result) {
+	for _, ret := range results {
+		t.EventBus.Publish(transform.Event{
+			Type:      transform.EventResult,
+			Container: ret.Container,
+			Msg:       ret.Msg,
+			Ok:        ret.Ok,
+		})
+		retCh <- ret
+	}
+}
+
+// groupRun is a group written to isulad and finalized on docker side, the rollback undoes all of it
+type groupRun struct {
+	g       transformGroup
+	rb      *rollback
+	names   []string
+	applied [][]string
+}
 
+// failGroup returns the same failure for all the members of the group
+func failGroup(g transformGroup, format string, a ...interface{}) []transform.Result {
+	results := make([]transform.Result, 0, len(g.ids))
+	for _, id := range g.ids {
+		results = append(results, transform.Result{Msg: fmt.Sprintf("transform %s: %s", id, fmt.Sprintf(format, a...))})
+	}
+	return results
+}
+
+// failMember returns the results of the group whose member idx failed with err, the members
+// before it are rolled back and the ones after it are skipped
+func failMember(g transformGroup, idx int, err error) []transform.Result {
+	results := make([]transform.Result, 0, len(g.ids))
+	id := g.ids[idx]
+	for j, other := range g.ids {
+		var msg string
+		switch {
+		case j < idx:
+			msg = fmt.Sprintf("transform %s: rolled back since %s of the same group failed", other, id)
+		case j > idx:
+			msg = fmt.Sprintf("transform %s: skipped since %s of the same group failed", other, id)
+		default:
+			msg = fmt.Sprintf("transform %s: %s", other, err.Error())
+		}
+		results = append(results, transform.Result{Msg: msg})
+	}
+	logrus.Errorf("transform group %v failed at %d: %v", g.ids, idx, err)
+	return results
+}
+
+// prepareGroup transforms the members of the group one by one and finalizes their docker containers,
+// all of them are rolled back if any member fails. It returns the group to be completed, or one result
+// for each member in the order of the members if the group fails
+func (t *dockerTransformer) prepareGroup(ctx context.Context, g transformGroup) (*groupRun, []transform.Result) {
+	if g.err != nil {
+		return nil, failGroup(g, "%v", g.err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, failGroup(g, "%v", contextCause(ctx, ctx, 0, err))
+	}
+
+	run := &groupRun{
+		g:       g,
+		rb:      newRollback(t.EventBus),
+		names:   make([]string, len(g.ids)),
+		applied: make([][]string, len(g.ids)),
+	}
+	for idx, id := range g.ids {
+		var err error
+		ctrCtx, cancel := t.containerContext(ctx)
+		run.names[idx], run.applied[idx], err = t.transform(ctrCtx, id, run.rb)
+		err = contextCause(ctx, ctrCtx, t.ContainerTimeout, err)
+		cancel()
+		if err != nil {
+			run.rb.run()
+			return nil, failMember(g, idx, err)
+		}
+	}
+	// the docker containers release their resources before the containers start in isulad
+	if err := t.finalizeGroup(ctx, g.ids, run.rb); err != nil {
+		run.rb.run()
+		return nil, failGroup(g, "rolled back since %v", contextCause(ctx, ctx, 0, err))
+	}
+	return run, nil
+}
+
+// completeGroup starts the members of a prepared group in isulad if required, providers first, and
+// removes their docker containers if required. There is one result for each member
+func (t *dockerTransformer) completeGroup(ctx context.Context, run *groupRun) []transform.Result {
+	if t.Start {
+		for idx, id := range run.g.ids {
+			ctrCtx, cancel := t.containerContext(ctx)
+			err := contextCause(ctx, ctrCtx, t.ContainerTimeout, t.startContainer(ctrCtx, id, run.rb))
+			cancel()
+			if err != nil {
+				results := failMember(run.g, idx, err)
+				if rbErr := run.rb.run(); rbErr != nil {
+					logrus.Errorf("rollback of group %v stopped, clean it up manually: %v", run.g.ids, rbErr)
+					for j := range results {
+						results[j].Msg += fmt.Sprintf(", but the rollback stopped and needs manual cleanup: %v", rbErr)
+					}
+				}
+				return results
+			}
+		}
+	}
+	t.removeFinalized(ctx, run.g.ids)
+	results := make([]transform.Result, 0, len(run.g.ids))
+	for idx, id := range run.g.ids {
+		msg := fmt.Sprintf("transform %s: success, name %s", id, run.names[idx])
+		if len(run.applied[idx]) > 0 {
+			msg += ", rules " + strings.Join(run.applied[idx], ",")
+		}
+		results = append(results, transform.Result{Ok: true, Msg: msg})
+	}
+	return results
+}
+
+// containerContext limits the transformation of a container by the container timeout
+func (t *dockerTransformer) containerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if t.ContainerTimeout > 0 {
+		return context.WithTimeout(ctx, t.ContainerTimeout)
+	}
+	return ctx, func() {}
+}
+
+// loadIntoIsulad makes isulad load the containers written to disk by restarting it
+func (t *dockerTransformer) loadIntoIsulad(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, isuladReloadTimeout)
+	defer cancel()
+	if err := t.isulad.Reload(ctx); err != nil {
+		logrus.Errorf("reload isulad failed: %v", err)
+		return errors.Wrap(err, "reload isulad")
+	}
+	return nil
+}
+
+// contextCause annotates err with the cause if the context of the caller or the container
+// limited by timeout is done
+func contextCause(parent, ctr context.Context, timeout time.Duration, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case parent.Err() == context.Canceled:
+		return errors.Wrap(err, "canceled")
+	case parent.Err() == context.DeadlineExceeded:
+		return errors.Wrap(err, "deadline exceeded")
+	case ctr.Err() == context.DeadlineExceeded:
+		return errors.Wrapf(err, "container timed out after %s", timeout)
+	default:
+	}
+	return err
+}
+
+// transform transforms container id and returns its name in isulad and the names of the rules applied,
+// the phases are stopped once ctx is done
+func (t *dockerTransformer) transform(ctx context.Context, id string, rb *rollback) (_ string, _ []string,
+	retErr error) {
+	var (
 		hostCfg *types.IsuladHostConfig
 		logCfg  *container.LogConfig
 		v2Cfg   *types.IsuladV2Config
@@ -182,68 +514,73 @@ func (t *dockerTransformer) transform(id string, rb *rollback) error {
 		oldRootFs string
 	)
 
-	rb.wait()
+	logrus.Infof("start to transform %s", id)
+	phases := newPhaseTracker(t.EventBus, t.PhaseTimeouts, id)
 	defer func() {
-		if retErr != nil {
-			rb.run()
-		}
-		rb.close()
+		retErr = phases.finish(retErr)
 	}()
 
-	logrus.Infof("start to transform %s", id)
-
 	// before transform, pause container to suspend all processes in a container
-	retErr = t.client.ContainerPause(context.Background(), id)
+	phaseCtx := phases.enter(ctx, transform.PhasePause)
+	retErr = t.client.ContainerPause(phaseCtx, id)
 	if retErr != nil && !strings.Contains(retErr.Error(), "already paused") {
 		logrus.Errorf("pause container %s failed: %v", id, retErr)
-		return errors.Wrap(retErr, "pause container")
+		return "", nil, errors.Wrap(retErr, "pause container")
 	}
 
 	// init
+	phaseCtx = phases.enter(ctx, transform.PhaseConfig)
 	iSulad := isulad.GetIsuladTool()
 	retErr = iSulad.PrepareBundleDir(id)
 	if retErr != nil {
 		logrus.Errorf("prepare bundle dir failed: %v", retErr)
-		return errors.Wrap(retErr, "prepare root dir")
+		return "", nil, errors.Wrap(retErr, "prepare root dir")
 	}
-	rb.register(func() {
+	rb.registerStep(id, "clean up bundle dir", func() {
 		logrus.Infof("rollback: clean up bundle dir of container %s", id)
 		if err := iSulad.Cleanup(id); err != nil {
-			logrus.Warnf("rollback: clean up bundle dir of container %s: %v", id, err)
+			t.warnf(id, "rollback: clean up bundle dir of container %s: %v", id, err)
 		}
 	})
 
+	rules, retErr := t.matchRules(id)
+	if retErr != nil {
+		logrus.Errorf("match rules failed: %v", retErr)
+		return "", nil, errors.Wrap(retErr, "match rules")
+	}
+
 	// start transform
 	// transform hostConfig: hostconfig.json
-	hostCfg, logCfg, retErr = t.transformHostConfig(id)
+	hostCfg, logCfg, retErr = t.transformHostConfig(id, rules)
 	if retErr != nil {
 		logrus.Errorf("transform hostconfig failed: %v", retErr)
-		return errors.Wrap(retErr, "transform hostconfig")
+		return "", nil, errors.Wrap(retErr, "transform hostconfig")
 	}
 
 	// transform config.v2: config.v2.json
 	reconcileOpts := append(genV2OptsFromHostCfg(hostCfg),
 		v2ConfigWithLogConfig(logCfg, filepath.Join(iSulad.GetRuntimePath(), id)))
-	v2Cfg, retErr = t.transformV2Config(id, reconcileOpts...)
+	v2Cfg, retErr = t.transformV2Config(id, hostCfg.StorageOpt, rules, reconcileOpts...)
 	if retErr != nil {
 		logrus.Errorf("transform configV2 failed: %v", retErr)
-		return errors.Wrap(retErr, "transform configV2")
+		return "", nil, errors.Wrap(retErr, "transform configV2")
 	}
-	rb.register(func() {
+	rb.registerStep(id, "clean up storage register", func() {
 		logrus.Infof("rollback: clean up storage register of container %s", id)
 		t.sd.Cleanup(id)
+		t.nameAlloc.release(id, v2Cfg.CommonConfig.Name)
 	})
 
 	// share shm : mounts/shm
-	retErr = iSulad.PrepareShm(v2Cfg.CommonConfig.ShmPath, hostCfg.ShmSize)
+	retErr = iSulad.PrepareShm(v2Cfg.CommonConfig.ShmPath, hostCfg.ShmSize, v2Cfg.CommonConfig.MountLabel)
 	if retErr != nil {
 		logrus.Errorf("prepare share shm failed: %v", retErr)
-		return errors.Wrap(retErr, "prepare share shm")
+		return "", nil, errors.Wrap(retErr, "prepare share shm")
 	}
-	rb.register(func() {
+	rb.registerStep(id, "umount share shm", func() {
 		logrus.Infof("rollback: umount share shm of container %s path %s", id, v2Cfg.CommonConfig.ShmPath)
 		if umountErr := unix.Unmount(v2Cfg.CommonConfig.ShmPath, unix.MNT_DETACH); umountErr != nil {
-			logrus.Warnf("umount %s err: %v", v2Cfg.CommonConfig.ShmPath, umountErr)
+			t.warnf(id, "umount %s err: %v", v2Cfg.CommonConfig.ShmPath, umountErr)
 		}
 	})
 
@@ -252,45 +589,146 @@ func (t *dockerTransformer) transform(id string, rb *rollback) error {
 	for idx := range files {
 		srcF := v2Cfg.CommonConfig.GetOriginNetworkFile(files[idx])
 		destF := iSulad.GetNetworkFilePath(id, files[idx])
-		retErr = exec.Command("cp", "-a", srcF, destF).Run()
+		retErr = exec.CommandContext(phaseCtx, "cp", "-a", srcF, destF).Run()
 		if retErr != nil {
 			logrus.Errorf("copy %s to %s failed", srcF, destF)
-			return errors.Wrapf(retErr, "copy %s to %s failed", srcF, destF)
+			return "", nil, errors.Wrapf(retErr, "copy %s to %s failed", srcF, destF)
+		}
+		if retErr = utils.Relabel(destF, v2Cfg.CommonConfig.MountLabel); retErr != nil {
+			logrus.Errorf("relabel %s failed: %v", destF, retErr)
+			return "", nil, errors.Wrapf(retErr, "relabel %s", destF)
 		}
 	}
+	if retErr = relabelMounts(v2Cfg.CommonConfig); retErr != nil {
+		return "", nil, errors.Wrap(retErr, "relabel bind mounts")
+	}
 
 	// oci spec: config.json
-	ociCfg, oldRootFs, retErr = t.transformOciConfig(id, v2Cfg.CommonConfig, hostCfg)
+	ociCfg, oldRootFs, retErr = t.transformOciConfig(id, v2Cfg.CommonConfig, hostCfg, rules)
 	if retErr != nil {
 		logrus.Errorf("transform oci spec config failed: %v", retErr)
-		return errors.Wrap(retErr, "transform oci spec")
+		return "", nil, errors.Wrap(retErr, "transform oci spec")
+	}
+	// the seccomp profile is known after the oci spec is converted
+	retErr = iSulad.SaveConfig(id, v2Cfg, iSulad.MarshalIndent, iSulad.GetConfigV2Path)
+	if retErr != nil {
+		logrus.Errorf("save v2 config to file %s failed", iSulad.GetConfigV2Path(id))
+		return "", nil, errors.Wrap(retErr, "save config.v2.json")
 	}
 
 	// copy RWlayer
-	retErr = t.sd.TransformRWLayer(v2Cfg, oldRootFs)
+	phaseCtx = phases.enter(ctx, transform.PhaseRWLayer)
+	rwSize, retErr := t.checkRWLayerSize(phaseCtx, v2Cfg, oldRootFs, hostCfg.StorageOpt)
+	if retErr != nil {
+		logrus.Errorf("check RWLayer size failed: %v", retErr)
+		return "", nil, errors.Wrap(retErr, "check RWLayer size")
+	}
+	retErr = t.sd.TransformRWLayer(phaseCtx, v2Cfg, oldRootFs, rwSize)
 	if retErr != nil {
 		logrus.Errorf("storage driver transform RWLayer failed: %v", retErr)
-		return errors.Wrap(retErr, "transform RWLayer")
+		return "", nil, errors.Wrap(retErr, "transform RWLayer")
+	}
+	if t.Verify {
+		phaseCtx = phases.enter(ctx, transform.PhaseVerify)
+		retErr = t.sd.VerifyRWLayer(phaseCtx, v2Cfg, oldRootFs)
+		if retErr != nil {
+			logrus.Errorf("storage driver verify RWLayer failed: %v", retErr)
+			return "", nil, errors.Wrap(retErr, "verify RWLayer")
+		}
 	}
 
 	// lcr_create: config  ocihooks.json  seccomp
-	ociCfgData, err := json.Marshal(ociCfg)
-	if err != nil {
-		logrus.Errorf("marshal oci config failed: %s", err)
-		return errors.Wrap(err, "marshal oci config")
+	// lcr create can not be interrupted, give up before it if the container is canceled
+	phaseCtx = phases.enter(ctx, transform.PhaseCreate)
+	if retErr = phaseCtx.Err(); retErr != nil {
+		return "", nil, retErr
+	}
+	ociCfgData, retErr := json.Marshal(ociCfg)
+	if retErr != nil {
+		logrus.Errorf("marshal oci config failed: %s", retErr)
+		return "", nil, errors.Wrap(retErr, "marshal oci config")
 	}
 	retErr = iSulad.LcrCreate(id, ociCfgData)
 	if retErr != nil {
 		logrus.Error("lcr create failed")
-		return retErr
+		return "", nil, retErr
 	}
 
 	logrus.Infof("transform %s successfully", id)
 
+	if len(rules) > 0 {
+		logrus.Infof("rules %v applied to %s", rules.names(), id)
+	}
+	return v2Cfg.CommonConfig.Name, rules.names(), nil
+}
+
+// startContainer starts the transformed container in isulad and waits until it is running
+// and healthy, the container is stopped and removed from isulad on rollback
+func (t *dockerTransformer) startContainer(ctx context.Context, id string, rb *rollback) (retErr error) {
+	phases := newPhaseTracker(t.EventBus, t.PhaseTimeouts, id)
+	ctx = phases.enter(ctx, transform.PhaseStart)
+	defer func() {
+		retErr = phases.finish(retErr)
+	}()
+	rb.registerStep(id, "remove container from isulad", func() {
+		logrus.Infof("rollback: remove container %s from isulad", id)
+		// the files and the docker container are kept as long as isulad may run the container
+		if err := removeFromIsulad(context.Background(), t.isulad, id); err != nil {
+			t.warnf(id, "rollback: remove container %s from isulad: %v", id, err)
+			rb.stop(errors.Wrapf(err, "remove container %s from isulad", id))
+		}
+	})
+
+	if t.StartTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.StartTimeout)
+		defer cancel()
+	}
+	if err := t.isulad.Start(ctx, id); err != nil {
+		logrus.Errorf("start container %s in isulad failed: %v", id, err)
+		return errors.Wrap(err, "start in isulad")
+	}
+	if err := t.isulad.WaitReady(ctx, id); err != nil {
+		logrus.Errorf("container %s is not ready in isulad: %v", id, err)
+		return errors.Wrap(err, "wait for ready in isulad")
+	}
+	logrus.Infof("container %s is ready in isulad", id)
 	return nil
 }
 
-func (t *dockerTransformer) transformHostConfig(id string) (*types.IsuladHostConfig, *container.LogConfig, error) {
+// resolveSharedNamespaces rewrites the container modes of namespaces to container:<full id>, as
+// iSulad looks up the container by the mode and joins its namespaces when the container starts.
+// The container referred to must have been transformed to iSulad.
+func (t *dockerTransformer) resolveSharedNamespaces(h *types.IsuladHostConfig) error {
+	modes := []struct {
+		typ  specs.LinuxNamespaceType
+		mode *string
+	}{
+		{specs.IPCNamespace, &h.IpcMode},
+		{specs.PIDNamespace, &h.PidMode},
+		{specs.NetworkNamespace, &h.NetworkMode},
+		{specs.UTSNamespace, &h.UTSMode},
+		{specs.UserNamespace, &h.UsernsMode},
+	}
+	for _, m := range modes {
+		if !strings.HasPrefix(*m.mode, containerModePrefix) {
+			continue
+		}
+		ref := strings.TrimPrefix(*m.mode, containerModePrefix)
+		id, ok, err := t.resolveDep(ref, t.containerNames())
+		if err != nil {
+			return errors.Wrapf(err, "container %s sharing %s namespace", ref, m.typ)
+		}
+		if !ok {
+			return errors.Errorf("container %s sharing %s namespace is not in iSulad", ref, m.typ)
+		}
+		*m.mode = containerModePrefix + id
+	}
+	return nil
+}
+
+func (t *dockerTransformer) transformHostConfig(id string,
+	rules reconcileRules) (*types.IsuladHostConfig, *container.LogConfig, error) {
 	var isuladHostCfg types.IsuladHostConfig
 	var l container.LogConfig
 	var hostCfg = struct {
@@ -320,8 +758,29 @@ func (t *dockerTransformer) transformHostConfig(id string) (*types.IsuladHostCon
 		return nil, nil, errors.Wrap(err, "unmarshal host config data")
 	}
 
+	if err := t.resolveSharedNamespaces(&isuladHostCfg); err != nil {
+		logrus.Errorf("resolve shared namespaces of container %s failed: %v", id, err)
+		return nil, nil, errors.Wrap(err, "resolve shared namespaces")
+	}
+
 	iSulad := isulad.GetIsuladTool()
+	usernsMode := isuladHostCfg.UsernsMode
 	reconcileHostConfig(&isuladHostCfg, iSulad.Runtime())
+	if t.userns != nil {
+		t.adaptUsernsHostConfig(&isuladHostCfg, usernsMode)
+	}
+	isuladHostCfg.CgroupParent = t.cgroups.convertParent(isuladHostCfg.CgroupParent)
+	if t.cgroups.unified {
+		adaptUnifiedHostConfig(&isuladHostCfg)
+	}
+	if err := t.remaps.applyHostConfig(&isuladHostCfg); err != nil {
+		logrus.Errorf("remap paths of host config of container %s failed: %v", id, err)
+		return nil, nil, err
+	}
+	if err := rules.applyHostConfig(&isuladHostCfg); err != nil {
+		logrus.Errorf("apply rules to host config of container %s failed: %v", id, err)
+		return nil, nil, err
+	}
 	err = iSulad.SaveConfig(id, &isuladHostCfg, iSulad.MarshalIndent, iSulad.GetHostCfgPath)
 	if err != nil {
 		logrus.Errorf("save host config to file %s failed", iSulad.GetHostCfgPath(id))
@@ -355,7 +814,8 @@ func (t *dockerTransformer) loadV2Config(id string) (*types.DockerV2Config, erro
 	return &dockerV2Cfg, nil
 }
 
-func (t *dockerTransformer) transformV2Config(id string, opts ...v2ConfigReconcileOpt) (*types.IsuladV2Config, error) {
+func (t *dockerTransformer) transformV2Config(id string, storageOpt map[string]string, rules reconcileRules,
+	opts ...v2ConfigReconcileOpt) (*types.IsuladV2Config, error) {
 	var (
 		iSuladState  = types.ContainerState{}
 		iSuladCommon = types.CommonConfig{}
@@ -397,31 +857,49 @@ func (t *dockerTransformer) transformV2Config(id string, opts ...v2ConfigReconci
 	}
 
 	iSuladV2Cfg.Image = ctr.ImageID
+	iSuladCommon.OriginDriver = ctr.Driver
 
 	basePath := filepath.Join(iSulad.GetRuntimePath(), id)
 	opts = append(opts, []v2ConfigReconcileOpt{
 		v2ConfigWithImage(ctr.Config.Image),
-		v2ConfigWithCgroupParent(ctr.CgroupParent),
+		v2ConfigWithCgroupParent(ctr.CgroupParent, t.cgroups),
 	}...)
 	reconcileV2Config(&iSuladV2Cfg, basePath, opts...)
+	if err := t.remaps.applyV2Config(&iSuladV2Cfg); err != nil {
+		logrus.Errorf("remap paths of v2 config of container %s failed: %v", id, err)
+		return nil, err
+	}
+	rules.applyV2Config(&iSuladV2Cfg)
 
-	iSuladCommon.BaseFs, err = t.sd.GenerateRootFs(id, iSuladCommon.Image)
+	name := iSuladCommon.Name
+	iSuladCommon.Name, err = t.nameAlloc.allocate(id, name)
+	if err != nil {
+		logrus.Errorf("allocate name for container %s failed: %v", id, err)
+		return nil, errors.Wrap(err, "allocate name")
+	}
+	if iSuladCommon.Name != name {
+		logrus.Infof("name %s of container %s is in use, renamed to %s", name, id, iSuladCommon.Name)
+	}
+
+	iSuladCommon.BaseFs, err = t.sd.GenerateRootFs(id, iSuladCommon.Image, storageOpt)
 	if err != nil {
 		logrus.Errorf("storage driver generate new rootfs failed: %v", err)
+		t.nameAlloc.release(id, iSuladCommon.Name)
 		return nil, errors.Wrap(err, "generate new rootfs")
 	}
 
 	err = iSulad.SaveConfig(id, &iSuladV2Cfg, iSulad.MarshalIndent, iSulad.GetConfigV2Path)
 	if err != nil {
 		logrus.Errorf("save v2 config to file %s failed", iSulad.GetConfigV2Path(id))
+		t.nameAlloc.release(id, iSuladCommon.Name)
 		return nil, errors.Wrap(err, "save config.v2.json")
 	}
 
 	return &iSuladV2Cfg, nil
 }
 
-func (t *dockerTransformer) transformOciConfig(id string,
-	commonCfg *types.CommonConfig, hostCfg *types.IsuladHostConfig) (*specs.Spec, string, error) {
+func (t *dockerTransformer) transformOciConfig(id string, commonCfg *types.CommonConfig,
+	hostCfg *types.IsuladHostConfig, rules reconcileRules) (*specs.Spec, string, error) {
 	var ociConfig = specs.Spec{}
 
 	// load
@@ -447,7 +925,30 @@ func (t *dockerTransformer) transformOciConfig(id string,
 
 	// reconcile
 	oldRoot := ociConfig.Root.Path
-	reconcileOciConfig(&ociConfig, commonCfg, hostCfg)
+	reconcileOciConfig(&ociConfig, commonCfg, hostCfg, t.cgroups)
+	t.adaptUsernsOciConfig(&ociConfig)
+	seccompExt, err := loadSeccompExtensions(data)
+	if err != nil {
+		logrus.Errorf("load seccomp config of container %s failed: %v", id, err)
+		return nil, "", err
+	}
+	if commonCfg.SeccompProfile, err = convertSeccomp(&ociConfig, commonCfg.SeccompProfile, seccompExt); err != nil {
+		logrus.Errorf("convert seccomp config of container %s failed: %v", id, err)
+		return nil, "", errors.Wrap(err, "convert seccomp config")
+	}
+	for _, reason := range droppedSeccompFields(seccompExt) {
+		t.warnf(id, "container %s: %s", id, reason)
+	}
+	if t.cgroups.unified {
+		for _, reason := range adaptUnifiedResources(&ociConfig) {
+			t.warnf(id, "container %s: %s, removed", id, reason)
+		}
+	}
+	if err := t.remaps.applyOciConfig(&ociConfig); err != nil {
+		logrus.Errorf("remap paths of oci config of container %s failed: %v", id, err)
+		return nil, "", err
+	}
+	rules.applyOciConfig(&ociConfig)
 
 	// save
 	iSulad := isulad.GetIsuladTool()
@@ -460,13 +961,75 @@ func (t *dockerTransformer) transformOciConfig(id string,
 	return &ociConfig, oldRoot, nil
 }
 
+// checkRWLayerSize makes sure that the read-write layer data fits the space left for it by the storage
+// driver under the size limit of the new rootfs and returns its size, which is shared with the copy progress.
+// The size is computed only for the limit as the container is paused, -1 is returned if there is no limit
+func (t *dockerTransformer) checkRWLayerSize(ctx context.Context, ctr *types.IsuladV2Config, oldRootFs string,
+	storageOpt map[string]string) (int64, error) {
+	sizeOpt, ok := storageOpt[storageOptSize]
+	if !ok {
+		return -1, nil
+	}
+	limit, err := units.RAMInBytes(sizeOpt)
+	if err != nil {
+		return -1, errors.Wrapf(err, "parse storage opt %s=%s", storageOptSize, sizeOpt)
+	}
+	size, err := t.sd.RWLayerSize(ctx, ctr, oldRootFs)
+	if err != nil {
+		return -1, err
+	}
+	space, err := t.sd.RWLayerSpace(ctr, limit)
+	if err != nil {
+		return -1, err
+	}
+	logrus.Infof("read-write layer of container %s uses %d bytes, space: %d bytes, limit: %s",
+		ctr.CommonConfig.ID, size, space, sizeOpt)
+	if size > space {
+		return -1, errors.Errorf("read-write layer uses %s, exceeds the space %s of the new rootfs limited to %s",
+			units.BytesSize(float64(size)), units.BytesSize(float64(space)), units.BytesSize(float64(limit)))
+	}
+	return size, nil
+}
+
+// dockerInfo returns the system-wide information of docker
+func (t *dockerTransformer) dockerInfo() (dockertypes.Info, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	info, err := t.client.Info(ctx)
+	if err != nil {
+		return info, errors.Wrap(err, "get docker info failed")
+	}
+	return info, nil
+}
+
 func (t *dockerTransformer) initStorageDriver() (transform.StorageDriver, error) {
+	var local *dmLocalDiff
+	switch t.dmDiff {
+	case "", dmDiffAPI:
+	case dmDiffLocal:
+		local = newDmLocalDiff(t.GraphRoot)
+	default:
+		return nil, fmt.Errorf("unsupported devicemapper diff mode: %s", t.dmDiff)
+	}
+	sources := newChangeSources(t.GraphRoot, t.client, local)
+
+	var limiter *utils.RateLimiter
+	if t.copyBps != "" {
+		bps, err := units.RAMInBytes(t.copyBps)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid copy bps %s", t.copyBps)
+		}
+		if bps > 0 {
+			limiter = utils.NewRateLimiter(bps)
+		}
+	}
+
 	iSulad := isulad.GetIsuladTool()
 	switch iSulad.StorageType() {
 	case transform.Overlay2:
-		return newOverlayDriver(iSulad.BaseStorageDriver()), nil
+		return newOverlayDriver(iSulad.BaseStorageDriver(), sources, limiter, t.EventBus, t.ownerShift()), nil
 	case transform.DeviceMapper:
-		return newDeviceMapperDriver(iSulad.BaseStorageDriver(), t.client), nil
+		return newDeviceMapperDriver(iSulad.BaseStorageDriver(), sources, limiter, t.EventBus, t.ownerShift()), nil
 	default:
 	}
 	return nil, fmt.Errorf("unsupported storage driver type: %s", iSulad.StorageType())
@@ -484,40 +1047,118 @@ func (t *dockerTransformer) initContainers() error {
 			t.ctrs.Store(info.Name(), false)
 		}
 	}
-	return nil
+	return t.initSelection()
 }
 
-func (t *dockerTransformer) matchID(id string) (fullID string, status containerStatus) {
-	t.ctrs.Range(func(k, v interface{}) bool {
-		fullID, status = "", notExist
-		tmpID, _ := k.(string)
-		transformed, _ := v.(bool)
-		if strings.HasPrefix(tmpID, id) {
-			if !transformed {
-				t.ctrs.Store(k, true)
-				status = needTransform
-			} else {
-				status = hasBeenTransformed
-			}
-			fullID = tmpID
-			return false
+// initSelection finds the containers left out by the filters and excludes
+func (t *dockerTransformer) initSelection() error {
+	filter, err := parseFilters(t.filters)
+	if err != nil {
+		return errors.Wrap(err, "parse filters")
+	}
+	t.filtered = make(map[string]bool)
+	t.excluded = make(map[string]bool)
+	names := t.containerNames()
+	for _, ref := range t.excludes {
+		id, err := t.resolveRef(ref, names)
+		if errors.Cause(err) == errCtrNotFound {
+			logrus.Warnf("container %s to be excluded was not found", ref)
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "exclude %s", ref)
+		}
+		t.excluded[id] = true
+	}
+	if filter == nil {
+		return nil
+	}
+	t.ctrs.Range(func(k, _ interface{}) bool {
+		id, _ := k.(string)
+		cfg, err := t.loadV2Config(id)
+		if err != nil {
+			logrus.Warnf("load config of container %s for filters failed: %v", id, err)
+			t.filtered[id] = true
+			return true
+		}
+		target := &filterTarget{cfg: cfg}
+		if refs, err := t.loadRefs(id); err == nil {
+			target.networkMode = refs.NetworkMode
+		}
+		if !filter.match(target) {
+			t.filtered[id] = true
 		}
 		return true
 	})
-	return
+	return nil
 }
 
-func (t *dockerTransformer) handleSignal() context.Context {
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
-	ctx, cancel := context.WithCancel(context.Background())
-	go func() {
-		if s, ok := <-sigCh; ok {
-			logrus.Infof("catch signal %v", s)
-			cancel()
-			return
+// selectContainers resolves ids to the full IDs, merges the ones referring to the same container
+// and leaves out the containers excluded or not matching the filters, the containers given
+// explicitly are reported unless all is set
+func (t *dockerTransformer) selectContainers(ids []string, all bool) ([]string, []transform.Result) {
+	var (
+		selected []string
+		results  []transform.Result
+		names    = t.containerNames()
+		seen     = make(map[string]string)
+	)
+	for _, id := range ids {
+		ctrID, err := t.resolveRef(id, names)
+		if err != nil {
+			results = append(results, transform.Result{
+				Container: id,
+				Msg:       fmt.Sprintf("transform %s: %v", id, err),
+				Skipped:   transform.SkipUnresolved,
+			})
+			continue
 		}
-	}()
+		if first, ok := seen[ctrID]; ok {
+			logrus.Infof("%s refers to the same container %s as %s, merged", id, ctrID, first)
+			continue
+		}
+		seen[ctrID] = id
+
+		var reason, skipped string
+		switch {
+		case t.excluded[ctrID]:
+			reason, skipped = "excluded", transform.SkipExcluded
+		case t.filtered[ctrID]:
+			reason, skipped = "not matching the filters", transform.SkipFiltered
+		default:
+			selected = append(selected, ctrID)
+			continue
+		}
+		if all {
+			logrus.Infof("skip container %s: %s", ctrID, reason)
+			continue
+		}
+		results = append(results, transform.Result{
+			Container: ctrID,
+			Ok:        true,
+			Msg:       fmt.Sprintf("transform %s: skipped, %s", id, reason),
+			Skipped:   skipped,
+		})
+	}
+	return selected, results
+}
+
+// matchID claims the container referred to by id, which is resolved by resolveRef
+func (t *dockerTransformer) matchID(id string) (fullID string, status containerStatus) {
+	fullID, err := t.resolveRef(id, t.containerNames())
+	if err != nil {
+		return "", notExist
+	}
+	v, _ := t.ctrs.Load(fullID)
+	if transformed, _ := v.(bool); transformed {
+		return fullID, hasBeenTransformed
+	}
+	t.ctrs.Store(fullID, true)
+	return fullID, needTransform
+}
 
-	return ctx
+// warnf logs the warning of container id and publishes it
+func (t *dockerTransformer) warnf(id, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logrus.Warn(msg)
+	t.EventBus.Publish(transform.Event{Type: transform.EventWarning, Container: id, Msg: msg})
 }
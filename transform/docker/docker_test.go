@@ -13,6 +13,7 @@
 package docker
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -28,6 +29,7 @@ import (
 	. "github.com/golang/mock/gomock"
 	. "github.com/google/go-cmp/cmp"
 	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/urfave/cli"
 	"isula.org/isula-transform/pkg/isulad"
@@ -62,7 +64,9 @@ func TestNew(t *testing.T) {
 					Name:      "docker",
 					StateRoot: "/var/run/docker",
 					GraphRoot: "/var/lib/docker",
+					EventBus:  transform.NewEventBus(),
 				},
+				recordRoot: defaultFinalizeRecordRoot,
 			}
 			So(reflect.DeepEqual(got, expect), ShouldBeTrue)
 		})
@@ -79,7 +83,9 @@ func TestNew(t *testing.T) {
 					Name:      "docker",
 					StateRoot: "/test/run/docker",
 					GraphRoot: "/test/lib/docker",
+					EventBus:  transform.NewEventBus(),
 				},
+				recordRoot: defaultFinalizeRecordRoot,
 			}
 			So(reflect.DeepEqual(got, expect), ShouldBeTrue)
 		})
@@ -101,7 +107,7 @@ func Test_dockerConfigEngine_transformHostConfig(t *testing.T) {
 
 	Convey("Test_dockerConfigEngine_transformHostConfig", t, func() {
 		Convey("container not exist", func() {
-			_, _, err := dt.transformHostConfig(notExistCtrID)
+			_, _, err := dt.transformHostConfig(notExistCtrID, nil)
 			So(err.Error(), ShouldContainSubstring, "no such file or directory")
 		})
 
@@ -110,12 +116,12 @@ func Test_dockerConfigEngine_transformHostConfig(t *testing.T) {
 				incorrectFile, hostCfgFile, false); err != nil {
 				t.Skipf("prepare test incorrect json format failed: %v", err)
 			}
-			_, _, err := dt.transformHostConfig(incorrectCtrID)
+			_, _, err := dt.transformHostConfig(incorrectCtrID, nil)
 			So(err.Error(), ShouldContainSubstring, "invalid character")
 		})
 
 		Convey("transform successfully", func() {
-			hGot, lGot, err := dt.transformHostConfig(transformTestCtrID)
+			hGot, lGot, err := dt.transformHostConfig(transformTestCtrID, nil)
 			hExpect := &types.IsuladHostConfig{
 				NetworkMode: "host",
 				Runtime:     "lcr",
@@ -157,14 +163,14 @@ func Test_dockerConfigEngine_transformV2Config(t *testing.T) {
 	defer ctrl.Finish()
 	sd := NewMockStorageDriver(ctrl)
 	InOrder(
-		sd.EXPECT().GenerateRootFs(Any(), Any()).Return("", fmt.Errorf("mock generate failed")),
-		sd.EXPECT().GenerateRootFs(Any(), Any()).Return("newRootFS", nil),
+		sd.EXPECT().GenerateRootFs(Any(), Any(), Any()).Return("", fmt.Errorf("mock generate failed")),
+		sd.EXPECT().GenerateRootFs(Any(), Any(), Any()).Return("newRootFS", nil),
 	)
 	dt.sd = sd
 
 	Convey("Test_dockerConfigEngine_transformV2Config", t, func() {
 		Convey("container not exist", func() {
-			_, err := dt.transformV2Config(notExistCtrID)
+			_, err := dt.transformV2Config(notExistCtrID, nil, nil)
 			So(err.Error(), ShouldContainSubstring, "no such file or directory")
 		})
 
@@ -172,13 +178,13 @@ func Test_dockerConfigEngine_transformV2Config(t *testing.T) {
 			if err := initDockerTransformTest(tmpdir, incorrectCtrID, incorrectFile, configV2File, false); err != nil {
 				t.Skipf("prepare test incorrect json format failed: %v", err)
 			}
-			_, err := dt.transformV2Config(incorrectCtrID)
+			_, err := dt.transformV2Config(incorrectCtrID, nil, nil)
 			So(err.Error(), ShouldContainSubstring, "invalid character")
 		})
 
 		Convey("load successfully", func() {
 			Convey("generate rootfs failed", func() {
-				_, err := dt.transformV2Config(transformTestCtrID)
+				_, err := dt.transformV2Config(transformTestCtrID, nil, nil)
 				So(err.Error(), ShouldContainSubstring, "mock generate failed")
 			})
 
@@ -186,7 +192,7 @@ func Test_dockerConfigEngine_transformV2Config(t *testing.T) {
 				opts := []v2ConfigReconcileOpt{
 					v2ConfigWithLogConfig(nil, ""),
 				}
-				got, err := dt.transformV2Config(transformTestCtrID, opts...)
+				got, err := dt.transformV2Config(transformTestCtrID, nil, nil, opts...)
 				expect := &types.IsuladV2Config{
 					CommonConfig: &types.CommonConfig{
 						Path: "bash",
@@ -223,6 +229,7 @@ func Test_dockerConfigEngine_transformV2Config(t *testing.T) {
 						OriginHostnamePath:     "/var/lib/docker/containers/511e7f915e3f5dc09b36a49657125eea4b36a05f862ab3dd01e0b9b2/hostname",
 						OriginHostsPath:        "/var/lib/docker/containers/511e7f915e3f5dc09b36a49657125eea4b36a05f862ab3dd01e0b9b2/hosts",
 						OriginResolvConfPath:   "/var/lib/docker/containers/511e7f915e3f5dc09b36a49657125eea4b36a05f862ab3dd01e0b9b2/resolv.conf",
+						OriginDriver:           "overlay2",
 						ShmPath:                tmpdir + "/lib/isulad/engines/lcr/511e7f915e3f5dc09b36a49657125eea4b36a05f862ab3dd01e0b9b2/mounts/shm",
 						LogPath:                "none",
 						BaseFs:                 "newRootFS",
@@ -264,7 +271,7 @@ func Test_dockerConfigEngine_transformOciConfig(t *testing.T) {
 
 	Convey("Test_dockerConfigEngine_transformOciConfig", t, func() {
 		Convey("container not exist", func() {
-			_, _, err := dt.transformOciConfig(notExistCtrID, nil, nil)
+			_, _, err := dt.transformOciConfig(notExistCtrID, nil, nil, nil)
 			So(err.Error(), ShouldContainSubstring, "no such file or directory")
 		})
 
@@ -272,7 +279,7 @@ func Test_dockerConfigEngine_transformOciConfig(t *testing.T) {
 			if err := initDockerTransformTest(tmpdir, incorrectCtrID, incorrectFile, ociCfgFile, false); err != nil {
 				t.Skipf("prepare test incorrect json format failed: %v", err)
 			}
-			_, _, err := dt.transformOciConfig(incorrectCtrID, nil, nil)
+			_, _, err := dt.transformOciConfig(incorrectCtrID, nil, nil, nil)
 			So(err.Error(), ShouldContainSubstring, "invalid character")
 		})
 
@@ -304,7 +311,7 @@ func Test_dockerConfigEngine_transformOciConfig(t *testing.T) {
 					ShmSize: 123456,
 				}
 			)
-			got, gotOldRootFS, err := dt.transformOciConfig(transformTestCtrID, common, host)
+			got, gotOldRootFS, err := dt.transformOciConfig(transformTestCtrID, common, host, nil)
 			expect := &specs.Spec{
 				Version: "1.0.1-dev",
 				Process: &specs.Process{
@@ -358,6 +365,60 @@ func Test_dockerConfigEngine_transformOciConfig(t *testing.T) {
 	})
 }
 
+func Test_dockerTransformer_checkRWLayerSize(t *testing.T) {
+	ctrl := NewController(t)
+	defer ctrl.Finish()
+	sd := NewMockStorageDriver(ctrl)
+	sd.EXPECT().RWLayerSize(Any(), Any(), Any()).Return(int64(2<<30), nil).Times(3)
+	// the image takes most of the device limited to 20G
+	sd.EXPECT().RWLayerSpace(Any(), int64(20<<30)).Return(int64(1<<30), nil)
+	sd.EXPECT().RWLayerSpace(Any(), Any()).DoAndReturn(func(_ *types.IsuladV2Config, limit int64) (int64, error) {
+		return limit, nil
+	}).Times(2)
+	dt := &dockerTransformer{sd: sd}
+	ctr := &types.IsuladV2Config{CommonConfig: &types.CommonConfig{ID: transformTestCtrID}}
+
+	Convey("Test_dockerTransformer_checkRWLayerSize", t, func() {
+		Convey("no size limit", func() {
+			size, err := dt.checkRWLayerSize(context.Background(), ctr, "", nil)
+			So(err, ShouldBeNil)
+			So(size, ShouldEqual, -1)
+		})
+
+		Convey("no size limit with the progress subscribed", func() {
+			events := transform.NewEventBus()
+			_, unsubscribe := events.Subscribe(1)
+			defer unsubscribe()
+			dt := &dockerTransformer{sd: sd}
+			dt.EventBus = events
+			size, err := dt.checkRWLayerSize(context.Background(), ctr, "", nil)
+			So(err, ShouldBeNil)
+			So(size, ShouldEqual, -1)
+		})
+
+		Convey("invalid size limit", func() {
+			_, err := dt.checkRWLayerSize(context.Background(), ctr, "", map[string]string{"size": "10X"})
+			So(err, ShouldBeError)
+		})
+
+		Convey("fit the size limit", func() {
+			size, err := dt.checkRWLayerSize(context.Background(), ctr, "", map[string]string{"size": "10G"})
+			So(err, ShouldBeNil)
+			So(size, ShouldEqual, 2<<30)
+		})
+
+		Convey("exceed the size limit", func() {
+			_, err := dt.checkRWLayerSize(context.Background(), ctr, "", map[string]string{"size": "1G"})
+			So(err, ShouldBeError)
+		})
+
+		Convey("exceed the space left by the image", func() {
+			_, err := dt.checkRWLayerSize(context.Background(), ctr, "", map[string]string{"size": "20G"})
+			So(err, ShouldBeError)
+		})
+	})
+}
+
 func Test_dockerTransformer_initStorageDriver(t *testing.T) {
 	dt := &dockerTransformer{}
 	SkipConvey("Test_dockerTransformer_initStorageDriver", t, func() {
@@ -519,11 +580,256 @@ func initDockerTransformTest(repalceVar, id, src, dest string, initIsuladTool bo
 }
 
 func getTestDockerTransformer(tmpdir string) *dockerTransformer {
+	nameAlloc, _ := newNameAllocator(nameConflictFail, "", nil)
 	return &dockerTransformer{
 		BaseTransformer: transform.BaseTransformer{
 			Name:      "docker",
 			StateRoot: tmpdir + "/run/docker",
 			GraphRoot: tmpdir + "/lib/docker",
 		},
+		nameAlloc: nameAlloc,
+	}
+}
+
+func Test_dockerTransformer_Transform(t *testing.T) {
+	Convey("Test_dockerTransformer_Transform", t, func() {
+		dt := &dockerTransformer{
+			ctrs: &sync.Map{},
+		}
+		dt.Parallel = 2
+		var ids []string
+		for i := 0; i < 5; i++ {
+			id := fmt.Sprintf("%064d", i)
+			dt.ctrs.Store(id, true)
+			ids = append(ids, id)
+		}
+		dt.EventBus = transform.NewEventBus()
+		evCh, unsubscribe := dt.EventBus.Subscribe(len(ids) + 1)
+		defer unsubscribe()
+		retCh := make(chan transform.Result, len(ids)+1)
+		dt.Transform(context.Background(), append(ids, notExistCtrID), false, retCh)
+
+		var success, failed int
+		for ret := range retCh {
+			if ret.Ok {
+				success++
+			} else {
+				failed++
+			}
+			// the results of the selection and the planning are published as well
+			ev := <-evCh
+			So(ev.Type, ShouldEqual, transform.EventResult)
+			So(ev.Container, ShouldEqual, ret.Container)
+		}
+		So(success, ShouldEqual, len(ids))
+		So(failed, ShouldEqual, 1)
+	})
+}
+
+func Test_contextCause(t *testing.T) {
+	Convey("Test_contextCause", t, func() {
+		errCopy := errors.New("copy failed")
+		parent, cancel := context.WithCancel(context.Background())
+		ctr, ctrCancel := context.WithTimeout(parent, time.Nanosecond)
+		defer ctrCancel()
+		<-ctr.Done()
+
+		So(contextCause(parent, ctr, time.Nanosecond, nil), ShouldBeNil)
+		So(contextCause(parent, ctr, time.Nanosecond, errCopy), ShouldBeError,
+			"container timed out after 1ns: copy failed")
+		cancel()
+		So(contextCause(parent, ctr, time.Nanosecond, errCopy), ShouldBeError, "canceled: copy failed")
+		So(contextCause(context.Background(), context.Background(), 0, errCopy), ShouldEqual, errCopy)
+	})
+}
+
+func Test_phaseTracker(t *testing.T) {
+	Convey("Test_phaseTracker", t, func() {
+		p := newPhaseTracker(nil, map[string]time.Duration{transform.PhaseRWLayer: time.Nanosecond}, "ctr")
+
+		ctx := p.enter(context.Background(), transform.PhasePause)
+		So(ctx.Err(), ShouldBeNil)
+		ctx = p.enter(context.Background(), transform.PhaseRWLayer)
+		<-ctx.Done()
+		err := p.finish(ctx.Err())
+		So(err, ShouldBeError, "phase rw-layer timed out after 1ns: context deadline exceeded")
+		So(p.finish(nil), ShouldBeNil)
+	})
+}
+
+func Test_dockerTransformer_resolveSharedNamespaces(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	providerID := transformTestCtrID + "abcdefgh"
+	if err := prepareDepsTestContainer(tmpdir, providerID, "provider", `{}`); err != nil {
+		t.Skipf("prepare provider: %v", err)
+	}
+	_ = isulad.InitIsuladTool(&isulad.DaemonConfig{Graph: filepath.Join(tmpdir, "isulad")})
+	iSuladCfg := isulad.GetIsuladTool().GetConfigV2Path(providerID)
+	if err := os.MkdirAll(filepath.Dir(iSuladCfg), 0700); err != nil {
+		t.Skipf("prepare isulad bundle: %v", err)
+	}
+	if err := ioutil.WriteFile(iSuladCfg, []byte("{}"), 0600); err != nil {
+		t.Skipf("prepare isulad config: %v", err)
 	}
+
+	Convey("Test_dockerTransformer_resolveSharedNamespaces", t, func() {
+		dt := &dockerTransformer{ctrs: &sync.Map{}}
+		dt.GraphRoot = tmpdir
+		dt.ctrs.Store(providerID, true)
+		dt.ctrs.Store(reconcileTestConnectCtrID, false)
+		want := "container:" + providerID
+
+		testCases := []struct {
+			name   string
+			h      *types.IsuladHostConfig
+			expect *types.IsuladHostConfig
+		}{
+			{"ipc by name", &types.IsuladHostConfig{IpcMode: "container:provider"},
+				&types.IsuladHostConfig{IpcMode: want}},
+			{"pid by id", &types.IsuladHostConfig{PidMode: want},
+				&types.IsuladHostConfig{PidMode: want}},
+			{"network by prefix", &types.IsuladHostConfig{NetworkMode: "container:" + providerID[:12]},
+				&types.IsuladHostConfig{NetworkMode: want}},
+			{"uts by name", &types.IsuladHostConfig{UTSMode: "container:provider"},
+				&types.IsuladHostConfig{UTSMode: want}},
+			{"user by name", &types.IsuladHostConfig{UsernsMode: "container:provider"},
+				&types.IsuladHostConfig{UsernsMode: want}},
+			{"not container mode", &types.IsuladHostConfig{IpcMode: "shareable", NetworkMode: "bridge"},
+				&types.IsuladHostConfig{IpcMode: "shareable", NetworkMode: "bridge"}},
+		}
+		for _, tc := range testCases {
+			Convey(tc.name, func() {
+				So(dt.resolveSharedNamespaces(tc.h), ShouldBeNil)
+				So(Diff(tc.h, tc.expect), ShouldBeBlank)
+			})
+		}
+
+		Convey("container not found", func() {
+			So(dt.resolveSharedNamespaces(&types.IsuladHostConfig{PidMode: "container:missing"}), ShouldBeError)
+		})
+
+		Convey("container not in iSulad", func() {
+			err := dt.resolveSharedNamespaces(&types.IsuladHostConfig{
+				NetworkMode: "container:" + reconcileTestConnectCtrID,
+			})
+			So(err, ShouldBeError)
+		})
+	})
+}
+
+type fakeIsuladClient struct {
+	mu        sync.Mutex
+	calls     []string
+	notReady  bool
+	deleteErr error
+}
+
+func (f *fakeIsuladClient) record(call, id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call+" "+id)
+}
+
+func (f *fakeIsuladClient) Start(_ context.Context, id string) error {
+	f.record("start", id)
+	return nil
+}
+
+func (f *fakeIsuladClient) WaitReady(_ context.Context, id string) error {
+	f.record("wait", id)
+	if f.notReady {
+		return fmt.Errorf("container is unhealthy")
+	}
+	return nil
+}
+
+func (f *fakeIsuladClient) Stop(_ context.Context, id string) error {
+	f.record("stop", id)
+	return nil
+}
+
+func (f *fakeIsuladClient) Delete(_ context.Context, id string) error {
+	f.record("delete", id)
+	return f.deleteErr
+}
+
+func (f *fakeIsuladClient) Reload(context.Context) error {
+	f.record("reload", "isulad")
+	return nil
+}
+
+func Test_dockerTransformer_completeGroup(t *testing.T) {
+	Convey("Test_dockerTransformer_completeGroup", t, func() {
+		fake := &fakeIsuladClient{}
+		dt := &dockerTransformer{isulad: fake}
+		dt.Start = true
+		ctx := context.Background()
+		run := &groupRun{
+			g:       transformGroup{ids: []string{"x", "y"}},
+			rb:      newRollback(nil),
+			names:   []string{"web", "db"},
+			applied: make([][]string, 2),
+		}
+		So(dt.loadIntoIsulad(ctx), ShouldBeNil)
+
+		Convey("started in order", func() {
+			So(dt.completeGroup(ctx, run), ShouldResemble, []transform.Result{
+				{Ok: true, Msg: "transform x: success, name web"},
+				{Ok: true, Msg: "transform y: success, name db"},
+			})
+			So(fake.calls, ShouldResemble, []string{"reload isulad", "start x", "wait x", "start y", "wait y"})
+		})
+
+		Convey("rolled back", func() {
+			fake.notReady = true
+			results := dt.completeGroup(ctx, run)
+			So(results[0].Ok, ShouldBeFalse)
+			So(results[1].Msg, ShouldEqual, "transform y: skipped since x of the same group failed")
+			So(fake.calls, ShouldResemble, []string{"reload isulad", "start x", "wait x", "stop x", "delete x"})
+		})
+
+		Convey("rollback stopped by failed delete", func() {
+			fake.notReady = true
+			fake.deleteErr = fmt.Errorf("isulad /ContainerService/Delete failed with status 500: busy")
+			var cleaned bool
+			run.rb.register(func() {
+				cleaned = true
+			})
+			results := dt.completeGroup(ctx, run)
+			So(results[0].Ok, ShouldBeFalse)
+			for _, ret := range results {
+				So(ret.Msg, ShouldContainSubstring, "rollback stopped and needs manual cleanup: "+
+					"remove container x from isulad")
+			}
+			So(cleaned, ShouldBeFalse)
+		})
+	})
+}
+
+func Test_dockerTransformer_startContainer(t *testing.T) {
+	Convey("Test_dockerTransformer_startContainer", t, func() {
+		fake := &fakeIsuladClient{}
+		dt := &dockerTransformer{isulad: fake}
+		dt.StartTimeout = time.Second
+		rb := newRollback(nil)
+
+		Convey("ready", func() {
+			So(dt.startContainer(context.Background(), transformTestCtrID, rb), ShouldBeNil)
+			So(fake.calls, ShouldResemble, []string{"start " + transformTestCtrID, "wait " + transformTestCtrID})
+		})
+
+		Convey("not ready and rolled back", func() {
+			fake.notReady = true
+			So(dt.startContainer(context.Background(), transformTestCtrID, rb), ShouldBeError)
+			rb.run()
+			So(fake.calls, ShouldResemble, []string{
+				"start " + transformTestCtrID, "wait " + transformTestCtrID,
+				"stop " + transformTestCtrID, "delete " + transformTestCtrID,
+			})
+		})
+	})
 }
@@ -0,0 +1,221 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"isula.org/isula-transform/types"
+)
+
+// keys of the container selection filters given as key=value
+const (
+	filterLabel         = "label"
+	filterName          = "name"
+	filterImage         = "image"
+	filterStatus        = "status"
+	filterCreatedBefore = "created-before"
+	filterCreatedAfter  = "created-after"
+	filterNetworkMode   = "network-mode"
+)
+
+// container status as reported by docker ps
+var validStatuses = map[string]bool{
+	"created": true, "restarting": true, "running": true, "removing": true,
+	"paused": true, "exited": true, "dead": true,
+}
+
+// layouts accepted by created-before and created-after, the ones without
+// time zone are in local time
+var createdLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+type labelFilter struct {
+	key, value string
+	hasValue   bool
+}
+
+// containerFilter selects containers by their docker metadata, the values of
+// the same key are ORed except labels and times, different keys are ANDed
+type containerFilter struct {
+	labels        []labelFilter
+	names         []*regexp.Regexp
+	images        []string
+	statuses      []string
+	networkModes  []string
+	createdBefore []time.Time
+	createdAfter  []time.Time
+}
+
+// filterTarget is the docker metadata of the container checked by filters
+type filterTarget struct {
+	cfg         *types.DockerV2Config
+	networkMode string
+}
+
+// parseFilters parses the filters in key=value format, nil is returned if there is no filter
+func parseFilters(args []string) (*containerFilter, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	f := &containerFilter{}
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("bad format of filter %q, expected key=value", arg)
+		}
+		key, value := strings.ToLower(strings.TrimSpace(parts[0])), parts[1]
+		switch key {
+		case filterLabel:
+			kv := strings.SplitN(value, "=", 2)
+			lf := labelFilter{key: kv[0]}
+			if len(kv) == 2 {
+				lf.value, lf.hasValue = kv[1], true
+			}
+			f.labels = append(f.labels, lf)
+		case filterName:
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("bad regular expression of filter %q: %v", arg, err)
+			}
+			f.names = append(f.names, re)
+		case filterImage:
+			f.images = append(f.images, value)
+		case filterStatus:
+			if !validStatuses[value] {
+				return nil, fmt.Errorf("invalid status of filter %q", arg)
+			}
+			f.statuses = append(f.statuses, value)
+		case filterCreatedBefore, filterCreatedAfter:
+			t, err := parseCreated(value)
+			if err != nil {
+				return nil, fmt.Errorf("bad time of filter %q: %v", arg, err)
+			}
+			if key == filterCreatedBefore {
+				f.createdBefore = append(f.createdBefore, t)
+			} else {
+				f.createdAfter = append(f.createdAfter, t)
+			}
+		case filterNetworkMode:
+			f.networkModes = append(f.networkModes, value)
+		default:
+			return nil, fmt.Errorf("unknown filter %q", key)
+		}
+	}
+	return f, nil
+}
+
+func parseCreated(value string) (time.Time, error) {
+	var err error
+	for _, layout := range createdLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// match reports whether the container satisfies all the filters
+func (f *containerFilter) match(target *filterTarget) bool {
+	cfg := target.cfg
+	var labels map[string]string
+	if cfg.Config != nil {
+		labels = cfg.Config.Labels
+	}
+	for _, lf := range f.labels {
+		v, ok := labels[lf.key]
+		if !ok || (lf.hasValue && v != lf.value) {
+			return false
+		}
+	}
+	for _, t := range f.createdBefore {
+		if !cfg.Created.Before(t) {
+			return false
+		}
+	}
+	for _, t := range f.createdAfter {
+		if !cfg.Created.After(t) {
+			return false
+		}
+	}
+
+	name := strings.TrimPrefix(cfg.Name, "/")
+	return matchAny(len(f.names), func(i int) bool { return f.names[i].MatchString(name) }) &&
+		matchAny(len(f.images), func(i int) bool { return matchImage(cfg, f.images[i]) }) &&
+		matchAny(len(f.statuses), func(i int) bool { return f.statuses[i] == containerStatusString(cfg.State) }) &&
+		matchAny(len(f.networkModes), func(i int) bool { return matchNetworkMode(target.networkMode, f.networkModes[i]) })
+}
+
+// matchAny reports whether any of the n values matches, true if there is no value
+func matchAny(n int, matched func(i int) bool) bool {
+	if n == 0 {
+		return true
+	}
+	for i := 0; i < n; i++ {
+		if matched(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchImage matches the image the container was created from by name[:tag] or ID prefix,
+// the ID prefix, with or without "sha256:", has at least as many characters as the short ID
+func matchImage(cfg *types.DockerV2Config, image string) bool {
+	id := strings.TrimPrefix(image, "sha256:")
+	if len(id) >= shortIDLen && strings.HasPrefix(strings.TrimPrefix(cfg.ImageID, "sha256:"), id) {
+		return true
+	}
+	if cfg.Config == nil || cfg.Config.Image == "" {
+		return false
+	}
+	ref := cfg.Config.Image
+	// the tag is latest if not specified
+	if !strings.Contains(ref[strings.LastIndex(ref, "/")+1:], ":") {
+		ref += ":latest"
+	}
+	return cfg.Config.Image == image || ref == image || ref == image+":latest"
+}
+
+// matchNetworkMode matches the network mode, "container" matches sharing the network of any container
+func matchNetworkMode(mode, want string) bool {
+	if want == strings.TrimSuffix(containerModePrefix, ":") {
+		return strings.HasPrefix(mode, containerModePrefix)
+	}
+	return mode == want
+}
+
+// containerStatusString returns the status of the container in the same words as docker ps
+func containerStatusString(s *types.ContainerState) string {
+	switch {
+	case s == nil:
+		return "created"
+	case s.Paused:
+		return "paused"
+	case s.Restarting:
+		return "restarting"
+	case s.Running:
+		return "running"
+	case s.RemovalInprogress:
+		return "removing"
+	case s.Dead:
+		return "dead"
+	case s.StartedAt.IsZero():
+		return "created"
+	default:
+	}
+	return "exited"
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/google/go-cmp/cmp"
+	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/transform"
+	"isula.org/isula-transform/types"
+)
+
+func Test_parseFilters(t *testing.T) {
+	Convey("Test_parseFilters", t, func() {
+		f, err := parseFilters(nil)
+		So(err, ShouldBeNil)
+		So(f, ShouldBeNil)
+
+		for _, bad := range []string{"label", "name=", "name=[", "status=up", "created-before=yesterday", "unknown=x"} {
+			_, err := parseFilters([]string{bad})
+			So(err, ShouldBeError)
+		}
+
+		f, err = parseFilters([]string{"label=tier=web", "label=managed", "created-after=2020-01-02"})
+		So(err, ShouldBeNil)
+		So(f.labels, ShouldResemble, []labelFilter{{key: "tier", value: "web", hasValue: true}, {key: "managed"}})
+		So(f.createdAfter[0].Equal(time.Date(2020, 1, 2, 0, 0, 0, 0, time.Local)), ShouldBeTrue)
+	})
+}
+
+func Test_containerFilter_match(t *testing.T) {
+	Convey("Test_containerFilter_match", t, func() {
+		target := &filterTarget{
+			cfg: &types.DockerV2Config{
+				Name:    "/web-1",
+				Created: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+				ImageID: "sha256:4bb46517cac397bdb0bab6eba09b0e1f8e90ddd17cf99662997c3253531136f8",
+				Config: &types.ContainerCfg{
+					Image:  "nginx",
+					Labels: map[string]string{"tier": "web", "managed": ""},
+				},
+				State: &types.ContainerState{Running: true, Paused: true},
+			},
+			networkMode: "container:" + reconcileTestConnectCtrID,
+		}
+		testCases := []struct {
+			filters []string
+			expect  bool
+		}{
+			{[]string{"label=tier=web", "label=managed"}, true},
+			{[]string{"label=tier=web", "label=owner"}, false},
+			{[]string{"label=tier=db"}, false},
+			{[]string{"name=^web-[0-9]+$"}, true},
+			{[]string{"name=^db", "name=web"}, true},
+			{[]string{"name=^db"}, false},
+			{[]string{"image=nginx:latest"}, true},
+			{[]string{"image=4bb46517cac3"}, true},
+			{[]string{"image=sha256:4bb46517cac3"}, true},
+			{[]string{"image=sha256:4bb46517cac397bdb0bab6eba09b0e1f8e90ddd17cf99662997c3253531136f8"}, true},
+			{[]string{"image=4bb46517"}, false},
+			{[]string{"image=4"}, false},
+			{[]string{"image=sha256:"}, false},
+			{[]string{"image=nginx:1.19"}, false},
+			{[]string{"status=paused"}, true},
+			{[]string{"status=running"}, false},
+			{[]string{"created-before=2020-07-01", "created-after=2020-05-01T00:00:00Z"}, true},
+			{[]string{"created-before=2020-05-01"}, false},
+			{[]string{"network-mode=container"}, true},
+			{[]string{"network-mode=host"}, false},
+			{[]string{"name=web", "status=exited"}, false},
+		}
+		for _, tc := range testCases {
+			f, err := parseFilters(tc.filters)
+			So(err, ShouldBeNil)
+			So(f.match(target), ShouldEqual, tc.expect)
+		}
+	})
+}
+
+func Test_dockerTransformer_selectContainers(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	testCtrs := []struct {
+		id, v2Cfg, hostCfg string
+	}{
+		{ctrID('a'), `{"Name":"/web","Config":{"Labels":{"tier":"web"}},"State":{"Running":true}}`, `{"NetworkMode":"host"}`},
+		{ctrID('b'), `{"Name":"/db","Config":{"Labels":{"tier":"db"}},"State":{"Running":true}}`, `{"NetworkMode":"host"}`},
+		{ctrID('c'), `{"Name":"/web-debug","Config":{"Labels":{"tier":"web"}},"State":{"Running":true}}`, `{"Links":["/db:/web-debug/db"]}`},
+	}
+	for _, c := range testCtrs {
+		root := filepath.Join(tmpdir, "containers", c.id)
+		if err := os.MkdirAll(root, 0700); err != nil {
+			t.Skipf("prepare container: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, types.V2config), []byte(c.v2Cfg), 0600); err != nil {
+			t.Skipf("prepare container: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, types.Hostconfig), []byte(c.hostCfg), 0600); err != nil {
+			t.Skipf("prepare container: %v", err)
+		}
+	}
+	newTransformer := func(filters, excludes []string) *dockerTransformer {
+		dt := &dockerTransformer{ctrs: &sync.Map{}, filters: filters, excludes: excludes}
+		dt.GraphRoot = tmpdir
+		for _, c := range testCtrs {
+			dt.ctrs.Store(c.id, false)
+		}
+		return dt
+	}
+
+	Convey("Test_dockerTransformer_selectContainers", t, func() {
+		Convey("bad filter", func() {
+			So(newTransformer([]string{"tier=web"}, nil).initSelection(), ShouldBeError)
+		})
+
+		Convey("filters and excludes of all", func() {
+			dt := newTransformer([]string{"label=tier=web"}, []string{"web-debug", "missing"})
+			So(dt.initSelection(), ShouldBeNil)
+			ids, results := dt.selectContainers([]string{ctrID('a'), ctrID('b'), ctrID('c')}, true)
+			So(results, ShouldBeEmpty)
+			So(ids, ShouldResemble, []string{ctrID('a')})
+		})
+
+		Convey("given containers", func() {
+			dt := newTransformer([]string{"network-mode=host"}, nil)
+			So(dt.initSelection(), ShouldBeNil)
+			ids, results := dt.selectContainers([]string{"db", "web-debug"}, false)
+			So(ids, ShouldResemble, []string{ctrID('b')})
+			So(Diff(results, []transform.Result{
+				{Container: ctrID('c'), Ok: true, Msg: "transform web-debug: skipped, not matching the filters",
+					Skipped: transform.SkipFiltered},
+			}), ShouldBeBlank)
+		})
+
+		Convey("excluded dependency", func() {
+			dt := newTransformer(nil, []string{"db"})
+			So(dt.initSelection(), ShouldBeNil)
+			So(dt.excluded, ShouldResemble, map[string]bool{ctrID('b'): true})
+			groups, _ := dt.planGroups([]string{ctrID('c')}, true)
+			So(len(groups), ShouldEqual, 1)
+			So(groups[0].err, ShouldBeError)
+		})
+	})
+}
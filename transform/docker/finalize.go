@@ -0,0 +1,244 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	docker "github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"isula.org/isula-transform/transform"
+	"isula.org/isula-transform/types"
+	"isula.org/isula-transform/utils"
+)
+
+// actions on the docker container after a successful transformation
+const (
+	finalizeKeep           = "keep"
+	finalizeStop           = "stop"
+	finalizeDisableRestart = "disable-restart"
+	finalizeRemove         = "remove"
+
+	defaultFinalizeRecordRoot = "/var/lib/isula-transform/docker-finalize"
+	recordFileMode            = 0600
+	restartPolicyNo           = "no"
+	dockerStopTimeout         = 10 * time.Second
+)
+
+// finalizeRecord records the changes of the docker container made by finalization,
+// it is kept in the record root so that the changes can be restored
+type finalizeRecord struct {
+	ID     string    `json:"id"`
+	Action string    `json:"action"`
+	Time   time.Time `json:"time"`
+	// Stopped is set when the container is stopped
+	Stopped bool `json:"stopped,omitempty"`
+	// RestartPolicy is the policy before it is disabled
+	RestartPolicy *container.RestartPolicy `json:"restartPolicy,omitempty"`
+}
+
+func checkFinalizeAction(action string) error {
+	switch action {
+	case finalizeKeep, finalizeStop, finalizeDisableRestart, finalizeRemove:
+		return nil
+	default:
+	}
+	return fmt.Errorf("unknown docker finalize action: %s", action)
+}
+
+// finalizeGroup changes the docker containers of a transformed group, consumers first, the changes
+// are restored on rollback. The docker containers are always stopped when they are started in isulad
+// so that the workload is not owned by both of them
+func (t *dockerTransformer) finalizeGroup(ctx context.Context, ids []string, rb *rollback) error {
+	if (t.finalize == finalizeKeep || t.finalize == "") && !t.Start {
+		return nil
+	}
+	for i := len(ids) - 1; i >= 0; i-- {
+		if err := t.finalizeContainer(ctx, ids[i], rb); err != nil {
+			logrus.Errorf("finalize docker container %s failed: %v", ids[i], err)
+			return errors.Wrapf(err, "finalize docker container %s", ids[i])
+		}
+	}
+	return nil
+}
+
+// removeFinalized removes the docker containers of a group once it can not be rolled back any more
+func (t *dockerTransformer) removeFinalized(ctx context.Context, ids []string) {
+	if t.finalize != finalizeRemove {
+		return
+	}
+	// the containers have been stopped with restart disabled, a failed removal is harmless
+	for i := len(ids) - 1; i >= 0; i-- {
+		err := t.client.ContainerRemove(ctx, ids[i], dockertypes.ContainerRemoveOptions{Force: true})
+		if err != nil {
+			t.warnf(ids[i], "remove docker container %s failed: %v", ids[i], err)
+		}
+	}
+}
+
+// finalizeContainer stops the docker container or disables its restart policy as required,
+// the record is saved before the changes are made
+func (t *dockerTransformer) finalizeContainer(ctx context.Context, id string, rb *rollback) (retErr error) {
+	phases := newPhaseTracker(t.EventBus, t.PhaseTimeouts, id)
+	ctx = phases.enter(ctx, transform.PhaseFinalize)
+	defer func() {
+		retErr = phases.finish(retErr)
+	}()
+	rec := &finalizeRecord{
+		ID:      id,
+		Action:  t.finalize,
+		Time:    time.Now(),
+		Stopped: t.finalize == finalizeStop || t.finalize == finalizeRemove || t.Start,
+	}
+	if t.finalize == finalizeDisableRestart || t.finalize == finalizeRemove {
+		policy, err := t.restartPolicy(id)
+		if err != nil {
+			return err
+		}
+		rec.RestartPolicy = &policy
+	}
+	if err := t.saveFinalizeRecord(rec); err != nil {
+		return err
+	}
+	rb.registerStep(id, "restore docker container", func() {
+		logrus.Infof("rollback: restore docker container %s finalized by %s", id, rec.Action)
+		t.restoreFinalize(rec)
+	})
+
+	if rec.RestartPolicy != nil {
+		if err := t.setRestartPolicy(ctx, id, container.RestartPolicy{Name: restartPolicyNo}); err != nil {
+			return errors.Wrap(err, "disable restart policy")
+		}
+	}
+	if rec.Stopped {
+		timeout := dockerStopTimeout
+		if err := t.client.ContainerStop(ctx, id, &timeout); err != nil {
+			return errors.Wrap(err, "stop container")
+		}
+	}
+	return nil
+}
+
+// restoreFinalize undoes the changes recorded by rec and removes the record
+func (t *dockerTransformer) restoreFinalize(rec *finalizeRecord) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	if rec.Stopped {
+		if err := t.client.ContainerStart(ctx, rec.ID, dockertypes.ContainerStartOptions{}); err != nil {
+			t.warnf(rec.ID, "rollback: start docker container %s: %v", rec.ID, err)
+		}
+	}
+	if rec.RestartPolicy != nil {
+		if err := t.setRestartPolicy(ctx, rec.ID, *rec.RestartPolicy); err != nil {
+			t.warnf(rec.ID, "rollback: restore restart policy of docker container %s: %v", rec.ID, err)
+		}
+	}
+	if err := os.Remove(t.finalizeRecordPath(rec.ID)); err != nil && !os.IsNotExist(err) {
+		t.warnf(rec.ID, "rollback: remove finalize record of %s: %v", rec.ID, err)
+	}
+}
+
+func (t *dockerTransformer) finalizeRecordPath(id string) string {
+	return filepath.Join(t.recordRoot, id+".json")
+}
+
+func (t *dockerTransformer) saveFinalizeRecord(rec *finalizeRecord) error {
+	data, err := json.MarshalIndent(rec, "", "\t")
+	if err != nil {
+		return errors.Wrap(err, "marshal finalize record")
+	}
+	if err := os.MkdirAll(t.recordRoot, 0700); err != nil {
+		return errors.Wrap(err, "create finalize record root")
+	}
+	path := t.finalizeRecordPath(rec.ID)
+	if err := ioutil.WriteFile(path, data, recordFileMode); err != nil {
+		logrus.Errorf("write finalize record %s failed: %v", path, err)
+		return errors.Wrap(err, "write finalize record")
+	}
+	return nil
+}
+
+func (t *dockerTransformer) loadFinalizeRecord(id string) (*finalizeRecord, error) {
+	var rec finalizeRecord
+	data, err := ioutil.ReadFile(t.finalizeRecordPath(id))
+	if err != nil {
+		return nil, errors.Wrap(err, "read finalize record")
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, errors.Wrap(err, "unmarshal finalize record")
+	}
+	return &rec, nil
+}
+
+// restartPolicy reads the restart policy from docker hostconfig.json of container id
+func (t *dockerTransformer) restartPolicy(id string) (container.RestartPolicy, error) {
+	var hostCfg struct {
+		RestartPolicy container.RestartPolicy
+	}
+	data, err := t.readHostConfig(id)
+	if err != nil {
+		return hostCfg.RestartPolicy, err
+	}
+	if err := json.Unmarshal(data, &hostCfg); err != nil {
+		return hostCfg.RestartPolicy, errors.Wrap(err, "unmarshal host config data")
+	}
+	return hostCfg.RestartPolicy, nil
+}
+
+// setRestartPolicy updates the restart policy through docker API,
+// hostconfig.json is edited offline if dockerd is not running
+func (t *dockerTransformer) setRestartPolicy(ctx context.Context, id string, policy container.RestartPolicy) error {
+	_, err := t.client.ContainerUpdate(ctx, id, container.UpdateConfig{RestartPolicy: policy})
+	if err == nil || !docker.IsErrConnectionFailed(err) {
+		return err
+	}
+	logrus.Infof("dockerd is not running, edit restart policy of %s offline", id)
+
+	var hostCfg map[string]json.RawMessage
+	data, err := t.readHostConfig(id)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &hostCfg); err != nil {
+		return errors.Wrap(err, "unmarshal host config data")
+	}
+	if hostCfg["RestartPolicy"], err = json.Marshal(policy); err != nil {
+		return errors.Wrap(err, "marshal restart policy")
+	}
+	if data, err = json.Marshal(hostCfg); err != nil {
+		return errors.Wrap(err, "marshal host config data")
+	}
+	return utils.AtomicWriteFile(t.hostConfigPath(id), data, 0644)
+}
+
+func (t *dockerTransformer) hostConfigPath(id string) string {
+	return filepath.Join(t.GraphRoot, "containers", id, types.Hostconfig)
+}
+
+func (t *dockerTransformer) readHostConfig(id string) ([]byte, error) {
+	path := t.hostConfigPath(id)
+	if err := utils.CheckFileValid(path); err != nil {
+		return nil, errors.Wrap(err, "check docker hostconfig.json")
+	}
+	data, err := ioutil.ReadFile(path)
+	return data, errors.Wrap(err, "read hostconfig.json")
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/types"
+)
+
+func Test_dockerTransformer_finalizeGroup(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	ids := []string{ctrID('a'), ctrID('b')}
+	hostCfg := `{"RestartPolicy":{"Name":"always","MaximumRetryCount":0},"NetworkMode":"host"}`
+	for _, id := range ids {
+		if err := prepareDepsTestContainer(tmpdir, id, id[:4], hostCfg); err != nil {
+			t.Skipf("prepare container: %v", err)
+		}
+	}
+
+	Convey("Test_dockerTransformer_finalizeGroup", t, func() {
+		client := &fakeDockerClient{}
+		dt := &dockerTransformer{client: client, recordRoot: filepath.Join(tmpdir, "records")}
+		dt.GraphRoot = tmpdir
+		rb := newRollback(nil)
+
+		So(checkFinalizeAction("pause"), ShouldBeError)
+
+		Convey("keep", func() {
+			dt.finalize = finalizeKeep
+			So(dt.finalizeGroup(context.Background(), ids, rb), ShouldBeNil)
+			So(client.calls, ShouldBeEmpty)
+		})
+
+		Convey("stop and rollback", func() {
+			dt.finalize = finalizeStop
+			So(dt.finalizeGroup(context.Background(), ids, rb), ShouldBeNil)
+			So(client.calls, ShouldResemble, []string{"stop " + ids[1], "stop " + ids[0]})
+
+			var rec finalizeRecord
+			data, err := ioutil.ReadFile(dt.finalizeRecordPath(ids[0]))
+			So(err, ShouldBeNil)
+			So(json.Unmarshal(data, &rec), ShouldBeNil)
+			So(rec.Stopped, ShouldBeTrue)
+			So(rec.RestartPolicy, ShouldBeNil)
+
+			rb.run()
+			So(client.calls[2:], ShouldResemble, []string{"start " + ids[0], "start " + ids[1]})
+			_, err = os.Stat(dt.finalizeRecordPath(ids[0]))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("keep and start", func() {
+			dt.finalize = finalizeKeep
+			dt.Start = true
+			So(dt.finalizeGroup(context.Background(), ids[:1], rb), ShouldBeNil)
+			dt.removeFinalized(context.Background(), ids[:1])
+			So(client.calls, ShouldResemble, []string{"stop " + ids[0]})
+			rb.run()
+			So(client.calls[1:], ShouldResemble, []string{"start " + ids[0]})
+		})
+
+		Convey("remove", func() {
+			dt.finalize = finalizeRemove
+			So(dt.finalizeGroup(context.Background(), ids, rb), ShouldBeNil)
+			dt.removeFinalized(context.Background(), ids)
+			So(client.calls, ShouldResemble, []string{
+				"update " + ids[1] + " no", "stop " + ids[1],
+				"update " + ids[0] + " no", "stop " + ids[0],
+				"remove " + ids[1], "remove " + ids[0],
+			})
+			var rec finalizeRecord
+			data, err := ioutil.ReadFile(dt.finalizeRecordPath(ids[1]))
+			So(err, ShouldBeNil)
+			So(json.Unmarshal(data, &rec), ShouldBeNil)
+			So(rec.RestartPolicy, ShouldResemble, &container.RestartPolicy{Name: "always"})
+		})
+
+		Convey("disable restart offline and rollback", func() {
+			dt.finalize = finalizeDisableRestart
+			client.offline = true
+			So(dt.finalizeGroup(context.Background(), ids[:1], rb), ShouldBeNil)
+			policy, err := dt.restartPolicy(ids[0])
+			So(err, ShouldBeNil)
+			So(policy.Name, ShouldEqual, restartPolicyNo)
+			// the other fields are kept
+			refs, err := dt.loadRefs(ids[0])
+			So(err, ShouldBeNil)
+			So(refs.NetworkMode, ShouldEqual, "host")
+
+			rb.run()
+			policy, err = dt.restartPolicy(ids[0])
+			So(err, ShouldBeNil)
+			So(policy.Name, ShouldEqual, "always")
+			So(client.calls, ShouldBeEmpty)
+		})
+
+		Convey("host config missing", func() {
+			dt.finalize = finalizeDisableRestart
+			So(dt.finalizeGroup(context.Background(), []string{ctrID('c')}, rb), ShouldBeError)
+			_, err := os.Stat(filepath.Join(tmpdir, "containers", ctrID('c'), types.Hostconfig))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+	})
+}
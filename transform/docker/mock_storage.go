@@ -5,6 +5,7 @@
 package docker
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	types "isula.org/isula-transform/types"
 	reflect "reflect"
@@ -46,30 +47,74 @@ func (mr *MockStorageDriverMockRecorder) Cleanup(arg0 interface{}) *gomock.Call
 }
 
 // GenerateRootFs mocks base method
-func (m *MockStorageDriver) GenerateRootFs(arg0, arg1 string) (string, error) {
+func (m *MockStorageDriver) GenerateRootFs(arg0, arg1 string, arg2 map[string]string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GenerateRootFs", arg0, arg1)
+	ret := m.ctrl.Call(m, "GenerateRootFs", arg0, arg1, arg2)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GenerateRootFs indicates an expected call of GenerateRootFs
-func (mr *MockStorageDriverMockRecorder) GenerateRootFs(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockStorageDriverMockRecorder) GenerateRootFs(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateRootFs", reflect.TypeOf((*MockStorageDriver)(nil).GenerateRootFs), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateRootFs", reflect.TypeOf((*MockStorageDriver)(nil).GenerateRootFs), arg0, arg1, arg2)
+}
+
+// RWLayerSpace mocks base method
+func (m *MockStorageDriver) RWLayerSpace(arg0 *types.IsuladV2Config, arg1 int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RWLayerSpace", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RWLayerSpace indicates an expected call of RWLayerSpace
+func (mr *MockStorageDriverMockRecorder) RWLayerSpace(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RWLayerSpace", reflect.TypeOf((*MockStorageDriver)(nil).RWLayerSpace), arg0, arg1)
+}
+
+// RWLayerSize mocks base method
+func (m *MockStorageDriver) RWLayerSize(arg0 context.Context, arg1 *types.IsuladV2Config, arg2 string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RWLayerSize", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RWLayerSize indicates an expected call of RWLayerSize
+func (mr *MockStorageDriverMockRecorder) RWLayerSize(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RWLayerSize", reflect.TypeOf((*MockStorageDriver)(nil).RWLayerSize), arg0, arg1, arg2)
 }
 
 // TransformRWLayer mocks base method
-func (m *MockStorageDriver) TransformRWLayer(arg0 *types.IsuladV2Config, arg1 string) error {
+func (m *MockStorageDriver) TransformRWLayer(arg0 context.Context, arg1 *types.IsuladV2Config, arg2 string, arg3 int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TransformRWLayer", arg0, arg1)
+	ret := m.ctrl.Call(m, "TransformRWLayer", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // TransformRWLayer indicates an expected call of TransformRWLayer
-func (mr *MockStorageDriverMockRecorder) TransformRWLayer(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockStorageDriverMockRecorder) TransformRWLayer(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransformRWLayer", reflect.TypeOf((*MockStorageDriver)(nil).TransformRWLayer), arg0, arg1, arg2, arg3)
+}
+
+// VerifyRWLayer mocks base method
+func (m *MockStorageDriver) VerifyRWLayer(arg0 context.Context, arg1 *types.IsuladV2Config, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyRWLayer", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyRWLayer indicates an expected call of VerifyRWLayer
+func (mr *MockStorageDriverMockRecorder) VerifyRWLayer(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransformRWLayer", reflect.TypeOf((*MockStorageDriver)(nil).TransformRWLayer), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyRWLayer", reflect.TypeOf((*MockStorageDriver)(nil).VerifyRWLayer), arg0, arg1, arg2)
 }
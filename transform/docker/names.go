@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// policies of name conflicts with the existing isulad containers
+const (
+	nameConflictFail     = "fail"
+	nameConflictSuffix   = "suffix"
+	nameConflictTemplate = "template"
+
+	shortIDLen = 12
+)
+
+// validNamePattern is the container name allowed by isulad
+var validNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// nameTemplateData is the data rendered by the name template on conflicts
+type nameTemplateData struct {
+	Name    string
+	ID      string
+	ShortID string
+}
+
+// nameAllocator hands out the isulad container names, resolving the conflicts
+// with the existing isulad containers and the ones being transformed
+type nameAllocator struct {
+	mu     sync.Mutex
+	policy string
+	tmpl   *template.Template
+	// used maps the names taken to the container IDs
+	used map[string]string
+}
+
+func newNameAllocator(policy, tmpl string, existing map[string]string) (*nameAllocator, error) {
+	a := &nameAllocator{policy: policy, used: make(map[string]string)}
+	switch policy {
+	case nameConflictFail, nameConflictSuffix:
+	case nameConflictTemplate:
+		t, err := template.New("name").Option("missingkey=error").Parse(tmpl)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse name template")
+		}
+		a.tmpl = t
+	default:
+		return nil, fmt.Errorf("unknown policy of name conflict: %s", policy)
+	}
+	for name, id := range existing {
+		a.used[name] = id
+	}
+	return a, nil
+}
+
+// allocate takes a name for container id according to the policy, name is used if it is free
+func (a *nameAllocator) allocate(id, name string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	newName, err := a.pick(id, name)
+	if err != nil {
+		return "", err
+	}
+	a.used[newName] = id
+	return newName, nil
+}
+
+// peek returns the name container id would get without taking it
+func (a *nameAllocator) peek(id, name string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.pick(id, name)
+}
+
+// pick chooses a free name for container id, the caller must hold the lock
+func (a *nameAllocator) pick(id, name string) (string, error) {
+	if owner, ok := a.used[name]; !ok || owner == id {
+		return name, nil
+	}
+
+	var newName string
+	switch a.policy {
+	case nameConflictSuffix:
+		for i := 1; ; i++ {
+			newName = name + "-" + strconv.Itoa(i)
+			if _, ok := a.used[newName]; !ok {
+				break
+			}
+		}
+	case nameConflictTemplate:
+		data := nameTemplateData{Name: name, ID: id, ShortID: id}
+		if len(id) > shortIDLen {
+			data.ShortID = id[:shortIDLen]
+		}
+		var buf bytes.Buffer
+		if err := a.tmpl.Execute(&buf, data); err != nil {
+			return "", errors.Wrap(err, "render name template")
+		}
+		newName = buf.String()
+		if !validNamePattern.MatchString(newName) {
+			return "", fmt.Errorf("invalid container name %q rendered by template", newName)
+		}
+		if _, ok := a.used[newName]; ok {
+			return "", fmt.Errorf("name %s is already in use, and so is %s rendered by template", name, newName)
+		}
+	default:
+		return "", fmt.Errorf("name %s is already in use by isulad container %s", name, a.used[name])
+	}
+	return newName, nil
+}
+
+// release gives back the name taken by container id
+func (a *nameAllocator) release(id, name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.used[name] == id {
+		delete(a.used, name)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_nameAllocator(t *testing.T) {
+	existing := map[string]string{"web": "isulad1", "web-1": "isulad2"}
+	id := ctrID('a')
+
+	Convey("Test_nameAllocator", t, func() {
+		Convey("bad policy or template", func() {
+			_, err := newNameAllocator("rename", "", existing)
+			So(err, ShouldBeError)
+			_, err = newNameAllocator(nameConflictTemplate, "{{.Name", existing)
+			So(err, ShouldBeError)
+		})
+
+		Convey("fail", func() {
+			a, err := newNameAllocator(nameConflictFail, "", existing)
+			So(err, ShouldBeNil)
+			_, err = a.allocate(id, "web")
+			So(err, ShouldBeError, "name web is already in use by isulad container isulad1")
+			name, err := a.allocate(id, "db")
+			So(err, ShouldBeNil)
+			So(name, ShouldEqual, "db")
+			// the same container gets its name again
+			name, err = a.allocate(id, "db")
+			So(err, ShouldBeNil)
+			So(name, ShouldEqual, "db")
+			_, err = a.allocate(ctrID('b'), "db")
+			So(err, ShouldBeError)
+			a.release(id, "db")
+			name, err = a.allocate(ctrID('b'), "db")
+			So(err, ShouldBeNil)
+			So(name, ShouldEqual, "db")
+		})
+
+		Convey("suffix", func() {
+			a, err := newNameAllocator(nameConflictSuffix, "", existing)
+			So(err, ShouldBeNil)
+			name, err := a.allocate(id, "web")
+			So(err, ShouldBeNil)
+			So(name, ShouldEqual, "web-2")
+			name, err = a.allocate(ctrID('b'), "web")
+			So(err, ShouldBeNil)
+			So(name, ShouldEqual, "web-3")
+		})
+
+		Convey("template", func() {
+			a, err := newNameAllocator(nameConflictTemplate, "{{.Name}}-{{.ShortID}}", existing)
+			So(err, ShouldBeNil)
+			name, err := a.allocate(id, "web")
+			So(err, ShouldBeNil)
+			So(name, ShouldEqual, "web-aaaaaaaaaaaa")
+
+			a, err = newNameAllocator(nameConflictTemplate, "{{.Name}}-docker", existing)
+			So(err, ShouldBeNil)
+			name, err = a.allocate(id, "web")
+			So(err, ShouldBeNil)
+			So(name, ShouldEqual, "web-docker")
+			_, err = a.allocate(ctrID('b'), "web")
+			So(err, ShouldBeError, "name web is already in use, and so is web-docker rendered by template")
+
+			a, err = newNameAllocator(nameConflictTemplate, "{{.Name}}/docker", existing)
+			So(err, ShouldBeNil)
+			_, err = a.allocate(id, "web")
+			So(err, ShouldBeError)
+		})
+	})
+}
@@ -13,35 +13,253 @@
 package docker
 
 import (
-	"os/exec"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
 
+	"github.com/docker/docker/api/types/container"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 	"isula.org/isula-transform/transform"
 	"isula.org/isula-transform/types"
+	"isula.org/isula-transform/utils"
+)
+
+const (
+	// overlayOpaqueXattr marks a directory of upper layer hiding the content of lower layers
+	overlayOpaqueXattr = "trusted.overlay.opaque"
+	// overlayXattrPrefix is the prefix of the private xattrs of overlay, such as opaque, origin and
+	// redirect, which mean nothing out of an overlay layer
+	overlayXattrPrefix = "trusted.overlay."
 )
 
 type overlayDriver struct {
 	transform.BaseStorageDriver
+	sources changeSources
+	limiter *utils.RateLimiter
+	events  *transform.EventBus
+	// owners shifts the owners of the copied files when not nil
+	owners *idShift
+}
+
+func newOverlayDriver(base transform.BaseStorageDriver, sources changeSources,
+	limiter *utils.RateLimiter, events *transform.EventBus, owners *idShift) transform.StorageDriver {
+	return &overlayDriver{BaseStorageDriver: base, sources: sources, limiter: limiter, events: events, owners: owners}
+}
+
+func (od *overlayDriver) GenerateRootFs(id, image string, storageOpt map[string]string) (string, error) {
+	return od.BaseStorageDriver.GenerateRootFs(id, image, storageOpt)
 }
 
-func newOverlayDriver(base transform.BaseStorageDriver) transform.StorageDriver {
-	return &overlayDriver{base}
+func (od *overlayDriver) RWLayerSize(ctx context.Context, ctr *types.IsuladV2Config,
+	oldRootFs string) (int64, error) {
+	srcRoot, walk, release, err := od.sources.open(ctx, originDriver(ctr, transform.Overlay2), ctr, oldRootFs)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	return changesSize(srcRoot, walk)
 }
 
-func (od *overlayDriver) GenerateRootFs(id, image string) (string, error) {
-	return od.BaseStorageDriver.GenerateRootFs(id, image)
+// RWLayerSpace returns limit, the project quota of overlay only limits the upper directory
+func (od *overlayDriver) RWLayerSpace(ctr *types.IsuladV2Config, limit int64) (int64, error) {
+	return limit, nil
 }
 
-// only copy diff from old to new
-func (od *overlayDriver) TransformRWLayer(ctr *types.IsuladV2Config, oldRootFs string) error {
-	srcRoot := strings.TrimSuffix(oldRootFs, "/merged")
+// only copy diff from old to new if the container is created by docker overlay2 driver,
+// otherwise the changes are written into diff with deletions converted to whiteouts
+func (od *overlayDriver) TransformRWLayer(ctx context.Context, ctr *types.IsuladV2Config, oldRootFs string,
+	size int64) error {
 	destRoot := strings.TrimSuffix(ctr.CommonConfig.BaseFs, "/merged")
-	if err := exec.Command("cp", "-ra", srcRoot+"/diff", destRoot).Run(); err != nil {
+	driver := originDriver(ctr, transform.Overlay2)
+	progress := newCopyProgress(od.events, ctr.CommonConfig.ID, size)
+	if driver == transform.Overlay2 {
+		srcRoot := strings.TrimSuffix(oldRootFs, "/merged")
+		// the diff is copied as a whole, only the start and the end are reported
+		walk := progress.wrap(srcRoot, func(fn changeFunc) error {
+			return fn(container.ContainerChangeResponseItem{Kind: addItem, Path: "/diff"})
+		})
+		err := walk(func(container.ContainerChangeResponseItem) error {
+			if err := copyPath(ctx, srcRoot+"/diff", destRoot, od.limiter); err != nil {
+				return err
+			}
+			if err := od.owners.shiftTrees(destRoot, "/diff"); err != nil {
+				return err
+			}
+			return utils.Relabel(destRoot+"/diff", ctr.CommonConfig.MountLabel)
+		})
+		if err != nil {
+			return err
+		}
+		progress.done()
+		return nil
+	}
+
+	srcRoot, walk, release, err := od.sources.open(ctx, driver, ctr, oldRootFs)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := applyChangesToUpper(ctx, srcRoot, destRoot+"/diff", progress.wrap(srcRoot, walk),
+		od.limiter, od.owners); err != nil {
+		return err
+	}
+	if err := utils.Relabel(destRoot+"/diff", ctr.CommonConfig.MountLabel); err != nil {
 		return err
 	}
+	progress.done()
 	return nil
 }
 
+func (od *overlayDriver) VerifyRWLayer(ctx context.Context, ctr *types.IsuladV2Config, oldRootFs string) error {
+	destDiff := strings.TrimSuffix(ctr.CommonConfig.BaseFs, "/merged") + "/diff"
+	driver := originDriver(ctr, transform.Overlay2)
+	if driver == transform.Overlay2 {
+		srcDiff := strings.TrimSuffix(oldRootFs, "/merged") + "/diff"
+		m, err := buildManifest(srcDiff)
+		if err != nil {
+			return err
+		}
+		od.owners.shiftManifest(m)
+		logrus.Debugf("overlay driver verify %d items of %s", len(m), srcDiff)
+		return m.compare(destDiff)
+	}
+
+	srcRoot, walk, release, err := od.sources.open(ctx, driver, ctr, oldRootFs)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return verifyChanges(srcRoot, destDiff, walk, od.owners, checkWhiteouts)
+}
+
 func (od *overlayDriver) Cleanup(id string) {
 	od.BaseStorageDriver.CleanupRootFs(id)
 }
+
+// overlaySource gets the changes of the container created by docker overlay2 driver
+type overlaySource struct{}
+
+func (ols *overlaySource) open(_ context.Context, ctr *types.IsuladV2Config, oldRootFs string) (string, changeWalker, func(), error) {
+	upper := strings.TrimSuffix(oldRootFs, "/merged") + "/diff"
+	walk := func(fn changeFunc) error {
+		return layerWalk(upper, ctr.CommonConfig.MountPoints, overlayFormat{}, fn)
+	}
+	return upper, walk, func() {}, nil
+}
+
+// overlayFormat marks the deleted files by whiteouts and opaque directories
+type overlayFormat struct{}
+
+func (overlayFormat) classify(path string, fi os.FileInfo) (layerEntry, error) {
+	if isWhiteout(fi) {
+		return layerWhiteout, nil
+	}
+	if !fi.IsDir() {
+		return layerFile, nil
+	}
+	opaque, err := utils.Lgetxattr(path, overlayOpaqueXattr)
+	if err != nil {
+		return layerFile, err
+	}
+	if string(opaque) == "y" {
+		return layerOpaque, nil
+	}
+	return layerFile, nil
+}
+
+func (overlayFormat) hidden(path string) string {
+	return path
+}
+
+// isWhiteout reports whether fi is a character device with 0/0 device number,
+// which is used by overlay to hide the file of lower layers
+func isWhiteout(fi os.FileInfo) bool {
+	if fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && st.Rdev == 0
+}
+
+// applyChangesToUpper migrates the changes from srcRoot to the upper directory of overlay,
+// deletions are converted to whiteouts which hide the files of the image layers
+func applyChangesToUpper(ctx context.Context, srcRoot, upper string, walk changeWalker,
+	limiter *utils.RateLimiter, owners *idShift) error {
+	var dirs, copied []string
+	links := make(linkTracker)
+	err := walk(func(change container.ContainerChangeResponseItem) error {
+		dest := upper + change.Path
+		parents, err := makeUpperParents(upper, change.Path)
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, parents...)
+		switch change.Kind {
+		case metaItem:
+			if err := makeDir(dest); err != nil {
+				return err
+			}
+			dirs = append(dirs, change.Path)
+		case addItem, changeItem:
+			if linked, err := links.link(srcRoot+change.Path, dest); err != nil || linked {
+				return err
+			}
+			copied = append(copied, change.Path)
+			return copyChange(ctx, srcRoot+change.Path, dest, limiter)
+		case delItem:
+			if err := os.RemoveAll(dest); err != nil {
+				logrus.Errorf("remove %s failed: %v", dest, err)
+				return err
+			}
+			if err := unix.Mknod(dest, unix.S_IFCHR, 0); err != nil {
+				logrus.Errorf("create whiteout %s failed: %v", dest, err)
+				return err
+			}
+		default:
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := applyDirsMetadata(srcRoot, upper, dirs); err != nil {
+		return err
+	}
+	return shiftOwners(owners, upper, copied, dirs)
+}
+
+// makeUpperParents creates the missing parent directories of rel in upper,
+// and returns them so that their metadata can be copied from the source later
+func makeUpperParents(upper, rel string) ([]string, error) {
+	var missing []string
+	for dir := filepath.Dir(rel); dir != "/"; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(upper + dir); err == nil {
+			break
+		}
+		missing = append([]string{dir}, missing...)
+	}
+	for _, dir := range missing {
+		if err := makeDir(upper + dir); err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// checkWhiteouts makes sure that the deleted paths are whiteouts in upper
+func checkWhiteouts(upper string, paths []string) error {
+	var mismatches []string
+	for _, rel := range paths {
+		fi, err := os.Lstat(filepath.Join(upper, rel))
+		if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %v", rel, err))
+		} else if !isWhiteout(fi) {
+			mismatches = append(mismatches, fmt.Sprintf("%s: should be a whiteout", rel))
+		}
+	}
+	return mismatchError(mismatches)
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	. "github.com/google/go-cmp/cmp"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/sys/unix"
+	"isula.org/isula-transform/transform"
+	"isula.org/isula-transform/types"
+	"isula.org/isula-transform/utils"
+)
+
+// prepareTestUpper makes an upper directory of overlay with a whiteout and an opaque directory
+func prepareTestUpper(t *testing.T, upper string) {
+	for _, dir := range []string{"etc", "opt/app", "mnt/data"} {
+		if err := os.MkdirAll(filepath.Join(upper, dir), 0755); err != nil {
+			t.Skipf("prepare upper: %v", err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(upper, "etc/conf"), []byte("conf"), 0644); err != nil {
+		t.Skipf("prepare upper: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(upper, "opt/app/bin"), []byte("bin"), 0755); err != nil {
+		t.Skipf("prepare upper: %v", err)
+	}
+	if err := unix.Mknod(filepath.Join(upper, "etc/old"), unix.S_IFCHR, 0); err != nil {
+		t.Skipf("make whiteout: %v", err)
+	}
+	if err := unix.Lsetxattr(filepath.Join(upper, "opt"), overlayOpaqueXattr, []byte("y"), 0); err != nil {
+		t.Skipf("set opaque xattr: %v", err)
+	}
+}
+
+func Test_layerWalk_overlay(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	prepareTestUpper(t, tmpdir)
+
+	Convey("Test_layerWalk_overlay", t, func() {
+		var got []container.ContainerChangeResponseItem
+		err := layerWalk(tmpdir, map[string]types.Mount{
+			"/mnt/data": {Destination: "/mnt/data"},
+		}, overlayFormat{}, func(change container.ContainerChangeResponseItem) error {
+			got = append(got, change)
+			return nil
+		})
+		So(err, ShouldBeNil)
+		So(Diff(got, []container.ContainerChangeResponseItem{
+			{Kind: metaItem, Path: "/etc"},
+			{Kind: addItem, Path: "/etc/conf"},
+			{Kind: delItem, Path: "/etc/old"},
+			{Kind: metaItem, Path: "/mnt"},
+			{Kind: delItem, Path: "/opt"},
+			{Kind: metaItem, Path: "/opt"},
+			{Kind: metaItem, Path: "/opt/app"},
+			{Kind: addItem, Path: "/opt/app/bin"},
+		}), ShouldBeBlank)
+	})
+}
+
+func Test_overlayToDeviceMapper(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	upper, rootfs := filepath.Join(tmpdir, "diff"), filepath.Join(tmpdir, "rootfs")
+	prepareTestUpper(t, upper)
+	for _, file := range []string{"etc/old", "opt/lower/file"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(rootfs, file)), 0755); err != nil {
+			t.Skipf("prepare rootfs: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(rootfs, file), []byte("lower"), 0644); err != nil {
+			t.Skipf("prepare rootfs: %v", err)
+		}
+	}
+
+	if err := unix.Lsetxattr(filepath.Join(upper, "etc/conf"), overlayXattrPrefix+"origin", []byte("x"), 0); err != nil {
+		t.Skipf("set origin xattr: %v", err)
+	}
+
+	Convey("Test_overlayToDeviceMapper", t, func() {
+		walk := func(fn changeFunc) error {
+			return layerWalk(upper, nil, overlayFormat{}, fn)
+		}
+		privXattrs := privateXattrs(transform.Overlay2)
+		So(applyChanges(context.Background(), upper, rootfs, walk, nil, nil, "", privXattrs...), ShouldBeNil)
+		// the private xattrs of overlay are not migrated out of overlay
+		for _, path := range []string{"opt", "etc/conf"} {
+			xattrs, err := utils.Lgetxattrs(filepath.Join(rootfs, path))
+			So(err, ShouldBeNil)
+			So(xattrs, ShouldBeEmpty)
+		}
+
+		_, err := os.Lstat(filepath.Join(rootfs, "etc/old"))
+		So(os.IsNotExist(err), ShouldBeTrue)
+		_, err = os.Lstat(filepath.Join(rootfs, "opt/lower"))
+		So(os.IsNotExist(err), ShouldBeTrue)
+		data, err := ioutil.ReadFile(filepath.Join(rootfs, "opt/app/bin"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "bin")
+
+		So(verifyChanges(upper, rootfs, walk, nil, checkRemoved, privXattrs...), ShouldBeNil)
+		So(verifyChanges(upper, rootfs, walk, nil, checkRemoved), ShouldBeError)
+
+		size, err := changesSize(upper, walk)
+		So(err, ShouldBeNil)
+		So(size, ShouldBeGreaterThan, 0)
+	})
+}
+
+func Test_hardLinkedChanges(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	upper, rootfs := filepath.Join(tmpdir, "diff"), filepath.Join(tmpdir, "rootfs")
+	if err := os.MkdirAll(filepath.Join(upper, "opt"), 0755); err != nil {
+		t.Skipf("prepare upper: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(upper, "opt/data"), make([]byte, 64<<10), 0644); err != nil {
+		t.Skipf("prepare upper: %v", err)
+	}
+	if err := os.MkdirAll(rootfs, 0755); err != nil {
+		t.Skipf("prepare rootfs: %v", err)
+	}
+
+	Convey("Test_hardLinkedChanges", t, func() {
+		walk := func(fn changeFunc) error {
+			return layerWalk(upper, nil, overlayFormat{}, fn)
+		}
+		single, err := changesSize(upper, walk)
+		So(err, ShouldBeNil)
+		So(os.Link(filepath.Join(upper, "opt/data"), filepath.Join(upper, "opt/link")), ShouldBeNil)
+		size, err := changesSize(upper, walk)
+		So(err, ShouldBeNil)
+		So(size, ShouldEqual, single)
+
+		So(applyChanges(context.Background(), upper, rootfs, walk, nil, nil, ""), ShouldBeNil)
+		data, err := os.Stat(filepath.Join(rootfs, "opt/data"))
+		So(err, ShouldBeNil)
+		link, err := os.Stat(filepath.Join(rootfs, "opt/link"))
+		So(err, ShouldBeNil)
+		So(os.SameFile(data, link), ShouldBeTrue)
+		So(verifyChanges(upper, rootfs, walk, nil, checkRemoved), ShouldBeNil)
+	})
+}
+
+func Test_deviceMapperToOverlay(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	ctrRoot, upper := filepath.Join(tmpdir, "rootfs"), filepath.Join(tmpdir, "diff")
+	for _, dir := range []string{ctrRoot + "/etc/app", ctrRoot + "/var/log", upper} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Skipf("prepare rootfs: %v", err)
+		}
+	}
+	if err := ioutil.WriteFile(ctrRoot+"/etc/app/conf", []byte("conf"), 0600); err != nil {
+		t.Skipf("prepare rootfs: %v", err)
+	}
+	if err := os.Chmod(ctrRoot+"/etc", 0700); err != nil {
+		t.Skipf("prepare rootfs: %v", err)
+	}
+
+	Convey("Test_deviceMapperToOverlay", t, func() {
+		changes := []container.ContainerChangeResponseItem{
+			{Kind: addItem, Path: "/etc/app/conf"},
+			{Kind: delItem, Path: "/var/log/old.log"},
+		}
+		walk := func(fn changeFunc) error {
+			for _, change := range changes {
+				if err := fn(change); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		So(applyChangesToUpper(context.Background(), ctrRoot, upper, walk, utils.NewRateLimiter(1024*1024), nil), ShouldBeNil)
+
+		fi, err := os.Lstat(upper + "/var/log/old.log")
+		So(err, ShouldBeNil)
+		So(isWhiteout(fi), ShouldBeTrue)
+		fi, err = os.Lstat(upper + "/etc")
+		So(err, ShouldBeNil)
+		So(fi.Mode().Perm(), ShouldEqual, os.FileMode(0700))
+		data, err := ioutil.ReadFile(upper + "/etc/app/conf")
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "conf")
+
+		So(verifyChanges(ctrRoot, upper, walk, nil, checkWhiteouts), ShouldBeNil)
+		So(checkWhiteouts(upper, []string{"/etc/app"}), ShouldBeError)
+	})
+}
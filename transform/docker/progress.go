@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/pkg/errors"
+	"isula.org/isula-transform/transform"
+)
+
+// progressInterval limits how often the copied bytes of a container are published
+const progressInterval = 200 * time.Millisecond
+
+// phaseTracker publishes the phases of a container one after another and limits each of them by its timeout
+type phaseTracker struct {
+	events   *transform.EventBus
+	timeouts map[string]time.Duration
+	id       string
+	phase    string
+	start    time.Time
+	// parent is the context the phase is derived from, ctx is done when the phase times out
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newPhaseTracker(events *transform.EventBus, timeouts map[string]time.Duration, id string) *phaseTracker {
+	return &phaseTracker{events: events, timeouts: timeouts, id: id}
+}
+
+// enter finishes the current phase successfully and starts phase,
+// the returned context of the phase is derived from ctx
+func (p *phaseTracker) enter(ctx context.Context, phase string) context.Context {
+	p.finish(nil)
+	p.phase, p.start, p.parent = phase, time.Now(), ctx
+	if timeout := p.timeouts[phase]; timeout > 0 {
+		p.ctx, p.cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		p.ctx, p.cancel = context.WithCancel(ctx)
+	}
+	p.events.Publish(transform.Event{
+		Type:      transform.EventPhaseStarted,
+		Container: p.id,
+		Phase:     phase,
+		Time:      p.start,
+	})
+	return p.ctx
+}
+
+// finish finishes the current phase with err, which is returned with the cause if the phase times out
+func (p *phaseTracker) finish(err error) error {
+	if p.phase == "" {
+		return err
+	}
+	if err != nil && p.parent.Err() == nil && p.ctx.Err() == context.DeadlineExceeded {
+		err = errors.Wrapf(err, "phase %s timed out after %s", p.phase, p.timeouts[p.phase])
+	}
+	p.cancel()
+	ev := transform.Event{
+		Type:      transform.EventPhaseFinished,
+		Container: p.id,
+		Phase:     p.phase,
+		Duration:  time.Since(p.start),
+		Ok:        err == nil,
+	}
+	if err != nil {
+		ev.Msg = err.Error()
+	}
+	p.events.Publish(ev)
+	p.phase = ""
+	return err
+}
+
+// copyProgress publishes the bytes of the read-write layer of a container copied so far
+type copyProgress struct {
+	events *transform.EventBus
+	id     string
+	total  int64
+	copied int64
+	last   time.Time
+}
+
+// newCopyProgress returns the progress of the container id copying total bytes,
+// a negative total means that the size of the read-write layer is unknown
+func newCopyProgress(events *transform.EventBus, id string, total int64) *copyProgress {
+	if total < 0 {
+		total = 0
+	}
+	return &copyProgress{events: events, id: id, total: total}
+}
+
+// wrap counts the size of the changes walked from srcRoot once they are applied
+func (p *copyProgress) wrap(srcRoot string, walk changeWalker) changeWalker {
+	if !p.events.Active() {
+		return walk
+	}
+	p.publish()
+	return func(fn changeFunc) error {
+		usage := newDiskUsage()
+		return walk(func(change container.ContainerChangeResponseItem) error {
+			if err := fn(change); err != nil {
+				return err
+			}
+			if change.Kind != addItem && change.Kind != changeItem {
+				return nil
+			}
+			if size, err := usage.add(srcRoot + change.Path); err == nil {
+				p.add(size)
+			}
+			return nil
+		})
+	}
+}
+
+func (p *copyProgress) add(n int64) {
+	p.copied += n
+	if time.Since(p.last) >= progressInterval {
+		p.publish()
+	}
+}
+
+func (p *copyProgress) publish() {
+	p.last = time.Now()
+	p.events.Publish(transform.Event{
+		Type:      transform.EventBytesCopied,
+		Container: p.id,
+		Bytes:     p.copied,
+		Total:     p.total,
+		Time:      p.last,
+	})
+}
+
+// done publishes the final bytes copied, which are the total if it is unknown before
+func (p *copyProgress) done() {
+	if p.events.Active() {
+		if p.total == 0 {
+			p.total = p.copied
+		}
+		p.publish()
+	}
+}
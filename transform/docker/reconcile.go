@@ -48,15 +48,10 @@ func v2ConfigWithImage(image string) v2ConfigReconcileOpt {
 	}
 }
 
-func v2ConfigWithCgroupParent(cgroupParent string) v2ConfigReconcileOpt {
+// v2ConfigWithCgroupParent sets the cgroup parent of isulad mapped from the cgroup path of docker
+func v2ConfigWithCgroupParent(cgroupParent string, m cgroupMapper) v2ConfigReconcileOpt {
 	return func(v2 *types.IsuladV2Config) {
-		var cgroupDir string
-		if strings.HasPrefix(cgroupParent, "/docker/") || !strings.HasSuffix(cgroupParent, "/"+v2.CommonConfig.ID) {
-			cgroupDir = defaultCgroupDir
-		} else {
-			cgroupDir = strings.TrimSuffix(cgroupParent, "/"+v2.CommonConfig.ID)
-		}
-		v2.CommonConfig.Config.Annotations["cgroup.dir"] = cgroupDir
+		v2.CommonConfig.Config.Annotations["cgroup.dir"] = m.parent(cgroupParent, v2.CommonConfig.ID)
 	}
 }
 
@@ -182,13 +177,20 @@ func reconcileHostConfig(h *types.IsuladHostConfig, runtime string) {
 		logrus.Info("isulad not support unless-stopped policy, transform to always")
 		h.RestartPolicy.Name = "always"
 	}
-	if h.UsernsMode != "" {
+	// sharing the user namespace of another container is the only user namespace mode of isulad
+	if h.UsernsMode != "" && !strings.HasPrefix(h.UsernsMode, containerModePrefix) {
 		logrus.Infof("isulad not allowed share user namespace %s, replace to nil", h.UsernsMode)
 		h.UsernsMode = ""
 	}
+	// docker still accepts the deprecated form key:value of the security options
+	for i, opt := range h.SecurityOpt {
+		if strings.HasPrefix(opt, "seccomp:") {
+			h.SecurityOpt[i] = "seccomp=" + strings.TrimPrefix(opt, "seccomp:")
+		}
+	}
 }
 
-func reconcileOciConfig(s *specs.Spec, c *types.CommonConfig, h *types.IsuladHostConfig) {
+func reconcileOciConfig(s *specs.Spec, c *types.CommonConfig, h *types.IsuladHostConfig, m cgroupMapper) {
 	// Annotations opt sync with CommonConfig
 	for k, v := range c.Config.Annotations {
 		s.Annotations[k] = v
@@ -199,16 +201,13 @@ func reconcileOciConfig(s *specs.Spec, c *types.CommonConfig, h *types.IsuladHos
 
 	// user-defined cgroup path
 	if _, exist := s.Annotations["cgroup.dir"]; !exist {
-		s.Annotations["cgroup.dir"] = defaultCgroupDir
+		s.Annotations["cgroup.dir"] = m.defaultParent()
 	}
 	cgroupDir := s.Annotations["cgroup.dir"]
-	s.Linux.CgroupsPath = filepath.Join(cgroupDir, c.ID)
+	s.Linux.CgroupsPath = m.path(cgroupDir, c.ID)
 
-	// set linux namespace path
-	// pid, ipc and network might be container mode
-	for idx := range s.Linux.Namespaces {
-		s.Linux.Namespaces[idx].Path = ociAdaptSharedNamespaceContainer(s.Linux.Namespaces[idx], h)
-	}
+	// namespaces might be container mode
+	ociAdaptSharedNamespaces(s, h)
 
 	// when privileged, there is no need to create pty device
 	if !h.Privileged {
@@ -247,33 +246,45 @@ func reconcileOciConfig(s *specs.Spec, c *types.CommonConfig, h *types.IsuladHos
 	s.Linux.Devices = s.Linux.Devices[:end]
 }
 
-func ociAdaptSharedNamespaceContainer(ns specs.LinuxNamespace, h *types.IsuladHostConfig) string {
-	isContainer := func(mode string) (string, bool) {
-		parts := strings.SplitN(mode, ":", 2)
-		if len(parts) > 1 && parts[0] == "container" && len(parts[1]) == containerIDLen {
-			return parts[1], true
-		}
-		return "", false
-	}
-
-	switch ns.Type {
+// namespaceMode returns the mode of host config for the namespace typ
+func namespaceMode(typ specs.LinuxNamespaceType, h *types.IsuladHostConfig) string {
+	switch typ {
 	case specs.IPCNamespace:
-		if ipcCtr, ok := isContainer(h.IpcMode); ok {
-			return ipcCtr
-		}
+		return h.IpcMode
 	case specs.PIDNamespace:
-		if pidCtr, ok := isContainer(h.PidMode); ok {
-			return pidCtr
-		}
+		return h.PidMode
 	case specs.NetworkNamespace:
-		if netCtr, ok := isContainer(h.NetworkMode); ok {
-			return netCtr
-		}
+		return h.NetworkMode
+	case specs.UTSNamespace:
+		return h.UTSMode
+	case specs.UserNamespace:
+		return h.UsernsMode
 	default:
 	}
 	return ""
 }
 
+// ociAdaptSharedNamespaces clears the namespace paths left by docker, which are invalid
+// for isulad. For the namespaces in container mode, isulad finds the container by the
+// container:<id> mode of host config when starting and fills in the namespace path
+// of its process, which lcr passes to lxc.
+func ociAdaptSharedNamespaces(s *specs.Spec, h *types.IsuladHostConfig) {
+	exist := make(map[specs.LinuxNamespaceType]bool)
+	for idx := range s.Linux.Namespaces {
+		exist[s.Linux.Namespaces[idx].Type] = true
+		s.Linux.Namespaces[idx].Path = ""
+	}
+	// docker leaves out the namespace shared with the host, so add the ones
+	// shared with containers, user namespace needs the id mappings and is kept as it is
+	for _, typ := range []specs.LinuxNamespaceType{
+		specs.IPCNamespace, specs.PIDNamespace, specs.NetworkNamespace, specs.UTSNamespace,
+	} {
+		if !exist[typ] && strings.HasPrefix(namespaceMode(typ, h), containerModePrefix) {
+			s.Linux.Namespaces = append(s.Linux.Namespaces, specs.LinuxNamespace{Type: typ})
+		}
+	}
+}
+
 func ociAddMustDevice(spec *specs.Spec) {
 	addDeviceFunc := func(major, minor int64) {
 		dev := specs.LinuxDeviceCgroup{
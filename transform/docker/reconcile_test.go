@@ -93,7 +93,7 @@ func Test_reconcileV2Config(t *testing.T) {
 			originCgroupParent := "/docker/" + reconcileTestCtrID
 			expectCfg.CommonConfig.Config.Annotations["cgroup.dir"] = "/isulad"
 			opts := []v2ConfigReconcileOpt{
-				v2ConfigWithCgroupParent(originCgroupParent),
+				v2ConfigWithCgroupParent(originCgroupParent, cgroupMapper{}),
 			}
 			reconcileV2Config(baseCfg, baseLcrPath, opts...)
 			expectCfg.State.FinishedAt = baseCfg.State.FinishedAt
@@ -104,7 +104,7 @@ func Test_reconcileV2Config(t *testing.T) {
 			originCgroupParent := "/test/" + reconcileTestCtrID
 			expectCfg.CommonConfig.Config.Annotations["cgroup.dir"] = "/test"
 			opts := []v2ConfigReconcileOpt{
-				v2ConfigWithCgroupParent(originCgroupParent),
+				v2ConfigWithCgroupParent(originCgroupParent, cgroupMapper{}),
 			}
 			reconcileV2Config(baseCfg, baseLcrPath, opts...)
 			expectCfg.State.FinishedAt = baseCfg.State.FinishedAt
@@ -251,6 +251,76 @@ func Test_reconcileHostConfig(t *testing.T) {
 		So(h.Runtime, ShouldEqual, runtime)
 		So(h.RestartPolicy.Name, ShouldEqual, "always")
 		So(h.UsernsMode, ShouldEqual, "")
+
+		h.UsernsMode = "container:" + reconcileTestConnectCtrID
+		reconcileHostConfig(h, runtime)
+		So(h.UsernsMode, ShouldEqual, "container:"+reconcileTestConnectCtrID)
+
+		h.SecurityOpt = []string{"seccomp:unconfined", "label=disable"}
+		reconcileHostConfig(h, runtime)
+		So(h.SecurityOpt, ShouldResemble, []string{"seccomp=unconfined", "label=disable"})
+	})
+}
+
+func Test_ociAdaptSharedNamespaces(t *testing.T) {
+	Convey("Test_ociAdaptSharedNamespaces", t, func() {
+		mode := "container:" + reconcileTestConnectCtrID
+		testCases := []struct {
+			name   string
+			typ    specs.LinuxNamespaceType
+			h      *types.IsuladHostConfig
+			expect []specs.LinuxNamespace
+		}{
+			{
+				name:   "ipc",
+				typ:    specs.IPCNamespace,
+				h:      &types.IsuladHostConfig{IpcMode: mode},
+				expect: []specs.LinuxNamespace{{Type: specs.IPCNamespace}},
+			},
+			{
+				name:   "pid",
+				typ:    specs.PIDNamespace,
+				h:      &types.IsuladHostConfig{PidMode: mode},
+				expect: []specs.LinuxNamespace{{Type: specs.PIDNamespace}},
+			},
+			{
+				name:   "network",
+				typ:    specs.NetworkNamespace,
+				h:      &types.IsuladHostConfig{NetworkMode: mode},
+				expect: []specs.LinuxNamespace{{Type: specs.NetworkNamespace}},
+			},
+			{
+				name:   "uts",
+				typ:    specs.UTSNamespace,
+				h:      &types.IsuladHostConfig{UTSMode: mode},
+				expect: []specs.LinuxNamespace{{Type: specs.UTSNamespace}},
+			},
+			{
+				name:   "user",
+				typ:    specs.UserNamespace,
+				h:      &types.IsuladHostConfig{UsernsMode: mode},
+				expect: []specs.LinuxNamespace{{Type: specs.UserNamespace}},
+			},
+		}
+		for _, tc := range testCases {
+			Convey(tc.name+" path of docker is cleared", func() {
+				s := &specs.Spec{Linux: &specs.Linux{
+					Namespaces: []specs.LinuxNamespace{{Type: tc.typ, Path: "/proc/1234/ns/" + string(tc.typ)}},
+				}}
+				ociAdaptSharedNamespaces(s, tc.h)
+				So(Diff(s.Linux.Namespaces, tc.expect), ShouldBeBlank)
+			})
+		}
+
+		Convey("missing namespace is added except user", func() {
+			s := &specs.Spec{Linux: &specs.Linux{}}
+			ociAdaptSharedNamespaces(s, &types.IsuladHostConfig{
+				UTSMode:    mode,
+				UsernsMode: mode,
+				IpcMode:    "shareable",
+			})
+			So(Diff(s.Linux.Namespaces, []specs.LinuxNamespace{{Type: specs.UTSNamespace}}), ShouldBeBlank)
+		})
 	})
 }
 
@@ -265,7 +335,7 @@ func Test_reconcileOciConfig(t *testing.T) {
 			},
 			Linux: &specs.Linux{
 				Namespaces: []specs.LinuxNamespace{
-					{Type: "pid"},
+					{Type: "pid", Path: "/proc/1234/ns/pid"},
 					{Type: "network"},
 					{Type: "ipc"},
 					{Type: "uts"},
@@ -312,7 +382,7 @@ func Test_reconcileOciConfig(t *testing.T) {
 			},
 			Linux: &specs.Linux{
 				Namespaces: []specs.LinuxNamespace{
-					{Type: "pid", Path: reconcileTestConnectCtrID},
+					{Type: "pid", Path: ""},
 					{Type: "network", Path: ""},
 					{Type: "ipc", Path: ""},
 					{Type: "uts", Path: ""},
@@ -340,7 +410,7 @@ func Test_reconcileOciConfig(t *testing.T) {
 			PidMode:     "container:" + reconcileTestConnectCtrID,
 			NetworkMode: "container:lessen64",
 			IpcMode:     "notcontainer:lessen64",
-		})
+		}, cgroupMapper{})
 		So(Diff(baseSpec, expectSpec), ShouldBeBlank)
 	})
 }
@@ -0,0 +1,149 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"isula.org/isula-transform/types"
+)
+
+// pathRemap relocates the host paths under from to to
+type pathRemap struct {
+	from string
+	to   string
+}
+
+// pathRemaps are applied to the host paths of the containers, the longest matching from wins
+type pathRemaps []pathRemap
+
+// parsePathRemaps parses the remaps given as old=new, both of them must be absolute paths
+func parsePathRemaps(specs []string) (pathRemaps, error) {
+	var remaps pathRemaps
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		kv := strings.SplitN(spec, "=", 2)
+		if len(kv) != 2 || !filepath.IsAbs(kv[0]) || !filepath.IsAbs(kv[1]) {
+			return nil, fmt.Errorf("invalid path remap %s, expected old=new of absolute paths", spec)
+		}
+		from := filepath.Clean(kv[0])
+		if seen[from] {
+			return nil, fmt.Errorf("path %s is remapped more than once", from)
+		}
+		seen[from] = true
+		remaps = append(remaps, pathRemap{from: from, to: filepath.Clean(kv[1])})
+	}
+	sort.SliceStable(remaps, func(i, j int) bool {
+		return len(remaps[i].from) > len(remaps[j].from)
+	})
+	return remaps, nil
+}
+
+// remap returns path relocated by the longest matching remap and whether it is remapped
+func (rs pathRemaps) remap(path string) (string, bool) {
+	clean := filepath.Clean(path)
+	for _, r := range rs {
+		if clean == r.from {
+			return r.to, true
+		}
+		if r.from == "/" {
+			return filepath.Join(r.to, clean), true
+		}
+		if strings.HasPrefix(clean, r.from+"/") {
+			return r.to + strings.TrimPrefix(clean, r.from), true
+		}
+	}
+	return path, false
+}
+
+// remapSource remaps the source of a mount, the remapped source must exist
+func (rs pathRemaps) remapSource(source string) (string, error) {
+	newSource, ok := rs.remap(source)
+	if !ok {
+		return source, nil
+	}
+	if _, err := os.Stat(newSource); err != nil {
+		return "", errors.Wrapf(err, "source %s remapped from %s", newSource, source)
+	}
+	return newSource, nil
+}
+
+// applyHostConfig remaps the sources of the binds given as source:destination[:options]
+func (rs pathRemaps) applyHostConfig(h *types.IsuladHostConfig) error {
+	if len(rs) == 0 {
+		return nil
+	}
+	for idx, bind := range h.Binds {
+		parts := strings.SplitN(bind, ":", 2)
+		if len(parts) != 2 || !filepath.IsAbs(parts[0]) {
+			continue
+		}
+		source, err := rs.remapSource(parts[0])
+		if err != nil {
+			return errors.Wrapf(err, "remap bind %s", bind)
+		}
+		h.Binds[idx] = source + ":" + parts[1]
+	}
+	return nil
+}
+
+// applyV2Config remaps the sources of the mount points and the log path,
+// the directory of the remapped log path must exist
+func (rs pathRemaps) applyV2Config(v2 *types.IsuladV2Config) error {
+	if len(rs) == 0 {
+		return nil
+	}
+	common := v2.CommonConfig
+	for dest, mp := range common.MountPoints {
+		if mp.Source == "" {
+			continue
+		}
+		source, err := rs.remapSource(mp.Source)
+		if err != nil {
+			return errors.Wrapf(err, "remap mount point %s", dest)
+		}
+		mp.Source = source
+		common.MountPoints[dest] = mp
+	}
+	if logPath, ok := rs.remap(common.LogPath); ok && filepath.IsAbs(common.LogPath) {
+		if _, err := os.Stat(filepath.Dir(logPath)); err != nil {
+			return errors.Wrapf(err, "log path %s remapped from %s", logPath, common.LogPath)
+		}
+		common.LogPath = logPath
+		if common.Config != nil && common.Config.Annotations["log.console.file"] != "" {
+			common.Config.Annotations["log.console.file"] = logPath
+		}
+	}
+	return nil
+}
+
+// applyOciConfig remaps the sources of the bind mounts of s
+func (rs pathRemaps) applyOciConfig(s *specs.Spec) error {
+	for idx := range s.Mounts {
+		if !filepath.IsAbs(s.Mounts[idx].Source) {
+			continue
+		}
+		source, err := rs.remapSource(s.Mounts[idx].Source)
+		if err != nil {
+			return errors.Wrapf(err, "remap mount %s", s.Mounts[idx].Destination)
+		}
+		s.Mounts[idx].Source = source
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/google/go-cmp/cmp"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/types"
+)
+
+func Test_pathRemaps(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	newData, newVolumes := filepath.Join(tmpdir, "data"), filepath.Join(tmpdir, "volumes")
+	for _, dir := range []string{newData + "/app", newVolumes + "/web/_data", tmpdir + "/logs"} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Skipf("prepare remapped paths: %v", err)
+		}
+	}
+
+	Convey("Test_pathRemaps", t, func() {
+		Convey("parse", func() {
+			for _, spec := range []string{"/data", "data=/new", "/data=new", "=/new"} {
+				_, err := parsePathRemaps([]string{spec})
+				So(err, ShouldBeError)
+			}
+			_, err := parsePathRemaps([]string{"/data=/a", "/data/=/b"})
+			So(err, ShouldBeError)
+		})
+
+		remaps, err := parsePathRemaps([]string{
+			"/srv=" + newData,
+			"/var/lib/docker/volumes=" + newVolumes,
+			"/var/log/docker=" + tmpdir + "/logs",
+		})
+		So(err, ShouldBeNil)
+
+		Convey("remap", func() {
+			got, ok := remaps.remap("/srv/app")
+			So(ok, ShouldBeTrue)
+			So(got, ShouldEqual, newData+"/app")
+			got, ok = remaps.remap("/srv")
+			So(ok, ShouldBeTrue)
+			So(got, ShouldEqual, newData)
+			_, ok = remaps.remap("/srv2/app")
+			So(ok, ShouldBeFalse)
+			_, err = remaps.remapSource("/srv/missing")
+			So(err, ShouldBeError)
+		})
+
+		Convey("apply", func() {
+			h := &types.IsuladHostConfig{Binds: []string{"/srv/app:/app:ro", "/etc/localtime:/etc/localtime"}}
+			So(remaps.applyHostConfig(h), ShouldBeNil)
+			So(Diff(h.Binds, []string{newData + "/app:/app:ro", "/etc/localtime:/etc/localtime"}), ShouldBeBlank)
+
+			v2 := &types.IsuladV2Config{CommonConfig: &types.CommonConfig{
+				LogPath: "/var/log/docker/web/console.log",
+				Config: &types.ContainerCfg{Annotations: map[string]string{
+					"log.console.file": "/var/log/docker/web/console.log",
+				}},
+				MountPoints: map[string]types.Mount{
+					"/data": {Destination: "/data", Source: "/var/lib/docker/volumes/web/_data"},
+				},
+			}}
+			So(remaps.applyV2Config(v2), ShouldBeError)
+			v2.CommonConfig.LogPath = "/var/log/docker/console.log"
+			So(remaps.applyV2Config(v2), ShouldBeNil)
+			So(v2.CommonConfig.MountPoints["/data"].Source, ShouldEqual, newVolumes+"/web/_data")
+			So(v2.CommonConfig.LogPath, ShouldEqual, tmpdir+"/logs/console.log")
+			So(v2.CommonConfig.Config.Annotations["log.console.file"], ShouldEqual, tmpdir+"/logs/console.log")
+
+			s := &specs.Spec{Mounts: []specs.Mount{
+				{Destination: "/proc", Source: "proc"},
+				{Destination: "/app", Source: "/srv/app"},
+			}}
+			So(remaps.applyOciConfig(s), ShouldBeNil)
+			So(s.Mounts[0].Source, ShouldEqual, "proc")
+			So(s.Mounts[1].Source, ShouldEqual, newData+"/app")
+			s.Mounts[1].Source = "/srv/missing"
+			So(remaps.applyOciConfig(s), ShouldBeError)
+		})
+	})
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+	"isula.org/isula-transform/pkg/isulad"
+	"isula.org/isula-transform/transform"
+	"isula.org/isula-transform/types"
+)
+
+// Rollback removes the transformed containers from isulad and restores their docker containers,
+// the containers are given by the isulad container IDs, unique ID prefixes or names
+func (t *dockerTransformer) Rollback(ctx context.Context, ids []string, retCh chan transform.Result) {
+	defer close(retCh)
+	names, err := isulad.GetIsuladTool().ContainerNames()
+	if err != nil {
+		for _, ref := range ids {
+			retCh <- transform.Result{Msg: fmt.Sprintf("rollback %s: %v", ref, err)}
+		}
+		return
+	}
+	for _, ref := range ids {
+		id, err := resolveTransformed(ref, names)
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			err = t.revert(ctx, id)
+		}
+		if err != nil {
+			logrus.Errorf("rollback %s failed: %v", ref, err)
+			retCh <- transform.Result{Msg: fmt.Sprintf("rollback %s: %v", ref, err)}
+			continue
+		}
+		retCh <- transform.Result{Ok: true, Msg: fmt.Sprintf("rollback %s: success", ref)}
+	}
+}
+
+// resolveTransformed finds the ID of the transformed container by its name or ID prefix
+func resolveTransformed(ref string, names map[string]string) (string, error) {
+	if id, ok := names[strings.TrimPrefix(ref, "/")]; ok {
+		return id, nil
+	}
+	var matched []string
+	for _, id := range names {
+		if ref != "" && strings.HasPrefix(id, ref) {
+			matched = append(matched, id)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return "", errCtrNotFound
+	case 1:
+		return matched[0], nil
+	default:
+	}
+	sort.Strings(matched)
+	return "", errors.Errorf("multiple containers found with prefix %s: %s", ref, strings.Join(matched, ", "))
+}
+
+// errFinalizeRemoved refuses the rollback of the containers whose docker container has been removed
+var errFinalizeRemoved = errors.New("docker container was removed by --docker-finalize=remove, " +
+	"the transformation is irreversible")
+
+// revert undoes the transformation of container id in the reverse order of transform,
+// it is refused before anything is changed if the docker container can not be restored
+func (t *dockerTransformer) revert(ctx context.Context, id string) error {
+	rec, err := t.loadFinalizeRecord(id)
+	if err != nil {
+		if !os.IsNotExist(errors.Cause(err)) {
+			logrus.Errorf("load finalize record of %s failed: %v", id, err)
+			return err
+		}
+		rec = nil
+	}
+	if rec != nil && rec.Action == finalizeRemove {
+		return errFinalizeRemoved
+	}
+
+	iSulad := isulad.GetIsuladTool()
+	// isulad has the container if it was started or isulad was restarted after the transformation,
+	// the files of the container are kept and docker is not restored as long as isulad may run it
+	if client, err := iSulad.Client(); err == nil {
+		if err := removeFromIsulad(ctx, client, id); err != nil {
+			logrus.Errorf("remove container %s from isulad failed: %v", id, err)
+			return err
+		}
+	}
+
+	var cfg types.IsuladV2Config
+	if data, err := ioutil.ReadFile(iSulad.GetConfigV2Path(id)); err == nil &&
+		json.Unmarshal(data, &cfg) == nil && cfg.CommonConfig != nil && cfg.CommonConfig.ShmPath != "" {
+		if err := unix.Unmount(cfg.CommonConfig.ShmPath, unix.MNT_DETACH); err != nil && err != unix.EINVAL &&
+			err != unix.ENOENT {
+			logrus.Warnf("umount %s err: %v", cfg.CommonConfig.ShmPath, err)
+		}
+	}
+	t.sd.Cleanup(id)
+	if err := iSulad.Cleanup(id); err != nil {
+		logrus.Errorf("clean up bundle dir of container %s failed: %v", id, err)
+		return errors.Wrap(err, "clean up bundle dir")
+	}
+
+	unpauseCtx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+	if err := t.client.ContainerUnpause(unpauseCtx, id); err != nil {
+		logrus.Warnf("unpause docker container %s: %v", id, err)
+	}
+	if rec != nil {
+		t.restoreFinalize(rec)
+	}
+	return nil
+}
+
+// removeFromIsulad stops and deletes container id in isulad, each call limited by its own timeout,
+// the container is removed if isulad does not have it
+func removeFromIsulad(ctx context.Context, client isuladClient, id string) error {
+	stopCtx, cancel := context.WithTimeout(ctx, isuladStopTimeout)
+	defer cancel()
+	if err := client.Stop(stopCtx, id); err != nil && !isulad.IsNoSuchContainer(err) {
+		// deleted by force below
+		logrus.Warnf("stop container %s in isulad: %v", id, err)
+	}
+	deleteCtx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+	if err := client.Delete(deleteCtx, id); err != nil && !isulad.IsNoSuchContainer(err) {
+		return errors.Wrap(err, "delete container in isulad")
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_resolveTransformed(t *testing.T) {
+	names := map[string]string{
+		"web":  "abc" + ctrID('1')[3:],
+		"db":   "abd" + ctrID('2')[3:],
+		"solo": ctrID('f'),
+	}
+
+	Convey("Test_resolveTransformed", t, func() {
+		id, err := resolveTransformed("/web", names)
+		So(err, ShouldBeNil)
+		So(id, ShouldEqual, names["web"])
+
+		id, err = resolveTransformed("ff", names)
+		So(err, ShouldBeNil)
+		So(id, ShouldEqual, ctrID('f'))
+
+		_, err = resolveTransformed("ab", names)
+		So(err, ShouldBeError)
+		So(err.Error(), ShouldContainSubstring, "multiple containers found with prefix ab")
+
+		_, err = resolveTransformed("missing", names)
+		So(err, ShouldEqual, errCtrNotFound)
+	})
+}
+
+func Test_revertFinalizeRemoved(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	Convey("Test_revertFinalizeRemoved", t, func() {
+		id := ctrID('r')
+		client := &fakeDockerClient{}
+		dt := &dockerTransformer{client: client, recordRoot: tmpdir}
+		So(dt.saveFinalizeRecord(&finalizeRecord{ID: id, Action: finalizeRemove, Time: time.Now(),
+			Stopped: true}), ShouldBeNil)
+		So(dt.revert(context.Background(), id), ShouldEqual, errFinalizeRemoved)
+		So(client.calls, ShouldBeEmpty)
+		_, err := dt.loadFinalizeRecord(id)
+		So(err, ShouldBeNil)
+	})
+}
+
+func Test_removeFromIsulad(t *testing.T) {
+	Convey("Test_removeFromIsulad", t, func() {
+		id := ctrID('r')
+		fake := &fakeIsuladClient{}
+		So(removeFromIsulad(context.Background(), fake, id), ShouldBeNil)
+		So(fake.calls, ShouldResemble, []string{"stop " + id, "delete " + id})
+
+		fake.deleteErr = fmt.Errorf("isulad /ContainerService/Delete failed with status 404: No such container:%s", id)
+		So(removeFromIsulad(context.Background(), fake, id), ShouldBeNil)
+
+		fake.deleteErr = fmt.Errorf("request /ContainerService/Delete of isulad: context deadline exceeded")
+		So(removeFromIsulad(context.Background(), fake, id), ShouldBeError)
+	})
+}
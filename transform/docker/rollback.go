@@ -13,70 +13,69 @@
 package docker
 
 import (
-	"context"
 	"sync"
-	"sync/atomic"
-)
 
-const (
-	executed   int32 = 1
-	unexecuted int32 = 0
+	"isula.org/isula-transform/transform"
 )
 
 type rollbackFunc func()
 
+// rollback keeps the undo funcs of a group, which are executed in the reverse order of registration
 type rollback struct {
-	wg      *sync.WaitGroup
-	st      *int32
-	ctx     context.Context
 	rbFuncs []rollbackFunc
+	// events receives the rollback steps registered by registerStep
+	events *transform.EventBus
 
-	closed  bool
-	closeCh chan bool
+	// mu protects done, which is set once the rollback is executed, and err, which stops the rollback
+	mu   sync.Mutex
+	done bool
+	err  error
 }
 
 // newRollback returns a rollback instance
-func newRollback(ctx context.Context, wg *sync.WaitGroup) *rollback {
-	initSt := unexecuted
-	return &rollback{
-		wg:      wg,
-		ctx:     ctx,
-		st:      &initSt,
-		closeCh: make(chan bool, 1),
-	}
+func newRollback(events *transform.EventBus) *rollback {
+	return &rollback{events: events}
 }
 
-func (rb *rollback) wait() {
-	rb.wg.Add(1)
-	go func() {
-		defer rb.wg.Done()
-		for {
-			select {
-			case <-rb.ctx.Done():
-				rb.run()
-				return
-			case rb.closed = <-rb.closeCh:
-				return
-			}
-		}
-	}()
+func (rb *rollback) register(f rollbackFunc) {
+	rb.mu.Lock()
+	rb.rbFuncs = append(rb.rbFuncs, f)
+	rb.mu.Unlock()
 }
 
-func (rb *rollback) close() {
-	rb.closeCh <- true
-	close(rb.closeCh)
+// registerStep registers f as the rollback step of container id, which is published after f is executed
+func (rb *rollback) registerStep(id, step string, f rollbackFunc) {
+	rb.register(func() {
+		f()
+		rb.events.Publish(transform.Event{Type: transform.EventRollbackStep, Container: id, Msg: step})
+	})
 }
 
-func (rb *rollback) register(f rollbackFunc) {
-	rb.rbFuncs = append(rb.rbFuncs, f)
+// stop keeps the funcs not executed yet from running, the error is returned by run
+func (rb *rollback) stop(err error) {
+	rb.mu.Lock()
+	rb.err = err
+	rb.mu.Unlock()
 }
 
-func (rb *rollback) run() {
-	if rb.closed || atomic.LoadInt32(rb.st) == executed {
-		return
+// run executes the registered funcs once, it returns the error of the func stopping the rollback
+func (rb *rollback) run() error {
+	rb.mu.Lock()
+	if rb.done {
+		rb.mu.Unlock()
+		return nil
 	}
-	atomic.StoreInt32(rb.st, executed)
-	for i := len(rb.rbFuncs) - 1; i >= 0; i-- {
-		rb.rbFuncs[i]()
+	rb.done = true
+	funcs := rb.rbFuncs
+	rb.mu.Unlock()
+	for i := len(funcs) - 1; i >= 0; i-- {
+		funcs[i]()
+		rb.mu.Lock()
+		err := rb.err
+		rb.mu.Unlock()
+		if err != nil {
+			return err
+		}
 	}
+	return nil
 }
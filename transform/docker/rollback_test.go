@@ -14,31 +14,25 @@ package docker
 
 import (
 	"container/list"
-	"context"
-	"sync"
+	"fmt"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/transform"
 )
 
 func Test_rollback(t *testing.T) {
 	Convey("Test rollback", t, func() {
-		wg := new(sync.WaitGroup)
-
-		Convey("Test rollback with cancel", func() {
+		Convey("Test rollback in reverse order", func() {
 			l := list.New()
-			ctx, cancel := context.WithCancel(context.Background())
-			rb := newRollback(ctx, wg)
-			rb.wait()
+			rb := newRollback(nil)
 			rb.register(func() {
 				l.PushBack("first register, last execute")
 			})
 			rb.register(func() {
 				l.PushBack("last register, first execute")
 			})
-			cancel()
-			wg.Wait()
-			rb.close()
+			rb.run()
 			So(l.Len(), ShouldEqual, 2)
 			So(l.Front().Value, ShouldEqual, "last register, first execute")
 			l.Remove(l.Front())
@@ -46,21 +40,44 @@ func Test_rollback(t *testing.T) {
 			l.Remove(l.Front())
 		})
 
-		Convey("Test rollback with close", func() {
-			l := list.New()
-			ctx, cancel := context.WithCancel(context.Background())
-			rb := newRollback(ctx, wg)
-			rb.wait()
+		Convey("Test rollback run once", func() {
+			var count int
+			rb := newRollback(nil)
 			rb.register(func() {
-				l.PushBack("first register, last execute")
+				count++
 			})
+			rb.run()
+			rb.run()
+			So(count, ShouldEqual, 1)
+		})
+
+		Convey("Test rollback stopped", func() {
+			var executed bool
+			rb := newRollback(nil)
 			rb.register(func() {
-				l.PushBack("last register, first execute")
+				executed = true
+			})
+			rb.register(func() {
+				rb.stop(fmt.Errorf("container may still run"))
+			})
+			So(rb.run(), ShouldBeError, "container may still run")
+			So(executed, ShouldBeFalse)
+		})
+
+		Convey("Test rollback steps", func() {
+			rb := newRollback(transform.NewEventBus())
+			evCh, unsubscribe := rb.events.Subscribe(4)
+			var executed bool
+			rb.registerStep("ctr", "clean up", func() {
+				executed = true
 			})
-			rb.close()
-			cancel()
-			wg.Wait()
-			So(l.Len(), ShouldEqual, 0)
+			rb.run()
+			unsubscribe()
+			So(executed, ShouldBeTrue)
+			ev := <-evCh
+			So(ev.Type, ShouldEqual, transform.EventRollbackStep)
+			So(ev.Container, ShouldEqual, "ctr")
+			So(ev.Msg, ShouldEqual, "clean up")
 		})
 	})
 }
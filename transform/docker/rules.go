@@ -0,0 +1,376 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	"isula.org/isula-transform/types"
+)
+
+// restart policies allowed by isulad
+var restartPolicies = map[string]bool{"no": true, "always": true, "on-failure": true}
+
+// rulesFile is the user-defined rules applied after the built-in reconciliation
+// and the host paths remapped as --remap-path does
+type rulesFile struct {
+	Rules      []*reconcileRule `json:"rules"`
+	RemapPaths []string         `json:"remapPaths,omitempty"`
+}
+
+// ruleSelector selects the containers whose labels, name and image all match,
+// name and image are shell patterns and an empty selector matches all the containers
+type ruleSelector struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Name   string            `json:"name,omitempty"`
+	Image  string            `json:"image,omitempty"`
+}
+
+// annotationRule sets and removes the annotations of the container
+type annotationRule struct {
+	Set    map[string]string `json:"set,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+// mountRule drops the mount at Destination or rewrites its source
+type mountRule struct {
+	Destination string `json:"destination"`
+	Drop        bool   `json:"drop,omitempty"`
+	Source      string `json:"source,omitempty"`
+}
+
+// reconcileRule changes the configs of the containers selected by Match
+type reconcileRule struct {
+	Name        string          `json:"name"`
+	Match       ruleSelector    `json:"match"`
+	Annotations *annotationRule `json:"annotations,omitempty"`
+	// HostConfig overrides the fields of hostconfig.json given in the same format
+	HostConfig    json.RawMessage      `json:"hostConfig,omitempty"`
+	Mounts        []mountRule          `json:"mounts,omitempty"`
+	CgroupParent  string               `json:"cgroupParent,omitempty"`
+	RestartPolicy *types.RestartPolicy `json:"restartPolicy,omitempty"`
+}
+
+// loadRules reads and validates the rules file at path, which is YAML if it ends with .yaml or .yml
+// and JSON otherwise
+func loadRules(path string) (*rulesFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read rules file")
+	}
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, errors.Wrapf(err, "parse rules file %s", path)
+		}
+	}
+	var f rulesFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, errors.Wrapf(err, "parse rules file %s", path)
+	}
+	names := make(map[string]bool)
+	for idx, r := range f.Rules {
+		if r == nil || r.Name == "" {
+			return nil, fmt.Errorf("rule %d of %s has no name", idx, path)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("duplicate rule %s in %s", r.Name, path)
+		}
+		names[r.Name] = true
+		if err := r.validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid rule %s", r.Name)
+		}
+	}
+	if _, err := parsePathRemaps(f.RemapPaths); err != nil {
+		return nil, errors.Wrapf(err, "remap paths of %s", path)
+	}
+	return &f, nil
+}
+
+// yamlToJSON converts the YAML document data into JSON, so that the rules are decoded
+// by the same strict JSON decoder whatever the format is
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	doc, err := jsonValue(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// jsonValue converts the maps decoded from YAML, whose keys can be of any type, into JSON objects
+func jsonValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("non-string key %v", k)
+			}
+			value, err := jsonValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			obj[key] = value
+		}
+		return obj, nil
+	case []interface{}:
+		for i := range v {
+			elem, err := jsonValue(v[i])
+			if err != nil {
+				return nil, err
+			}
+			v[i] = elem
+		}
+		return v, nil
+	default:
+	}
+	return v, nil
+}
+
+func (r *reconcileRule) validate() error {
+	for _, pattern := range []string{r.Match.Name, r.Match.Image} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "pattern %s", pattern)
+		}
+	}
+	if len(r.HostConfig) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(r.HostConfig))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&types.IsuladHostConfig{}); err != nil {
+			return errors.Wrap(err, "hostConfig")
+		}
+	}
+	for _, m := range r.Mounts {
+		if !path.IsAbs(m.Destination) {
+			return fmt.Errorf("mount destination %q is not an absolute path", m.Destination)
+		}
+		if m.Drop == (m.Source != "") {
+			return fmt.Errorf("mount %s needs either drop or source", m.Destination)
+		}
+	}
+	if r.CgroupParent != "" && !path.IsAbs(r.CgroupParent) && !strings.HasSuffix(r.CgroupParent, sliceSuffix) {
+		return fmt.Errorf("cgroup parent %q is neither an absolute path nor a systemd slice", r.CgroupParent)
+	}
+	if r.RestartPolicy != nil && !restartPolicies[r.RestartPolicy.Name] {
+		return fmt.Errorf("restart policy %q is not supported by isulad", r.RestartPolicy.Name)
+	}
+	return nil
+}
+
+func (r *reconcileRule) match(ctr *types.DockerV2Config) bool {
+	var (
+		labels map[string]string
+		image  string
+	)
+	if ctr.Config != nil {
+		labels, image = ctr.Config.Labels, ctr.Config.Image
+	}
+	for k, v := range r.Match.Labels {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	if r.Match.Name != "" {
+		if ok, _ := path.Match(r.Match.Name, strings.TrimPrefix(ctr.Name, "/")); !ok {
+			return false
+		}
+	}
+	if r.Match.Image != "" {
+		if ok, _ := path.Match(r.Match.Image, image); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// reconcileRules are the rules selecting a container, applied in the order of the rules file
+type reconcileRules []*reconcileRule
+
+// matchRules returns the rules selecting container id
+func (t *dockerTransformer) matchRules(id string) (reconcileRules, error) {
+	if len(t.rules) == 0 {
+		return nil, nil
+	}
+	ctr, err := t.loadV2Config(id)
+	if err != nil {
+		return nil, err
+	}
+	var matched reconcileRules
+	for _, r := range t.rules {
+		if r.match(ctr) {
+			matched = append(matched, r)
+		}
+	}
+	return matched, nil
+}
+
+// cgroupParent returns the cgroup parent set by r, cgroupParent taking precedence over
+// the one in hostConfig
+func (r *reconcileRule) cgroupParent() string {
+	if r.CgroupParent != "" || len(r.HostConfig) == 0 {
+		return r.CgroupParent
+	}
+	var h types.IsuladHostConfig
+	if err := json.Unmarshal(r.HostConfig, &h); err != nil {
+		return ""
+	}
+	return h.CgroupParent
+}
+
+// checkCgroupParents checks the cgroup parents set by the rules against the cgroup driver
+// of isulad since they are written into the configs of isulad as they are
+func (rs reconcileRules) checkCgroupParents(m cgroupMapper) error {
+	for _, r := range rs {
+		if parent := r.cgroupParent(); parent != "" {
+			if err := m.checkParent(parent); err != nil {
+				return errors.Wrapf(err, "rule %s", r.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// names returns the names of the rules
+func (rs reconcileRules) names() []string {
+	names := make([]string, 0, len(rs))
+	for _, r := range rs {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// applyHostConfig overrides the fields of h, the restart policy, cgroup parent and binds
+func (rs reconcileRules) applyHostConfig(h *types.IsuladHostConfig) error {
+	for _, r := range rs {
+		if len(r.HostConfig) > 0 {
+			if err := json.Unmarshal(r.HostConfig, h); err != nil {
+				return errors.Wrapf(err, "apply hostConfig of rule %s", r.Name)
+			}
+		}
+		if r.RestartPolicy != nil {
+			policy := *r.RestartPolicy
+			h.RestartPolicy = &policy
+		}
+		if r.CgroupParent != "" {
+			h.CgroupParent = r.CgroupParent
+		}
+		h.Binds = r.applyBinds(h.Binds)
+	}
+	return nil
+}
+
+// applyBinds drops or rewrites the binds given as source:destination[:options]
+func (r *reconcileRule) applyBinds(binds []string) []string {
+	if len(r.Mounts) == 0 {
+		return binds
+	}
+	var kept []string
+	for _, bind := range binds {
+		parts := strings.SplitN(bind, ":", 3)
+		var m *mountRule
+		if len(parts) > 1 {
+			m = r.mountRule(parts[1])
+		}
+		switch {
+		case m == nil:
+		case m.Drop:
+			continue
+		default:
+			parts[0] = m.Source
+			bind = strings.Join(parts, ":")
+		}
+		kept = append(kept, bind)
+	}
+	return kept
+}
+
+func (r *reconcileRule) mountRule(dest string) *mountRule {
+	for idx := range r.Mounts {
+		if path.Clean(r.Mounts[idx].Destination) == path.Clean(dest) {
+			return &r.Mounts[idx]
+		}
+	}
+	return nil
+}
+
+// applyV2Config sets and removes the annotations, the cgroup directory and the mount points of v2
+func (rs reconcileRules) applyV2Config(v2 *types.IsuladV2Config) {
+	common := v2.CommonConfig
+	for _, r := range rs {
+		if r.Annotations != nil {
+			for k, v := range r.Annotations.Set {
+				common.Config.Annotations[k] = v
+			}
+			for _, k := range r.Annotations.Remove {
+				delete(common.Config.Annotations, k)
+			}
+		}
+		if parent := r.cgroupParent(); parent != "" {
+			common.Config.Annotations["cgroup.dir"] = parent
+		}
+		for dest, mp := range common.MountPoints {
+			m := r.mountRule(dest)
+			switch {
+			case m == nil:
+			case m.Drop:
+				delete(common.MountPoints, dest)
+			default:
+				mp.Source = m.Source
+				common.MountPoints[dest] = mp
+			}
+		}
+	}
+}
+
+// applyOciConfig removes the annotations and drops or rewrites the mounts of s,
+// the annotations set and the cgroup directory come from the v2 config
+func (rs reconcileRules) applyOciConfig(s *specs.Spec) {
+	for _, r := range rs {
+		if r.Annotations != nil {
+			for _, k := range r.Annotations.Remove {
+				delete(s.Annotations, k)
+			}
+		}
+		if len(r.Mounts) == 0 {
+			continue
+		}
+		mounts := s.Mounts[:0]
+		for _, mnt := range s.Mounts {
+			m := r.mountRule(mnt.Destination)
+			switch {
+			case m == nil:
+			case m.Drop:
+				continue
+			default:
+				mnt.Source = m.Source
+			}
+			mounts = append(mounts, mnt)
+		}
+		s.Mounts = mounts
+	}
+}
@@ -0,0 +1,233 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/google/go-cmp/cmp"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/pkg/isulad"
+	"isula.org/isula-transform/types"
+)
+
+const testRules = `{
+	"rules": [
+		{
+			"name": "web",
+			"match": {"labels": {"team": "web"}, "name": "web-*", "image": "nginx:*"},
+			"annotations": {"set": {"app": "web"}, "remove": ["debug"]},
+			"hostConfig": {"ShmSize": 1024, "Privileged": true},
+			"mounts": [{"destination": "/data", "drop": true}, {"destination": "/logs", "source": "/srv/logs"}],
+			"cgroupParent": "/isulad/web",
+			"restartPolicy": {"Name": "on-failure", "MaximumRetryCount": 3}
+		},
+		{
+			"name": "all",
+			"match": {},
+			"annotations": {"set": {"migrated": "true"}}
+		}
+	]
+}`
+
+const testYAMLRules = `
+rules:
+  - name: web
+    match:
+      labels: {team: web}
+      name: web-*
+      image: "nginx:*"
+    annotations:
+      set: {app: web}
+      remove: [debug]
+    hostConfig: {ShmSize: 1024, Privileged: true}
+    mounts:
+      - {destination: /data, drop: true}
+      - {destination: /logs, source: /srv/logs}
+    cgroupParent: /isulad/web
+    restartPolicy: {Name: on-failure, MaximumRetryCount: 3}
+  - name: all
+    match: {}
+    annotations:
+      set: {migrated: "true"}
+`
+
+func writeTestRules(t *testing.T, dir, content string) string {
+	return writeTestRulesAs(t, dir, "rules.json", content)
+}
+
+func writeTestRulesAs(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Skipf("write rules: %v", err)
+	}
+	return path
+}
+
+func Test_loadRules(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	Convey("Test_loadRules", t, func() {
+		f, err := loadRules(writeTestRules(t, tmpdir, testRules))
+		So(err, ShouldBeNil)
+		So(Diff(reconcileRules(f.Rules).names(), []string{"web", "all"}), ShouldBeBlank)
+
+		for _, content := range []string{
+			`{"rules": [{"match": {}}]}`,
+			`{"rules": [{"name": "a"}, {"name": "a"}]}`,
+			`{"rules": [{"name": "a", "unknown": true}]}`,
+			`{"rules": [{"name": "a", "match": {"name": "["}}]}`,
+			`{"rules": [{"name": "a", "hostConfig": {"Shm": 1}}]}`,
+			`{"rules": [{"name": "a", "mounts": [{"destination": "data", "drop": true}]}]}`,
+			`{"rules": [{"name": "a", "mounts": [{"destination": "/data"}]}]}`,
+			`{"rules": [{"name": "a", "cgroupParent": "isulad"}]}`,
+			`{"rules": [{"name": "a", "restartPolicy": {"Name": "unless-stopped"}}]}`,
+			`{"rules": [], "remapPaths": ["/var/lib/docker"]}`,
+		} {
+			_, err := loadRules(writeTestRules(t, tmpdir, content))
+			So(err, ShouldBeError)
+		}
+		_, err = loadRules(filepath.Join(tmpdir, "notexist.json"))
+		So(err, ShouldBeError)
+
+		Convey("yaml", func() {
+			y, err := loadRules(writeTestRulesAs(t, tmpdir, "rules.yaml", testYAMLRules))
+			So(err, ShouldBeNil)
+			var yh, fh types.IsuladHostConfig
+			So(json.Unmarshal(y.Rules[0].HostConfig, &yh), ShouldBeNil)
+			So(json.Unmarshal(f.Rules[0].HostConfig, &fh), ShouldBeNil)
+			So(Diff(yh, fh), ShouldBeBlank)
+			y.Rules[0].HostConfig = f.Rules[0].HostConfig
+			So(Diff(y, f), ShouldBeBlank)
+
+			for _, content := range []string{
+				"rules:\n  - name: a\n    unknown: true\n",
+				"rules:\n  - name: a\n    match: {1: x}\n",
+				"rules: [",
+			} {
+				_, err := loadRules(writeTestRulesAs(t, tmpdir, "rules.yml", content))
+				So(err, ShouldBeError)
+			}
+		})
+	})
+}
+
+func Test_reconcileRules(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	f, err := loadRules(writeTestRules(t, tmpdir, testRules))
+	if err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+	all := f.Rules
+
+	Convey("Test_reconcileRules", t, func() {
+		Convey("match", func() {
+			ctr := &types.DockerV2Config{
+				Name:   "/web-1",
+				Config: &types.ContainerCfg{Image: "nginx:1.19", Labels: map[string]string{"team": "web"}},
+			}
+			So(all[0].match(ctr), ShouldBeTrue)
+			So(all[1].match(&types.DockerV2Config{}), ShouldBeTrue)
+			ctr.Config.Labels["team"] = "db"
+			So(all[0].match(ctr), ShouldBeFalse)
+			ctr.Config.Labels["team"] = "web"
+			ctr.Config.Image = "httpd:2"
+			So(all[0].match(ctr), ShouldBeFalse)
+			ctr.Config.Image = "nginx:1.19"
+			ctr.Name = "/db-1"
+			So(all[0].match(ctr), ShouldBeFalse)
+		})
+
+		rules := reconcileRules(all)
+		Convey("checkCgroupParents", func() {
+			cgroupfs, systemd := isulad.CgroupDriverCgroupfs, isulad.CgroupDriverSystemd
+			So(rules.checkCgroupParents(newCgroupMapper(systemd, cgroupfs, false)), ShouldBeNil)
+			So(rules.checkCgroupParents(newCgroupMapper(cgroupfs, systemd, false)), ShouldBeError)
+
+			hostConfigOnly := reconcileRules{{Name: "h", HostConfig: []byte(`{"CgroupParent": "/apps/web"}`)}}
+			So(hostConfigOnly.checkCgroupParents(newCgroupMapper(cgroupfs, systemd, false)), ShouldBeError)
+			So(hostConfigOnly.checkCgroupParents(newCgroupMapper(cgroupfs, cgroupfs, false)), ShouldBeNil)
+
+			v2 := &types.IsuladV2Config{CommonConfig: &types.CommonConfig{
+				Config: &types.ContainerCfg{Annotations: map[string]string{"cgroup.dir": "/isulad"}},
+			}}
+			hostConfigOnly.applyV2Config(v2)
+			So(v2.CommonConfig.Config.Annotations["cgroup.dir"], ShouldEqual, "/apps/web")
+		})
+
+		Convey("applyHostConfig", func() {
+			h := &types.IsuladHostConfig{
+				ShmSize:       64,
+				Binds:         []string{"/mnt/data:/data", "/var/log/web:/logs:ro", "/etc/app:/etc/app"},
+				RestartPolicy: &types.RestartPolicy{Name: "always"},
+			}
+			So(rules.applyHostConfig(h), ShouldBeNil)
+			So(Diff(h, &types.IsuladHostConfig{
+				ShmSize:       1024,
+				Privileged:    true,
+				Binds:         []string{"/srv/logs:/logs:ro", "/etc/app:/etc/app"},
+				RestartPolicy: &types.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3},
+				CgroupParent:  "/isulad/web",
+			}), ShouldBeBlank)
+		})
+
+		Convey("applyV2Config", func() {
+			v2 := &types.IsuladV2Config{CommonConfig: &types.CommonConfig{
+				Config: &types.ContainerCfg{Annotations: map[string]string{"debug": "1", "cgroup.dir": "/isulad"}},
+				MountPoints: map[string]types.Mount{
+					"/data": {Destination: "/data", Source: "/mnt/data"},
+					"/logs": {Destination: "/logs", Source: "/var/log/web"},
+				},
+			}}
+			rules.applyV2Config(v2)
+			So(Diff(v2.CommonConfig.Config.Annotations, map[string]string{
+				"app": "web", "migrated": "true", "cgroup.dir": "/isulad/web",
+			}), ShouldBeBlank)
+			So(Diff(v2.CommonConfig.MountPoints, map[string]types.Mount{
+				"/logs": {Destination: "/logs", Source: "/srv/logs"},
+			}), ShouldBeBlank)
+		})
+
+		Convey("applyOciConfig", func() {
+			s := &specs.Spec{
+				Annotations: map[string]string{"debug": "1", "app": "web"},
+				Mounts: []specs.Mount{
+					{Destination: "/proc", Source: "proc"},
+					{Destination: "/data", Source: "/mnt/data"},
+					{Destination: "/logs", Source: "/var/log/web"},
+				},
+			}
+			rules.applyOciConfig(s)
+			So(Diff(s, &specs.Spec{
+				Annotations: map[string]string{"app": "web"},
+				Mounts: []specs.Mount{
+					{Destination: "/proc", Source: "proc"},
+					{Destination: "/logs", Source: "/srv/logs"},
+				},
+			}), ShouldBeBlank)
+		})
+	})
+}
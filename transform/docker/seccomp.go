@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// seccompUnconfined disables seccomp in both docker and isulad
+	seccompUnconfined = "unconfined"
+	// actKillThread is the name of SCMP_ACT_KILL in the newer runtime spec
+	actKillThread specs.LinuxSeccompAction = "SCMP_ACT_KILL_THREAD"
+	// errnoEPERM is the errno returned by the errno actions of lcr
+	errnoEPERM = 1
+	// errnoENOSYS is returned by the syscalls docker hides from glibc, such as clone3, so that it falls back
+	errnoENOSYS = uint(unix.ENOSYS)
+)
+
+// lcrSeccompActions are the actions lcr converts into the seccomp config of lxc
+var lcrSeccompActions = map[specs.LinuxSeccompAction]bool{
+	specs.ActKill:  true,
+	specs.ActTrap:  true,
+	specs.ActErrno: true,
+	specs.ActTrace: true,
+	specs.ActAllow: true,
+}
+
+// lcrSeccompArches are the architectures lcr converts into the seccomp config of lxc
+var lcrSeccompArches = map[specs.Arch]bool{
+	specs.ArchX86:         true,
+	specs.ArchX86_64:      true,
+	specs.ArchX32:         true,
+	specs.ArchARM:         true,
+	specs.ArchAARCH64:     true,
+	specs.ArchMIPS:        true,
+	specs.ArchMIPS64:      true,
+	specs.ArchMIPS64N32:   true,
+	specs.ArchMIPSEL:      true,
+	specs.ArchMIPSEL64:    true,
+	specs.ArchMIPSEL64N32: true,
+	specs.ArchPPC:         true,
+	specs.ArchPPC64:       true,
+	specs.ArchPPC64LE:     true,
+	specs.ArchS390:        true,
+	specs.ArchS390X:       true,
+}
+
+// seccompExtensions holds the fields of the seccomp config of docker added after the runtime spec lcr reads,
+// they are dropped when the OCI config is loaded
+type seccompExtensions struct {
+	DefaultErrnoRet *uint    `json:"defaultErrnoRet"`
+	ListenerPath    string   `json:"listenerPath"`
+	Flags           []string `json:"flags"`
+	// Syscalls are in the same order as the rules of the seccomp config
+	Syscalls []struct {
+		Names    []string `json:"names"`
+		ErrnoRet *uint    `json:"errnoRet"`
+	} `json:"syscalls"`
+}
+
+// loadSeccompExtensions reads the seccomp extensions from the OCI config data, nil without seccomp
+func loadSeccompExtensions(data []byte) (*seccompExtensions, error) {
+	var cfg struct {
+		Linux *struct {
+			Seccomp *seccompExtensions `json:"seccomp"`
+		} `json:"linux"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.Wrap(err, "unmarshal seccomp config")
+	}
+	if cfg.Linux == nil {
+		return nil, nil
+	}
+	return cfg.Linux.Seccomp, nil
+}
+
+// convertSeccomp converts the seccomp config of s captured from docker into the form lcr creates the
+// container from, and returns the seccomp profile recorded in config.v2 of isulad. profile is the seccomp
+// profile of docker, empty for the default one, unconfined or the content of a custom profile, which has
+// been resolved into s by docker, and ext holds the errnos of the config missing from s
+func convertSeccomp(s *specs.Spec, profile string, ext *seccompExtensions) (string, error) {
+	if profile == seccompUnconfined {
+		if s.Linux != nil {
+			s.Linux.Seccomp = nil
+		}
+		return seccompUnconfined, nil
+	}
+	// privileged containers run without seccomp
+	if s.Linux == nil || s.Linux.Seccomp == nil {
+		return "", nil
+	}
+	seccomp := s.Linux.Seccomp
+	var unknown []string
+	for _, arch := range seccomp.Architectures {
+		if !lcrSeccompArches[arch] {
+			unknown = append(unknown, "arch "+string(arch))
+		}
+	}
+	if ext == nil {
+		ext = &seccompExtensions{}
+	}
+	action, ok := lcrSeccompAction(seccomp.DefaultAction)
+	if !ok {
+		unknown = append(unknown, "default action "+string(seccomp.DefaultAction))
+	}
+	if seccomp.DefaultAction, ok = lcrErrnoAction(action, ext.DefaultErrnoRet); !ok {
+		unknown = append(unknown, fmt.Sprintf("default errno %d", *ext.DefaultErrnoRet))
+	}
+	for i := range seccomp.Syscalls {
+		sc := &seccomp.Syscalls[i]
+		if sc.Action, ok = lcrSeccompAction(sc.Action); !ok {
+			unknown = append(unknown, fmt.Sprintf("action %s of %v", sc.Action, sc.Names))
+		}
+		if i < len(ext.Syscalls) {
+			errno := ext.Syscalls[i].ErrnoRet
+			if sc.Action, ok = lcrErrnoAction(sc.Action, errno); !ok {
+				unknown = append(unknown, fmt.Sprintf("errno %d of %v", *errno, sc.Names))
+			}
+		}
+		for _, name := range sc.Names {
+			if !knownSyscalls[name] {
+				unknown = append(unknown, "syscall "+name)
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("not supported by lcr in seccomp config: %v", unknown)
+	}
+	data, err := json.Marshal(seccomp)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal seccomp config")
+	}
+	return string(data), nil
+}
+
+// lcrSeccompAction returns the action of lcr for action and whether lcr supports it
+func lcrSeccompAction(action specs.LinuxSeccompAction) (specs.LinuxSeccompAction, bool) {
+	if action == actKillThread {
+		return specs.ActKill, true
+	}
+	return action, lcrSeccompActions[action]
+}
+
+// lcrErrnoAction returns the action of lcr for the errno action returning errno and whether lcr can
+// express it. lcr returns EPERM only, the errno action returning ENOSYS becomes the trace action, which
+// fails the syscall with ENOSYS as well when the container is not traced, and the other errnos are refused
+func lcrErrnoAction(action specs.LinuxSeccompAction, errno *uint) (specs.LinuxSeccompAction, bool) {
+	if action != specs.ActErrno || errno == nil || *errno == errnoEPERM {
+		return action, true
+	}
+	if *errno == errnoENOSYS {
+		return specs.ActTrace, true
+	}
+	return action, false
+}
+
+// droppedSeccompFields returns the reasons of the fields of the seccomp config which lcr can not express
+// and are dropped: the flags and the notification listener
+func droppedSeccompFields(ext *seccompExtensions) []string {
+	if ext == nil {
+		return nil
+	}
+	var reasons []string
+	if len(ext.Flags) > 0 {
+		reasons = append(reasons, fmt.Sprintf("seccomp flags %v are dropped", ext.Flags))
+	}
+	if ext.ListenerPath != "" {
+		reasons = append(reasons, fmt.Sprintf("seccomp listener %s is dropped", ext.ListenerPath))
+	}
+	return reasons
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_convertSeccomp(t *testing.T) {
+	newSpec := func() *specs.Spec {
+		return &specs.Spec{Linux: &specs.Linux{Seccomp: &specs.LinuxSeccomp{
+			DefaultAction: specs.ActErrno,
+			Architectures: []specs.Arch{specs.ArchX86_64, specs.ArchX86, specs.ArchX32},
+			Syscalls: []specs.LinuxSyscall{
+				{Names: []string{"accept", "clone3", "openat2"}, Action: specs.ActAllow},
+				{Names: []string{"ptrace"}, Action: actKillThread},
+			},
+		}}}
+	}
+
+	Convey("Test_convertSeccomp", t, func() {
+		Convey("unconfined", func() {
+			s := newSpec()
+			profile, err := convertSeccomp(s, seccompUnconfined, nil)
+			So(err, ShouldBeNil)
+			So(profile, ShouldEqual, seccompUnconfined)
+			So(s.Linux.Seccomp, ShouldBeNil)
+		})
+
+		Convey("without seccomp", func() {
+			profile, err := convertSeccomp(&specs.Spec{Linux: &specs.Linux{}}, "", nil)
+			So(err, ShouldBeNil)
+			So(profile, ShouldBeEmpty)
+		})
+
+		Convey("default or custom profile", func() {
+			s := newSpec()
+			profile, err := convertSeccomp(s, "", nil)
+			So(err, ShouldBeNil)
+			So(s.Linux.Seccomp.Syscalls[1].Action, ShouldEqual, specs.ActKill)
+			So(profile, ShouldContainSubstring, `"defaultAction":"SCMP_ACT_ERRNO"`)
+			So(profile, ShouldContainSubstring, `"openat2"`)
+			So(profile, ShouldNotContainSubstring, string(actKillThread))
+		})
+
+		Convey("unknown to lcr", func() {
+			s := newSpec()
+			s.Linux.Seccomp.Architectures = append(s.Linux.Seccomp.Architectures, specs.ArchPARISC)
+			s.Linux.Seccomp.Syscalls[0].Names = append(s.Linux.Seccomp.Syscalls[0].Names, "no_such_call")
+			s.Linux.Seccomp.Syscalls[1].Action = "SCMP_ACT_KILL_PROCESS"
+			_, err := convertSeccomp(s, "", nil)
+			So(err, ShouldBeError)
+			So(err.Error(), ShouldContainSubstring, "arch SCMP_ARCH_PARISC")
+			So(err.Error(), ShouldContainSubstring, "syscall no_such_call")
+			So(err.Error(), ShouldContainSubstring, "action SCMP_ACT_KILL_PROCESS of [ptrace]")
+		})
+	})
+}
+
+func Test_convertSeccomp_errno(t *testing.T) {
+	Convey("Test_convertSeccomp_errno", t, func() {
+		Convey("default profile of docker", func() {
+			data, err := ioutil.ReadFile("testdata/seccomp_default.json")
+			So(err, ShouldBeNil)
+			var s specs.Spec
+			So(json.Unmarshal(data, &s), ShouldBeNil)
+			ext, err := loadSeccompExtensions(data)
+			So(err, ShouldBeNil)
+
+			profile, err := convertSeccomp(&s, "", ext)
+			So(err, ShouldBeNil)
+			So(s.Linux.Seccomp.DefaultAction, ShouldEqual, specs.ActErrno)
+			// clone3 fails with ENOSYS rather than EPERM so that glibc falls back to clone
+			clone3 := s.Linux.Seccomp.Syscalls[len(s.Linux.Seccomp.Syscalls)-1]
+			So(clone3.Names, ShouldResemble, []string{"clone3"})
+			So(clone3.Action, ShouldEqual, specs.ActTrace)
+			So(profile, ShouldContainSubstring, `{"names":["clone3"],"action":"SCMP_ACT_TRACE"}`)
+			So(droppedSeccompFields(ext), ShouldBeEmpty)
+		})
+
+		Convey("errno unknown to lcr", func() {
+			data := []byte(`{"linux": {"seccomp": {
+				"defaultAction": "SCMP_ACT_ERRNO", "defaultErrnoRet": 38,
+				"syscalls": [
+					{"names": ["bpf"], "action": "SCMP_ACT_ERRNO", "errnoRet": 1},
+					{"names": ["keyctl"], "action": "SCMP_ACT_ERRNO", "errnoRet": 13}
+				]}}}`)
+			var s specs.Spec
+			So(json.Unmarshal(data, &s), ShouldBeNil)
+			ext, err := loadSeccompExtensions(data)
+			So(err, ShouldBeNil)
+			_, err = convertSeccomp(&s, "", ext)
+			So(err, ShouldBeError, "not supported by lcr in seccomp config: [errno 13 of [keyctl]]")
+			So(s.Linux.Seccomp.DefaultAction, ShouldEqual, specs.ActTrace)
+			So(s.Linux.Seccomp.Syscalls[0].Action, ShouldEqual, specs.ActErrno)
+		})
+	})
+}
+
+func Test_droppedSeccompFields(t *testing.T) {
+	Convey("Test_droppedSeccompFields", t, func() {
+		ext, err := loadSeccompExtensions([]byte(`{"linux": {}}`))
+		So(err, ShouldBeNil)
+		So(droppedSeccompFields(ext), ShouldBeEmpty)
+
+		ext, err = loadSeccompExtensions([]byte(`{"linux": {"seccomp": {
+			"defaultAction": "SCMP_ACT_ERRNO", "flags": ["SECCOMP_FILTER_FLAG_LOG"],
+			"listenerPath": "/run/seccomp.sock",
+			"syscalls": [{"names": ["accept"], "action": "SCMP_ACT_ALLOW"}]}}}`))
+		So(err, ShouldBeNil)
+		So(droppedSeccompFields(ext), ShouldResemble, []string{
+			"seccomp flags [SECCOMP_FILTER_FLAG_LOG] are dropped",
+			"seccomp listener /run/seccomp.sock is dropped",
+		})
+
+		_, err = loadSeccompExtensions([]byte(`{`))
+		So(err, ShouldBeError)
+	})
+}
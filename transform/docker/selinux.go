@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"github.com/sirupsen/logrus"
+	"isula.org/isula-transform/types"
+	"isula.org/isula-transform/utils"
+)
+
+const (
+	// relabelShared marks the bind mounts shared by several containers, :z in docker
+	relabelShared = "z"
+	// relabelPrivate marks the bind mounts private to the container, :Z in docker
+	relabelPrivate = "Z"
+)
+
+// relabelMounts labels the sources of the :z and :Z bind mounts of c with its mount label,
+// the level of the label is shared by all the containers for :z
+func relabelMounts(c *types.CommonConfig) error {
+	for _, mp := range c.MountPoints {
+		if mp.Source == "" {
+			continue
+		}
+		var label string
+		switch mp.Relabel {
+		case relabelShared:
+			label = utils.SharedLabel(c.MountLabel)
+		case relabelPrivate:
+			label = c.MountLabel
+		default:
+			continue
+		}
+		if err := utils.Relabel(mp.Source, label); err != nil {
+			logrus.Errorf("relabel %s of mount %s failed: %v", mp.Source, mp.Destination, err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"isula.org/isula-transform/pkg/isulad"
+	"isula.org/isula-transform/types"
+)
+
+const (
+	subuidFile = "/etc/subuid"
+	subgidFile = "/etc/subgid"
+	// usernsSecurityOpt is reported in the security options by dockerd with userns-remap
+	usernsSecurityOpt = "name=userns"
+)
+
+// idShift is the range of the host IDs the root of containers is remapped to by docker userns-remap
+type idShift struct {
+	uid  uint32
+	gid  uint32
+	size uint32
+}
+
+// String returns the shift in the form uid:gid:size taken by the user remap of isulad
+func (s *idShift) String() string {
+	return fmt.Sprintf("%d:%d:%d", s.uid, s.gid, s.size)
+}
+
+// toContainer maps the host IDs in the shifted range back to the IDs in the container
+func (s *idShift) toContainer(uid, gid uint32) (uint32, uint32) {
+	if uid >= s.uid && uid-s.uid < s.size {
+		uid -= s.uid
+	}
+	if gid >= s.gid && gid-s.gid < s.size {
+		gid -= s.gid
+	}
+	return uid, gid
+}
+
+// chown shifts the owners of path back to the IDs in the container, keeping the setuid and setgid bits
+func (s *idShift) chown(path string, fi os.FileInfo) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errors.Errorf("unsupported stat type of %s", path)
+	}
+	uid, gid := s.toContainer(st.Uid, st.Gid)
+	if uid == st.Uid && gid == st.Gid {
+		return nil
+	}
+	if err := os.Lchown(path, int(uid), int(gid)); err != nil {
+		return errors.Wrapf(err, "shift owner of %s", path)
+	}
+	// chown clears the setuid and setgid bits
+	if fi.Mode()&os.ModeSymlink == 0 && fi.Mode()&(os.ModeSetuid|os.ModeSetgid) != 0 {
+		if err := os.Chmod(path, fi.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+			return errors.Wrapf(err, "restore mode of %s", path)
+		}
+	}
+	return nil
+}
+
+// shiftTrees shifts the owners of the paths under root and their children, s is nil if nothing is shifted
+func (s *idShift) shiftTrees(root string, paths ...string) error {
+	if s == nil {
+		return nil
+	}
+	for _, p := range paths {
+		err := filepath.Walk(root+p, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			return s.chown(path, fi)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shiftDirs shifts the owners of the directories under root without their children
+func (s *idShift) shiftDirs(root string, dirs []string) error {
+	if s == nil {
+		return nil
+	}
+	for _, dir := range dirs {
+		fi, err := os.Lstat(root + dir)
+		if err != nil {
+			return err
+		}
+		if err := s.chown(root+dir, fi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shiftManifest shifts the owners recorded in m as the files are shifted when copied
+func (s *idShift) shiftManifest(m manifest) {
+	if s == nil {
+		return
+	}
+	for _, entry := range m {
+		entry.UID, entry.GID = s.toContainer(entry.UID, entry.GID)
+	}
+}
+
+// detectUsernsRemap returns the shift of docker with userns-remap and its remapped data root,
+// whose name is <uid>.<gid> of the remapped root, the size of the shift is read from the subordinate
+// ID files subuid and subgid. It returns nil if docker does not remap
+func detectUsernsRemap(info dockertypes.Info, subuid, subgid string) (*idShift, error) {
+	var remapped bool
+	for _, opt := range info.SecurityOptions {
+		if opt == usernsSecurityOpt {
+			remapped = true
+		}
+	}
+	if !remapped {
+		return nil, nil
+	}
+	ids := strings.Split(filepath.Base(info.DockerRootDir), ".")
+	if len(ids) != 2 {
+		return nil, fmt.Errorf("unexpected data root %s of docker with userns-remap", info.DockerRootDir)
+	}
+	uid, err := strconv.ParseUint(ids[0], 10, 32)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse remapped uid of %s", info.DockerRootDir)
+	}
+	gid, err := strconv.ParseUint(ids[1], 10, 32)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse remapped gid of %s", info.DockerRootDir)
+	}
+	uidSize, err := subIDSize(subuid, uint32(uid))
+	if err != nil {
+		return nil, err
+	}
+	gidSize, err := subIDSize(subgid, uint32(gid))
+	if err != nil {
+		return nil, err
+	}
+	size := uidSize
+	if gidSize < size {
+		size = gidSize
+	}
+	return &idShift{uid: uint32(uid), gid: uint32(gid), size: size}, nil
+}
+
+// subIDSize returns the size of the subordinate IDs starting at start in file
+func subIDSize(file string, start uint32) (uint32, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, errors.Wrap(err, "open subordinate ids")
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// name:start:count
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ":")
+		if len(fields) != 3 || fields[1] != strconv.FormatUint(uint64(start), 10) {
+			continue
+		}
+		size, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return 0, errors.Wrapf(err, "parse %s of %s", scanner.Text(), file)
+		}
+		return uint32(size), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, errors.Wrapf(err, "read %s", file)
+	}
+	return 0, fmt.Errorf("no subordinate ids start at %d in %s", start, file)
+}
+
+// initUserns reads from the remapped data root of docker with userns-remap. The remapping is kept by the
+// user remap of isulad if isulad remaps as well, otherwise the owners are shifted back while copying
+func (t *dockerTransformer) initUserns(info dockertypes.Info) error {
+	shift, err := detectUsernsRemap(info, subuidFile, subgidFile)
+	if err != nil || shift == nil {
+		return err
+	}
+	if filepath.Dir(info.DockerRootDir) == filepath.Clean(t.GraphRoot) {
+		t.GraphRoot = info.DockerRootDir
+	}
+	t.userns = shift
+	if remap := isulad.GetIsuladTool().UsernsRemap(); remap != "" {
+		if remap != shift.String() {
+			return fmt.Errorf("userns remap %s of isulad differs from %s of docker", remap, shift)
+		}
+		t.isuladRemap = true
+	}
+	logrus.Infof("docker remaps the root of containers to %s, graph root %s, isulad remaps: %v",
+		shift, t.GraphRoot, t.isuladRemap)
+	return nil
+}
+
+// ownerShift returns the shift of the owners of the copied files, nil if they are kept as they are
+func (t *dockerTransformer) ownerShift() *idShift {
+	if t.isuladRemap {
+		return nil
+	}
+	return t.userns
+}
+
+// adaptUsernsHostConfig maps the remapping of docker to the user remap of isulad for the containers
+// in the remapped user namespace, whose user namespace mode in docker is usernsMode
+func (t *dockerTransformer) adaptUsernsHostConfig(h *types.IsuladHostConfig, usernsMode string) {
+	h.UserRemap = ""
+	if t.isuladRemap && usernsMode == "" {
+		h.UserRemap = t.userns.String()
+	}
+}
+
+// adaptUsernsOciConfig removes the user namespace of docker from s when isulad does not remap,
+// the owners of the files are shifted back to the IDs in the container instead
+func (t *dockerTransformer) adaptUsernsOciConfig(s *specs.Spec) {
+	if t.userns == nil || t.isuladRemap || s.Linux == nil {
+		return
+	}
+	s.Linux.UIDMappings, s.Linux.GIDMappings = nil, nil
+	end := 0
+	for _, ns := range s.Linux.Namespaces {
+		if ns.Type != specs.UserNamespace {
+			s.Linux.Namespaces[end] = ns
+			end++
+		}
+	}
+	s.Linux.Namespaces = s.Linux.Namespaces[:end]
+}
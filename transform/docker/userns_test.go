@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	. "github.com/google/go-cmp/cmp"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/types"
+)
+
+func Test_detectUsernsRemap(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	subuid, subgid := filepath.Join(tmpdir, "subuid"), filepath.Join(tmpdir, "subgid")
+	if err := ioutil.WriteFile(subuid, []byte("test:200000:1000\ndockremap:100000:65536\n"), 0644); err != nil {
+		t.Skipf("prepare subuid: %v", err)
+	}
+	if err := ioutil.WriteFile(subgid, []byte("dockremap:100000:4096\n"), 0644); err != nil {
+		t.Skipf("prepare subgid: %v", err)
+	}
+	remapped := func(root string) dockertypes.Info {
+		return dockertypes.Info{
+			DockerRootDir:   root,
+			SecurityOptions: []string{"name=seccomp,profile=default", usernsSecurityOpt},
+		}
+	}
+
+	Convey("Test_detectUsernsRemap", t, func() {
+		Convey("not remapped", func() {
+			shift, err := detectUsernsRemap(dockertypes.Info{DockerRootDir: "/var/lib/docker"}, subuid, subgid)
+			So(err, ShouldBeNil)
+			So(shift, ShouldBeNil)
+		})
+
+		Convey("remapped", func() {
+			shift, err := detectUsernsRemap(remapped("/var/lib/docker/100000.100000"), subuid, subgid)
+			So(err, ShouldBeNil)
+			So(shift.String(), ShouldEqual, "100000:100000:4096")
+		})
+
+		Convey("invalid", func() {
+			_, err := detectUsernsRemap(remapped("/var/lib/docker"), subuid, subgid)
+			So(err, ShouldBeError)
+			_, err = detectUsernsRemap(remapped("/var/lib/docker/a.100000"), subuid, subgid)
+			So(err, ShouldBeError)
+			_, err = detectUsernsRemap(remapped("/var/lib/docker/300000.300000"), subuid, subgid)
+			So(err, ShouldBeError)
+			_, err = detectUsernsRemap(remapped("/var/lib/docker/100000.100000"), subuid, filepath.Join(tmpdir, "none"))
+			So(err, ShouldBeError)
+		})
+	})
+}
+
+func Test_idShift(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	Convey("Test_idShift", t, func() {
+		shift := &idShift{uid: 1000, gid: 2000, size: 100}
+
+		Convey("toContainer", func() {
+			uid, gid := shift.toContainer(1000, 2099)
+			So([]uint32{uid, gid}, ShouldResemble, []uint32{0, 99})
+			uid, gid = shift.toContainer(1100, 1000)
+			So([]uint32{uid, gid}, ShouldResemble, []uint32{1100, 1000})
+		})
+
+		Convey("shift manifest", func() {
+			m := manifest{"/a": {Path: "/a", UID: 1001, GID: 2002}}
+			shift.shiftManifest(m)
+			So(m["/a"].UID, ShouldEqual, 1)
+			So(m["/a"].GID, ShouldEqual, 2)
+			var none *idShift
+			none.shiftManifest(m)
+			So(m["/a"].UID, ShouldEqual, 1)
+		})
+
+		Convey("shift trees", func() {
+			if os.Geteuid() != 0 {
+				return
+			}
+			So(os.MkdirAll(filepath.Join(tmpdir, "dir/sub"), 0755), ShouldBeNil)
+			So(ioutil.WriteFile(filepath.Join(tmpdir, "dir/sub/bin"), nil, 0755), ShouldBeNil)
+			for _, p := range []string{"dir", "dir/sub", "dir/sub/bin"} {
+				So(os.Lchown(filepath.Join(tmpdir, p), 1010, 2020), ShouldBeNil)
+			}
+			So(os.Chmod(filepath.Join(tmpdir, "dir/sub/bin"), 0755|os.ModeSetuid), ShouldBeNil)
+			So(shift.shiftTrees(tmpdir, "/dir/sub"), ShouldBeNil)
+			So(shift.shiftDirs(tmpdir, []string{"/dir"}), ShouldBeNil)
+			for _, p := range []string{"dir", "dir/sub", "dir/sub/bin"} {
+				fi, err := os.Lstat(filepath.Join(tmpdir, p))
+				So(err, ShouldBeNil)
+				st := fi.Sys().(*syscall.Stat_t)
+				So([]uint32{st.Uid, st.Gid}, ShouldResemble, []uint32{10, 20})
+			}
+			fi, err := os.Lstat(filepath.Join(tmpdir, "dir/sub/bin"))
+			So(err, ShouldBeNil)
+			So(fi.Mode()&os.ModeSetuid, ShouldNotEqual, 0)
+		})
+	})
+}
+
+func Test_adaptUserns(t *testing.T) {
+	shift := &idShift{uid: 100000, gid: 100000, size: 65536}
+	newSpec := func() *specs.Spec {
+		return &specs.Spec{Linux: &specs.Linux{
+			Namespaces: []specs.LinuxNamespace{
+				{Type: specs.PIDNamespace}, {Type: specs.UserNamespace}, {Type: specs.MountNamespace},
+			},
+			UIDMappings: []specs.LinuxIDMapping{{HostID: 100000, Size: 65536}},
+			GIDMappings: []specs.LinuxIDMapping{{HostID: 100000, Size: 65536}},
+		}}
+	}
+
+	Convey("Test_adaptUserns", t, func() {
+		Convey("isulad remaps", func() {
+			dt := &dockerTransformer{userns: shift, isuladRemap: true}
+			So(dt.ownerShift(), ShouldBeNil)
+			h := &types.IsuladHostConfig{}
+			dt.adaptUsernsHostConfig(h, "")
+			So(h.UserRemap, ShouldEqual, "100000:100000:65536")
+			dt.adaptUsernsHostConfig(h, "host")
+			So(h.UserRemap, ShouldBeEmpty)
+			s := newSpec()
+			dt.adaptUsernsOciConfig(s)
+			So(Diff(s, newSpec()), ShouldBeEmpty)
+		})
+
+		Convey("isulad does not remap", func() {
+			dt := &dockerTransformer{userns: shift}
+			So(dt.ownerShift(), ShouldEqual, shift)
+			h := &types.IsuladHostConfig{UserRemap: "1:1:1"}
+			dt.adaptUsernsHostConfig(h, "")
+			So(h.UserRemap, ShouldBeEmpty)
+			s := newSpec()
+			dt.adaptUsernsOciConfig(s)
+			So(s.Linux.UIDMappings, ShouldBeNil)
+			So(s.Linux.GIDMappings, ShouldBeNil)
+			So(s.Linux.Namespaces, ShouldResemble, []specs.LinuxNamespace{
+				{Type: specs.PIDNamespace}, {Type: specs.MountNamespace},
+			})
+		})
+	})
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"isula.org/isula-transform/utils"
+)
+
+// maxReportMismatch limits the number of mismatches carried by the verify error
+const maxReportMismatch = 10
+
+// manifestEntry records the properties of a file which should be kept after transformation
+type manifestEntry struct {
+	Path   string
+	Type   os.FileMode
+	Mode   os.FileMode
+	UID    uint32
+	GID    uint32
+	Size   int64
+	Rdev   uint64
+	Link   string
+	Xattrs map[string]string
+	Hash   string
+}
+
+func (e *manifestEntry) diff(other *manifestEntry) string {
+	switch {
+	case e.Type != other.Type:
+		return fmt.Sprintf("type %v != %v", e.Type, other.Type)
+	case e.Mode != other.Mode:
+		return fmt.Sprintf("mode %v != %v", e.Mode, other.Mode)
+	case e.UID != other.UID || e.GID != other.GID:
+		return fmt.Sprintf("owner %d:%d != %d:%d", e.UID, e.GID, other.UID, other.GID)
+	case e.Size != other.Size:
+		return fmt.Sprintf("size %d != %d", e.Size, other.Size)
+	case e.Rdev != other.Rdev:
+		return fmt.Sprintf("device %d != %d", e.Rdev, other.Rdev)
+	case e.Link != other.Link:
+		return fmt.Sprintf("link %s != %s", e.Link, other.Link)
+	case e.Hash != other.Hash:
+		return "content hash mismatch"
+	}
+	if len(e.Xattrs) != len(other.Xattrs) {
+		return fmt.Sprintf("xattrs %v != %v", e.Xattrs, other.Xattrs)
+	}
+	for k, v := range e.Xattrs {
+		if ov, ok := other.Xattrs[k]; !ok || ov != v {
+			return fmt.Sprintf("xattr %s mismatch", k)
+		}
+	}
+	return ""
+}
+
+// manifest maps the path relative to the root of a rootfs to its entry
+type manifest map[string]*manifestEntry
+
+func newManifestEntry(root, rel string) (*manifestEntry, error) {
+	path := filepath.Join(root, rel)
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, errors.Errorf("unsupported stat type of %s", path)
+	}
+	entry := &manifestEntry{
+		Path: rel,
+		Type: fi.Mode() & os.ModeType,
+		Mode: fi.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky),
+		UID:  st.Uid,
+		GID:  st.Gid,
+	}
+	if entry.Xattrs, err = utils.Lgetxattrs(path); err != nil {
+		return nil, err
+	}
+	// the files are relabeled with the mount label of the container after copied
+	delete(entry.Xattrs, utils.SELinuxXattr)
+
+	switch {
+	case fi.Mode().IsRegular():
+		entry.Size = fi.Size()
+		if entry.Hash, err = hashFile(path); err != nil {
+			return nil, err
+		}
+	case fi.Mode()&os.ModeSymlink != 0:
+		if entry.Link, err = os.Readlink(path); err != nil {
+			return nil, err
+		}
+	case fi.Mode()&(os.ModeDevice|os.ModeCharDevice) != 0:
+		entry.Rdev = uint64(st.Rdev)
+	default:
+	}
+	return entry, nil
+}
+
+// dropXattrs removes the xattrs whose names start with any of prefixes from the entry
+func (e *manifestEntry) dropXattrs(prefixes ...string) {
+	for name := range e.Xattrs {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(name, prefix) {
+				delete(e.Xattrs, name)
+				break
+			}
+		}
+	}
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.Wrapf(err, "hash %s", path)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// buildManifest walks the given paths under root recursively and records every file,
+// the whole root is walked when paths is empty
+func buildManifest(root string, paths ...string) (manifest, error) {
+	m := make(manifest)
+	if len(paths) == 0 {
+		paths = []string{"/"}
+	}
+	for _, p := range paths {
+		start := filepath.Join(root, p)
+		err := filepath.Walk(start, func(path string, _ os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel := "/" + strings.TrimPrefix(strings.TrimPrefix(path, root), "/")
+			entry, err := newManifestEntry(root, rel)
+			if err != nil {
+				return err
+			}
+			m[rel] = entry
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "build manifest of %s", start)
+		}
+	}
+	return m, nil
+}
+
+// compare checks that every entry of the manifest exists under root with the same properties,
+// the xattrs whose names start with any of skippedXattrs are not compared
+func (m manifest) compare(root string, skippedXattrs ...string) error {
+	var mismatches []string
+	for _, rel := range m.paths() {
+		expect := m[rel]
+		got, err := newManifestEntry(root, rel)
+		if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %v", rel, err))
+			continue
+		}
+		expect.dropXattrs(skippedXattrs...)
+		got.dropXattrs(skippedXattrs...)
+		if d := expect.diff(got); d != "" {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %s", rel, d))
+		}
+	}
+	return mismatchError(mismatches)
+}
+
+func (m manifest) paths() []string {
+	paths := make([]string, 0, len(m))
+	for p := range m {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// checkRemoved makes sure that the deleted paths do not exist under root
+func checkRemoved(root string, paths []string) error {
+	var mismatches []string
+	for _, rel := range paths {
+		if _, err := os.Lstat(filepath.Join(root, rel)); err == nil {
+			mismatches = append(mismatches, fmt.Sprintf("%s: should be removed", rel))
+		} else if !os.IsNotExist(err) {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %v", rel, err))
+		}
+	}
+	return mismatchError(mismatches)
+}
+
+func mismatchError(mismatches []string) error {
+	if len(mismatches) == 0 {
+		return nil
+	}
+	total := len(mismatches)
+	if total > maxReportMismatch {
+		mismatches = mismatches[:maxReportMismatch]
+	}
+	return errors.Errorf("%d mismatch(es) found: %s", total, strings.Join(mismatches, "; "))
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/sys/unix"
+)
+
+func prepareVerifyTestTree(root string) error {
+	if err := os.MkdirAll(filepath.Join(root, "etc/app"), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "etc/app/app.conf"), []byte("key=value"), 0640); err != nil {
+		return err
+	}
+	return os.Symlink("app/app.conf", filepath.Join(root, "etc/app.conf"))
+}
+
+func Test_manifest(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	src, dest := filepath.Join(tmpdir, "src"), filepath.Join(tmpdir, "dest")
+	if err := prepareVerifyTestTree(src); err != nil {
+		t.Skipf("prepare test tree: %v", err)
+	}
+
+	Convey("Test_manifest", t, func() {
+		if err := exec.Command("cp", "-a", src, dest).Run(); err != nil {
+			t.Skipf("copy test tree: %v", err)
+		}
+		defer os.RemoveAll(dest)
+
+		Convey("build manifest of whole root", func() {
+			m, err := buildManifest(src)
+			So(err, ShouldBeNil)
+			So(m.paths(), ShouldResemble, []string{"/", "/etc", "/etc/app", "/etc/app.conf", "/etc/app/app.conf"})
+			So(m["/etc/app/app.conf"].Size, ShouldEqual, len("key=value"))
+			So(m["/etc/app.conf"].Link, ShouldEqual, "app/app.conf")
+		})
+
+		Convey("build manifest of specific paths", func() {
+			m, err := buildManifest(src, "/etc/app")
+			So(err, ShouldBeNil)
+			So(m.paths(), ShouldResemble, []string{"/etc/app", "/etc/app/app.conf"})
+		})
+
+		Convey("same content", func() {
+			m, err := buildManifest(src)
+			So(err, ShouldBeNil)
+			So(m.compare(dest), ShouldBeNil)
+		})
+
+		Convey("content changed", func() {
+			err := ioutil.WriteFile(filepath.Join(dest, "etc/app/app.conf"), []byte("key=VALUE"), 0640)
+			So(err, ShouldBeNil)
+			m, err := buildManifest(src)
+			So(err, ShouldBeNil)
+			err = m.compare(dest)
+			So(err, ShouldBeError)
+			So(err.Error(), ShouldContainSubstring, "/etc/app/app.conf: content hash mismatch")
+		})
+
+		Convey("mode changed and file lost", func() {
+			So(os.Chmod(filepath.Join(dest, "etc/app"), 0700), ShouldBeNil)
+			So(os.Remove(filepath.Join(dest, "etc/app.conf")), ShouldBeNil)
+			m, err := buildManifest(src)
+			So(err, ShouldBeNil)
+			err = m.compare(dest)
+			So(err, ShouldBeError)
+			So(err.Error(), ShouldContainSubstring, "2 mismatch(es) found")
+			So(err.Error(), ShouldContainSubstring, "/etc/app: mode")
+			So(err.Error(), ShouldContainSubstring, "/etc/app.conf: lstat")
+		})
+
+		Convey("overlay xattr lost", func() {
+			if err := unix.Lsetxattr(filepath.Join(src, "etc/app"), overlayOpaqueXattr, []byte("y"), 0); err != nil {
+				t.Skipf("set opaque xattr: %v", err)
+			}
+			defer func() {
+				_ = unix.Lremovexattr(filepath.Join(src, "etc/app"), overlayOpaqueXattr)
+			}()
+			m, err := buildManifest(src)
+			So(err, ShouldBeNil)
+			err = m.compare(dest)
+			So(err, ShouldBeError)
+			So(err.Error(), ShouldContainSubstring, "/etc/app: xattrs")
+			So(m.compare(dest, overlayXattrPrefix), ShouldBeNil)
+		})
+
+		Convey("check removed", func() {
+			So(checkRemoved(dest, []string{"/etc/notexist"}), ShouldBeNil)
+			err := checkRemoved(dest, []string{"/etc/app.conf"})
+			So(err, ShouldBeError)
+			So(err.Error(), ShouldContainSubstring, "/etc/app.conf: should be removed")
+		})
+	})
+}
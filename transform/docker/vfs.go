@@ -0,0 +1,39 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+package docker
+
+import (
+	"context"
+	"path/filepath"
+
+	"isula.org/isula-transform/transform"
+	"isula.org/isula-transform/types"
+)
+
+// vfsSource gets the changes of the container created by docker vfs driver, which keeps
+// a complete copy of rootfs in dir/<id>, by comparing it with the init layer dir/<init-id>
+// holding the content of the image
+type vfsSource struct {
+	graphRoot string
+}
+
+func (vs *vfsSource) open(_ context.Context, ctr *types.IsuladV2Config, oldRootFs string) (string, changeWalker, func(), error) {
+	initID, err := readLayerID(vs.graphRoot, transform.Vfs, ctr.CommonConfig.ID, "init-id")
+	if err != nil {
+		return "", nil, nil, err
+	}
+	parentRoot := filepath.Join(filepath.Dir(oldRootFs), initID)
+	walk := func(fn changeFunc) error {
+		return diffWalk(oldRootFs, parentRoot, ctr.CommonConfig.MountPoints, fn)
+	}
+	return oldRootFs, walk, func() {}, nil
+}
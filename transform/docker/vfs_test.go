@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+package docker
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	. "github.com/google/go-cmp/cmp"
+	. "github.com/smartystreets/goconvey/convey"
+	"isula.org/isula-transform/types"
+)
+
+func Test_vfsSource(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "isula-transform")
+	if err != nil {
+		t.Skipf("make temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	ctrID, mountID := "vfstest", "0123456789abcdef"
+	ctrRoot := filepath.Join(tmpdir, "vfs/dir", mountID)
+	initRoot := filepath.Join(tmpdir, "vfs/dir", mountID+"-init")
+	layerdb := filepath.Join(tmpdir, "image/vfs/layerdb/mounts", ctrID)
+	for _, dir := range []string{ctrRoot + "/etc", initRoot + "/etc", layerdb} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Skipf("prepare rootfs: %v", err)
+		}
+	}
+	for _, file := range []string{ctrRoot + "/etc/new", initRoot + "/etc/old"} {
+		if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+			t.Skipf("prepare rootfs: %v", err)
+		}
+	}
+
+	Convey("Test_vfsSource", t, func() {
+		vs := &vfsSource{graphRoot: tmpdir}
+		ctr := &types.IsuladV2Config{CommonConfig: &types.CommonConfig{ID: ctrID}}
+		_, _, _, err := vs.open(context.Background(), ctr, ctrRoot)
+		So(err, ShouldBeError)
+
+		So(ioutil.WriteFile(filepath.Join(layerdb, "init-id"), []byte(mountID+"-init"), 0600), ShouldBeNil)
+		srcRoot, walk, release, err := vs.open(context.Background(), ctr, ctrRoot)
+		So(err, ShouldBeNil)
+		defer release()
+		So(srcRoot, ShouldEqual, ctrRoot)
+
+		var got []container.ContainerChangeResponseItem
+		So(walk(func(change container.ContainerChangeResponseItem) error {
+			if change.Kind != metaItem {
+				got = append(got, change)
+			}
+			return nil
+		}), ShouldBeNil)
+		So(Diff(got, []container.ContainerChangeResponseItem{
+			{Kind: addItem, Path: "/etc/new"},
+			{Kind: delItem, Path: "/etc/old"},
+		}), ShouldBeBlank)
+	})
+}
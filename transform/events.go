@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package transform
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType is the type of the progress event of transformation
+type EventType string
+
+const (
+	// EventPhaseStarted is published when a container enters a phase
+	EventPhaseStarted EventType = "phase-started"
+	// EventPhaseFinished is published when a container leaves a phase, with the duration of the phase,
+	// Ok tells whether the phase succeeds and Msg is the error if not
+	EventPhaseFinished EventType = "phase-finished"
+	// EventBytesCopied reports Bytes of the Total bytes of read-write layer copied,
+	// Total is 0 until the last one if the size of read-write layer is not computed
+	EventBytesCopied EventType = "bytes-copied"
+	// EventWarning is a problem which does not fail the transformation
+	EventWarning EventType = "warning"
+	// EventRollbackStep is published after a rollback step described by Msg is executed
+	EventRollbackStep EventType = "rollback-step"
+	// EventResult is the final result of a container, the same as the Result sent to the channel
+	EventResult EventType = "result"
+)
+
+// phases of the transformation of a container
+const (
+	PhasePause    = "pause"
+	PhaseConfig   = "config"
+	PhaseRWLayer  = "rw-layer"
+	PhaseVerify   = "verify"
+	PhaseCreate   = "create"
+	PhaseStart    = "start"
+	PhaseFinalize = "finalize"
+)
+
+func isPhase(phase string) bool {
+	switch phase {
+	case PhasePause, PhaseConfig, PhaseRWLayer, PhaseVerify, PhaseCreate, PhaseStart, PhaseFinalize:
+		return true
+	default:
+	}
+	return false
+}
+
+// Event is a progress of the transformation of a container
+type Event struct {
+	Type      EventType     `json:"type"`
+	Container string        `json:"container"`
+	Time      time.Time     `json:"time"`
+	Phase     string        `json:"phase,omitempty"`
+	Duration  time.Duration `json:"duration,omitempty"`
+	Bytes     int64         `json:"bytes,omitempty"`
+	Total     int64         `json:"total,omitempty"`
+	Msg       string        `json:"msg,omitempty"`
+	Ok        bool          `json:"ok,omitempty"`
+}
+
+// EventBus delivers the events to all the subscribers. Publishing never blocks the
+// transformation, the events are dropped for the subscriber whose buffer is full
+type EventBus struct {
+	mu   sync.RWMutex
+	subs map[chan Event]struct{}
+}
+
+// NewEventBus creates an event bus without subscriber
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel of the events published from now on and a func to unsubscribe,
+// which closes the channel
+func (b *EventBus) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Active tells whether anyone subscribes, so that the costly events can be skipped
+func (b *EventBus) Active() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs) > 0
+}
+
+// Publish sends ev to the subscribers, the time of ev is set if it is zero
+func (b *EventBus) Publish(ev Event) {
+	if b == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Publisher is implemented by the transformers publishing the progress events
+type Publisher interface {
+	Events() *EventBus
+}
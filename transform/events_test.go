@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package transform
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEventBus(t *testing.T) {
+	Convey("TestEventBus", t, func() {
+		bus := NewEventBus()
+		So(bus.Active(), ShouldBeFalse)
+		bus.Publish(Event{Type: EventWarning, Msg: "nobody"})
+
+		first, unsubFirst := bus.Subscribe(1)
+		second, unsubSecond := bus.Subscribe(4)
+		So(bus.Active(), ShouldBeTrue)
+		bus.Publish(Event{Type: EventPhaseStarted, Container: "a", Phase: PhasePause})
+		// the buffer of first is full, the event is dropped for it
+		bus.Publish(Event{Type: EventPhaseFinished, Container: "a", Phase: PhasePause, Ok: true})
+		unsubFirst()
+		unsubFirst()
+
+		var got []Event
+		for ev := range first {
+			got = append(got, ev)
+		}
+		So(len(got), ShouldEqual, 1)
+		So(got[0].Type, ShouldEqual, EventPhaseStarted)
+		So(got[0].Time.IsZero(), ShouldBeFalse)
+
+		unsubSecond()
+		got = got[:0]
+		for ev := range second {
+			got = append(got, ev)
+		}
+		So(len(got), ShouldEqual, 2)
+		So(bus.Active(), ShouldBeFalse)
+
+		var nilBus *EventBus
+		So(nilBus.Active(), ShouldBeFalse)
+		nilBus.Publish(Event{Type: EventWarning})
+	})
+}
@@ -13,6 +13,8 @@
 package transform
 
 import (
+	"context"
+
 	"isula.org/isula-transform/types"
 )
 
@@ -25,19 +27,32 @@ const (
 	DeviceMapper StorageType = "devicemapper"
 )
 
+// legacy storage driver of docker, only supported as the source of transformation
+const (
+	Aufs StorageType = "aufs"
+	Vfs  StorageType = "vfs"
+)
+
 // StorageDriver defines methods for creating and rolling storage resources
 type StorageDriver interface {
-	// GenerateRootFs returns a new rootfs path used by container
-	GenerateRootFs(id, image string) (string, error)
-	// TransformRWLayer migrates container read-write layer data
-	TransformRWLayer(ctr *types.IsuladV2Config, oldRootFs string) error
+	// GenerateRootFs returns a new rootfs path used by container, limited by storageOpt
+	GenerateRootFs(id, image string, storageOpt map[string]string) (string, error)
+	// TransformRWLayer migrates container read-write layer data,
+	// size is the disk usage returned by RWLayerSize or negative if it is not computed
+	TransformRWLayer(ctx context.Context, ctr *types.IsuladV2Config, oldRootFs string, size int64) error
+	// RWLayerSize returns the disk usage of container read-write layer data need to be migrated
+	RWLayerSize(ctx context.Context, ctr *types.IsuladV2Config, oldRootFs string) (int64, error)
+	// RWLayerSpace returns the space left for read-write layer data in the new rootfs limited to limit bytes
+	RWLayerSpace(ctr *types.IsuladV2Config, limit int64) (int64, error)
+	// VerifyRWLayer checks that the migrated read-write layer is the same as the origin one
+	VerifyRWLayer(ctx context.Context, ctr *types.IsuladV2Config, oldRootFs string) error
 	// Cleanup olls back the image operation when the transformation fails
 	Cleanup(id string)
 }
 
 // BaseStorageDriver contains the common functions used by StorageDriver
 type BaseStorageDriver interface {
-	GenerateRootFs(id, image string) (string, error)
+	GenerateRootFs(id, image string, storageOpt map[string]string) (string, error)
 	CleanupRootFs(id string)
 	MountRootFs(id, image string) error
 	UmountRootFs(id, image string) error
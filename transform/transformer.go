@@ -15,6 +15,11 @@
 package transform
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
@@ -45,16 +50,40 @@ func Register(typ string, newFunc NewFunc) {
 	transformers[typ] = newFunc
 }
 
+// reasons why a container is skipped without being transformed
+const (
+	SkipExcluded    = "excluded"
+	SkipFiltered    = "filtered"
+	SkipTransformed = "transformed"
+	SkipUnresolved  = "unresolved"
+)
+
 // Result contains the success of and the output of the transformation
 type Result struct {
-	Msg string
-	Ok  bool
+	// Container is the ID of the container, or the reference given when it is not resolved
+	Container string
+	Msg       string
+	Ok        bool
+	// Skipped is the reason why the container is not transformed at all, empty if it is attempted
+	Skipped string
 }
 
-// Transformer defines common container transform engine interface
+// Transformer defines common container transform engine interface. Transform stops when ctx
+// is done, the containers being transformed are rolled back and the cause is reported in their results
 type Transformer interface {
 	Init() error
-	Transform([]string, bool, chan Result)
+	Transform(context.Context, []string, bool, chan Result)
+}
+
+// Checker is implemented by the transformers able to check whether
+// the containers can be transformed without changing anything
+type Checker interface {
+	Check(context.Context, []string, bool, chan Result)
+}
+
+// Reverter is implemented by the transformers able to roll back the transformed containers
+type Reverter interface {
+	Rollback(context.Context, []string, chan Result)
 }
 
 // BaseTransformer contains the base members of transformer
@@ -62,6 +91,27 @@ type BaseTransformer struct {
 	Name      string
 	GraphRoot string
 	StateRoot string
+	// Verify checks the content of the migrated read-write layer
+	Verify bool
+	// Parallel limits the number of containers transformed at the same time, 0 means no limit
+	Parallel int
+	// Start starts the transformed containers in isulad and waits StartTimeout for them to be ready
+	Start        bool
+	StartTimeout time.Duration
+	// ContainerTimeout limits the transformation of each container, 0 means no limit
+	ContainerTimeout time.Duration
+	// PhaseTimeouts limits each phase of the transformation of a container
+	PhaseTimeouts map[string]time.Duration
+	// EventBus publishes the progress of transformation
+	EventBus *EventBus
+}
+
+// Events returns the bus of progress events, created on first use
+func (e *BaseTransformer) Events() *EventBus {
+	if e.EventBus == nil {
+		e.EventBus = NewEventBus()
+	}
+	return e.EventBus
 }
 
 // EngineOpt allows configuring a BaseEngineCfg
@@ -81,6 +131,78 @@ func EngineWithState(stateRoot string) EngineOpt {
 	}
 }
 
+// EngineWithVerify sets whether to verify the read-write layer after migration
+func EngineWithVerify(verify bool) EngineOpt {
+	return func(e *BaseTransformer) {
+		e.Verify = verify
+	}
+}
+
+// EngineWithParallel sets the number of containers transformed at the same time
+func EngineWithParallel(parallel int) EngineOpt {
+	return func(e *BaseTransformer) {
+		e.Parallel = parallel
+	}
+}
+
+// EngineWithStart sets whether to start the containers in isulad after transformation
+func EngineWithStart(start bool) EngineOpt {
+	return func(e *BaseTransformer) {
+		e.Start = start
+	}
+}
+
+// EngineWithStartTimeout sets how long to wait for the started containers to be ready
+func EngineWithStartTimeout(timeout time.Duration) EngineOpt {
+	return func(e *BaseTransformer) {
+		e.StartTimeout = timeout
+	}
+}
+
+// EngineWithEvents sets the bus the progress events are published to
+func EngineWithEvents(bus *EventBus) EngineOpt {
+	return func(e *BaseTransformer) {
+		e.EventBus = bus
+	}
+}
+
+// EngineWithContainerTimeout sets how long the transformation of each container may take
+func EngineWithContainerTimeout(timeout time.Duration) EngineOpt {
+	return func(e *BaseTransformer) {
+		e.ContainerTimeout = timeout
+	}
+}
+
+// EngineWithPhaseTimeout sets how long phase of the transformation of a container may take
+func EngineWithPhaseTimeout(phase string, timeout time.Duration) EngineOpt {
+	return func(e *BaseTransformer) {
+		if e.PhaseTimeouts == nil {
+			e.PhaseTimeouts = make(map[string]time.Duration)
+		}
+		e.PhaseTimeouts[phase] = timeout
+	}
+}
+
+// ParsePhaseTimeouts parses the timeouts given as phase=duration, such as rw-layer=30m
+func ParsePhaseTimeouts(specs []string) ([]EngineOpt, error) {
+	var opts []EngineOpt
+	for _, spec := range specs {
+		kv := strings.SplitN(spec, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid phase timeout %s, expected phase=duration", spec)
+		}
+		if !isPhase(kv[0]) {
+			return nil, fmt.Errorf("unknown phase %s in phase timeout %s", kv[0], spec)
+		}
+		timeout, err := time.ParseDuration(kv[1])
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid duration in phase timeout %s", spec)
+		}
+		opts = append(opts, EngineWithPhaseTimeout(kv[0], timeout))
+	}
+	return opts, nil
+}
+
 // GetTransformer returns the specified transformer
 func GetTransformer(ctx *cli.Context) Transformer {
 	typ := ctx.GlobalString("container-type")
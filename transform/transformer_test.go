@@ -13,8 +13,10 @@
 package transform
 
 import (
+	"context"
 	"flag"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/urfave/cli"
@@ -26,7 +28,7 @@ func newMockTransformer(*cli.Context) Transformer { return &mockTransformer{} }
 
 func (mt *mockTransformer) Init() error { return nil }
 
-func (mt *mockTransformer) Transform([]string, bool, chan Result) {}
+func (mt *mockTransformer) Transform(context.Context, []string, bool, chan Result) {}
 
 func TestTransformers(t *testing.T) {
 	defer delete(transformers, "mock")
@@ -74,5 +76,53 @@ func TestEngineOpt(t *testing.T) {
 			opt(base)
 			So(base.StateRoot, ShouldEqual, state)
 		})
+
+		Convey("TestEngineWithVerify", func() {
+			opt := EngineWithVerify(true)
+			opt(base)
+			So(base.Verify, ShouldBeTrue)
+		})
+
+		Convey("TestEngineWithParallel", func() {
+			opt := EngineWithParallel(4)
+			opt(base)
+			So(base.Parallel, ShouldEqual, 4)
+		})
+
+		Convey("TestEngineWithStart", func() {
+			EngineWithStart(true)(base)
+			EngineWithStartTimeout(time.Minute)(base)
+			So(base.Start, ShouldBeTrue)
+			So(base.StartTimeout, ShouldEqual, time.Minute)
+		})
+
+		Convey("TestEngineWithEvents", func() {
+			So(base.Events(), ShouldNotBeNil)
+			bus := NewEventBus()
+			EngineWithEvents(bus)(base)
+			So(base.Events(), ShouldEqual, bus)
+		})
+
+		Convey("TestEngineWithTimeouts", func() {
+			EngineWithContainerTimeout(time.Hour)(base)
+			So(base.ContainerTimeout, ShouldEqual, time.Hour)
+
+			opts, err := ParsePhaseTimeouts([]string{"rw-layer=30m", "pause=10s"})
+			So(err, ShouldBeNil)
+			for _, o := range opts {
+				o(base)
+			}
+			So(base.PhaseTimeouts, ShouldResemble, map[string]time.Duration{
+				PhaseRWLayer: 30 * time.Minute,
+				PhasePause:   10 * time.Second,
+			})
+		})
+
+		Convey("TestParsePhaseTimeouts with invalid specs", func() {
+			for _, spec := range []string{"rw-layer", "copy=1m", "pause=soon", "pause=-1s"} {
+				_, err := ParsePhaseTimeouts([]string{spec})
+				So(err, ShouldBeError)
+			}
+		})
 	})
 }
@@ -136,6 +136,9 @@ type CommonConfig struct {
 	OriginHostnamePath   string `json:"-"`
 	OriginHostsPath      string `json:"-"`
 	OriginResolvConfPath string `json:"-"`
+
+	// Backup storage driver of docker
+	OriginDriver string `json:"-"`
 }
 
 // GetOriginNetworkFile returns the path specified file in host, hostname and resolv.conf
@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// SELinuxXattr is the extended attribute holding the SELinux label of a file
+	SELinuxXattr   = "security.selinux"
+	selinuxFsMount = "/sys/fs/selinux"
+	// sharedLevel is the level of the labels shared by all the containers
+	sharedLevel = "s0"
+)
+
+var (
+	selinuxOnce    sync.Once
+	selinuxEnabled bool
+)
+
+// SELinuxEnabled reports whether SELinux is enabled on the host, the labels are ignored if it is not
+func SELinuxEnabled() bool {
+	selinuxOnce.Do(func() {
+		var st unix.Statfs_t
+		selinuxEnabled = unix.Statfs(selinuxFsMount, &st) == nil && st.Type == unix.SELINUX_MAGIC
+	})
+	return selinuxEnabled
+}
+
+// SharedLabel returns the label with the level shared by all the containers, used by the :z bind mounts
+func SharedLabel(label string) string {
+	// user:role:type:level, the level may contain ':' as s0:c1,c2
+	fields := strings.SplitN(label, ":", 4)
+	if len(fields) < 4 {
+		return label
+	}
+	return strings.Join(append(fields[:3], sharedLevel), ":")
+}
+
+// FormatMountLabel appends the context option of label to the mount options opts
+func FormatMountLabel(opts, label string) string {
+	if label == "" || !SELinuxEnabled() {
+		return opts
+	}
+	ctx := fmt.Sprintf("context=%q", label)
+	if opts == "" {
+		return ctx
+	}
+	return opts + "," + ctx
+}
+
+// Relabel sets the SELinux label of path and its children to label without following symlinks
+func Relabel(path, label string) error {
+	if label == "" || !SELinuxEnabled() {
+		return nil
+	}
+	return filepath.Walk(path, func(p string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return setLabel(p, label)
+	})
+}
+
+// SetLabel sets the SELinux label of path only to label without following symlinks
+func SetLabel(path, label string) error {
+	if label == "" || !SELinuxEnabled() {
+		return nil
+	}
+	return setLabel(path, label)
+}
+
+func setLabel(path, label string) error {
+	if err := unix.Lsetxattr(path, SELinuxXattr, []byte(label), 0); err != nil {
+		return errors.Wrapf(err, "set label of %s to %s", path, label)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+package utils
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the bytes per second passing through all the readers it wraps
+type RateLimiter struct {
+	mu   sync.Mutex
+	bps  int64
+	next time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing bps bytes per second
+func NewRateLimiter(bps int64) *RateLimiter {
+	return &RateLimiter{bps: bps}
+}
+
+// Wait blocks until n more bytes are allowed to pass
+func (l *RateLimiter) Wait(n int) {
+	if n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.bps))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// Reader returns a reader whose data read from r is limited by l
+func (l *RateLimiter) Reader(r io.Reader) io.Reader {
+	return &limitedReader{r: r, l: l}
+}
+
+type limitedReader struct {
+	r io.Reader
+	l *RateLimiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	lr.l.Wait(n)
+	return n, err
+}
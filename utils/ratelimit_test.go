@@ -0,0 +1,33 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+package utils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRateLimiter(t *testing.T) {
+	Convey("TestRateLimiter", t, func() {
+		l := NewRateLimiter(1024 * 1024)
+		data := make([]byte, 256*1024)
+		start := time.Now()
+		got, err := ioutil.ReadAll(l.Reader(bytes.NewReader(data)))
+		So(err, ShouldBeNil)
+		So(len(got), ShouldEqual, len(data))
+		So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 200*time.Millisecond)
+	})
+}
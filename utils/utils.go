@@ -14,9 +14,13 @@
 package utils
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"syscall"
 
 	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
 )
 
 const (
@@ -38,3 +42,60 @@ func CheckFileValid(path string) error {
 	}
 	return nil
 }
+
+// CopyMetadata applies the owner, mode, extended attributes and times of src to dest, the content
+// of dest and its extended attributes starting with one of skippedXattrs are left untouched
+func CopyMetadata(src, dest string, skippedXattrs ...string) error {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return errors.Wrapf(err, "stat %s", src)
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errors.Errorf("unsupported stat type of %s", src)
+	}
+
+	// chown clears the setuid and setgid bits, so change owner before mode
+	if err := os.Lchown(dest, int(st.Uid), int(st.Gid)); err != nil {
+		return errors.Wrapf(err, "change owner of %s", dest)
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		if err := os.Chmod(dest, fi.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+			return errors.Wrapf(err, "change mode of %s", dest)
+		}
+	}
+	if err := CopyXattrs(src, dest, skippedXattrs...); err != nil {
+		return err
+	}
+	ts := []unix.Timespec{unix.Timespec(st.Atim), unix.Timespec(st.Mtim)}
+	if err := unix.UtimesNanoAt(unix.AT_FDCWD, dest, ts, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return errors.Wrapf(err, "change times of %s", dest)
+	}
+	return nil
+}
+
+// AtomicWriteFile writes data to a temporary file in the same directory and renames it to path,
+// so that the readers see either the old content or the new one
+func AtomicWriteFile(path string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "create temporary file for %s", path)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "write temporary file for %s", path)
+	}
+	return errors.Wrapf(os.Rename(tmp, path), "rename to %s", path)
+}
@@ -15,9 +15,12 @@ package utils
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/sys/unix"
 )
 
 func TestCheckFileValid(t *testing.T) {
@@ -67,3 +70,106 @@ func TestCheckFileValid(t *testing.T) {
 		})
 	})
 }
+
+func TestCopyMetadata(t *testing.T) {
+	Convey("TestCopyMetadata", t, func() {
+		tmpdir, err := ioutil.TempDir("", "isula-transform")
+		if err != nil {
+			t.Skipf("make temp dir: %v", err)
+		}
+		defer os.RemoveAll(tmpdir)
+		src, dest := filepath.Join(tmpdir, "src"), filepath.Join(tmpdir, "dest")
+		So(os.Mkdir(src, 0750), ShouldBeNil)
+		So(os.Mkdir(dest, 0700), ShouldBeNil)
+		So(os.Chmod(src, 0750|os.ModeSticky), ShouldBeNil)
+		mtime := time.Unix(1579744800, 0)
+		So(os.Chtimes(src, mtime, mtime), ShouldBeNil)
+
+		Convey("not exist", func() {
+			So(CopyMetadata(filepath.Join(tmpdir, "notexist"), dest), ShouldBeError)
+		})
+
+		Convey("skipped xattrs", func() {
+			if err := unix.Lsetxattr(src, "user.src", []byte("src"), 0); err != nil {
+				t.Skipf("set xattr: %v", err)
+			}
+			So(unix.Lsetxattr(src, "user.skip.src", []byte("src"), 0), ShouldBeNil)
+			So(unix.Lsetxattr(dest, "user.skip.dest", []byte("dest"), 0), ShouldBeNil)
+			So(unix.Lsetxattr(dest, "user.dest", []byte("dest"), 0), ShouldBeNil)
+			So(CopyMetadata(src, dest, "user.skip."), ShouldBeNil)
+			xattrs, err := Lgetxattrs(dest)
+			So(err, ShouldBeNil)
+			So(xattrs, ShouldResemble, map[string]string{"user.src": "src", "user.skip.dest": "dest"})
+
+			So(os.Mkdir(filepath.Join(dest, "child"), 0700), ShouldBeNil)
+			So(unix.Lsetxattr(filepath.Join(dest, "child"), "user.skip.child", []byte("child"), 0), ShouldBeNil)
+			So(RemoveXattrs(dest, "user.skip."), ShouldBeNil)
+			xattrs, err = Lgetxattrs(dest)
+			So(err, ShouldBeNil)
+			So(xattrs, ShouldResemble, map[string]string{"user.src": "src"})
+			xattrs, err = Lgetxattrs(filepath.Join(dest, "child"))
+			So(err, ShouldBeNil)
+			So(xattrs, ShouldBeEmpty)
+		})
+
+		Convey("copy mode and times", func() {
+			So(CopyMetadata(src, dest), ShouldBeNil)
+			fi, err := os.Stat(dest)
+			So(err, ShouldBeNil)
+			So(fi.Mode().Perm(), ShouldEqual, os.FileMode(0750))
+			So(fi.Mode()&os.ModeSticky, ShouldNotBeZeroValue)
+			So(fi.ModTime().Equal(mtime), ShouldBeTrue)
+		})
+	})
+}
+
+func TestAtomicWriteFile(t *testing.T) {
+	Convey("TestAtomicWriteFile", t, func() {
+		tmpdir, err := ioutil.TempDir("", "isula-transform")
+		if err != nil {
+			t.Skipf("make temp dir: %v", err)
+		}
+		defer os.RemoveAll(tmpdir)
+		path := filepath.Join(tmpdir, "file")
+		So(ioutil.WriteFile(path, []byte("old"), 0600), ShouldBeNil)
+
+		So(AtomicWriteFile(path, []byte("new"), 0644), ShouldBeNil)
+		data, err := ioutil.ReadFile(path)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "new")
+		fi, err := os.Stat(path)
+		So(err, ShouldBeNil)
+		So(fi.Mode().Perm(), ShouldEqual, os.FileMode(0644))
+		infos, err := ioutil.ReadDir(tmpdir)
+		So(err, ShouldBeNil)
+		So(len(infos), ShouldEqual, 1)
+
+		So(AtomicWriteFile(filepath.Join(tmpdir, "missing/file"), nil, 0644), ShouldBeError)
+	})
+}
+
+func TestLabel(t *testing.T) {
+	Convey("TestLabel", t, func() {
+		Convey("shared label", func() {
+			So(SharedLabel("system_u:object_r:container_file_t:s0:c1,c2"), ShouldEqual,
+				"system_u:object_r:container_file_t:s0")
+			So(SharedLabel("system_u:object_r:container_file_t:s0"), ShouldEqual,
+				"system_u:object_r:container_file_t:s0")
+			So(SharedLabel("invalid"), ShouldEqual, "invalid")
+		})
+
+		Convey("mount label", func() {
+			label := "system_u:object_r:container_file_t:s0:c1,c2"
+			So(FormatMountLabel("mode=1777", ""), ShouldEqual, "mode=1777")
+			if !SELinuxEnabled() {
+				So(FormatMountLabel("mode=1777", label), ShouldEqual, "mode=1777")
+				So(Relabel("/not/exist/in/host", label), ShouldBeNil)
+				So(SetLabel("/not/exist/in/host", label), ShouldBeNil)
+				return
+			}
+			So(FormatMountLabel("mode=1777", label), ShouldEqual, `mode=1777,context="`+label+`"`)
+			So(FormatMountLabel("", label), ShouldEqual, `context="`+label+`"`)
+			So(SetLabel("/not/exist/in/host", label), ShouldBeError)
+		})
+	})
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) 2020 Huawei Technologies Co., Ltd.
+ * isula-transform is licensed under the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Create: 2026-10-18
+ */
+
+package utils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// Lgetxattrs returns all extended attributes of path without following symlinks
+func Lgetxattrs(path string) (map[string]string, error) {
+	size, err := unix.Llistxattr(path, nil)
+	if err != nil {
+		if err == unix.ENOTSUP {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "list xattrs of %s", path)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(path, buf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "list xattrs of %s", path)
+	}
+
+	xattrs := make(map[string]string)
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		value, err := Lgetxattr(path, string(name))
+		if err != nil {
+			return nil, err
+		}
+		xattrs[string(name)] = string(value)
+	}
+	return xattrs, nil
+}
+
+// Lgetxattr returns the value of the extended attribute attr of path without following symlinks
+func Lgetxattr(path, attr string) ([]byte, error) {
+	size, err := unix.Lgetxattr(path, attr, nil)
+	if err != nil {
+		if err == unix.ENODATA {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "get xattr %s of %s", attr, path)
+	}
+	value := make([]byte, size)
+	size, err = unix.Lgetxattr(path, attr, value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get xattr %s of %s", attr, path)
+	}
+	return value[:size], nil
+}
+
+// CopyXattrs makes the extended attributes of dest the same as src, except the ones whose names
+// start with one of skipped, which are neither copied from src nor removed from dest
+func CopyXattrs(src, dest string, skipped ...string) error {
+	srcXattrs, err := Lgetxattrs(src)
+	if err != nil {
+		return err
+	}
+	destXattrs, err := Lgetxattrs(dest)
+	if err != nil {
+		return err
+	}
+	for name := range destXattrs {
+		if _, ok := srcXattrs[name]; ok || hasAnyPrefix(name, skipped) {
+			continue
+		}
+		if err := unix.Lremovexattr(dest, name); err != nil {
+			return errors.Wrapf(err, "remove xattr %s of %s", name, dest)
+		}
+	}
+	for name, value := range srcXattrs {
+		if hasAnyPrefix(name, skipped) {
+			continue
+		}
+		if err := unix.Lsetxattr(dest, name, []byte(value), 0); err != nil {
+			return errors.Wrapf(err, "set xattr %s of %s", name, dest)
+		}
+	}
+	return nil
+}
+
+// RemoveXattrs removes the extended attributes whose names start with prefix from path
+// and its children without following symlinks
+func RemoveXattrs(path, prefix string) error {
+	return filepath.Walk(path, func(p string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		xattrs, err := Lgetxattrs(p)
+		if err != nil {
+			return err
+		}
+		for name := range xattrs {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			if err := unix.Lremovexattr(p, name); err != nil {
+				return errors.Wrapf(err, "remove xattr %s of %s", name, p)
+			}
+		}
+		return nil
+	})
+}
+
+func hasAnyPrefix(name string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}